@@ -0,0 +1,77 @@
+package instrumented
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/m-zajac/smartcache"
+)
+
+// Operation is a backend operation reported to the observer.
+type Operation string
+
+const (
+	OperationGet    Operation = "get"
+	OperationSet    Operation = "set"
+	OperationDelete Operation = "delete"
+)
+
+// Observer receives the duration and the error of every backend operation.
+// It's called synchronously, so it should be fast and safe for concurrent use.
+type Observer interface {
+	ObserveOperation(op Operation, d time.Duration, err error)
+}
+
+// Backend wraps another backend, reporting its operations to an observer.
+// Optional interfaces of the wrapped backend, like `smartcache.MultiGetBackend`, are not exposed.
+//
+// The wrapped backend will be closed when the parent cache is closed.
+type Backend[T any] struct {
+	backend  smartcache.Backend[T]
+	observer Observer
+}
+
+var _ smartcache.Backend[string] = &Backend[string]{}
+
+func NewBackend[T any](backend smartcache.Backend[T], observer Observer) (*Backend[T], error) {
+	if backend == nil {
+		return nil, errors.New("backend is nil")
+	}
+	if observer == nil {
+		return nil, errors.New("observer is nil")
+	}
+
+	return &Backend[T]{
+		backend:  backend,
+		observer: observer,
+	}, nil
+}
+
+func (b *Backend[T]) Get(ctx context.Context, key string) (*smartcache.CacheEntry[T], error) {
+	start := time.Now()
+	entry, err := b.backend.Get(ctx, key)
+	b.observer.ObserveOperation(OperationGet, time.Since(start), err)
+
+	return entry, err
+}
+
+func (b *Backend[T]) Set(ctx context.Context, key string, ttl time.Duration, entry *smartcache.CacheEntry[T]) error {
+	start := time.Now()
+	err := b.backend.Set(ctx, key, ttl, entry)
+	b.observer.ObserveOperation(OperationSet, time.Since(start), err)
+
+	return err
+}
+
+func (b *Backend[T]) Delete(ctx context.Context, key string) error {
+	start := time.Now()
+	err := b.backend.Delete(ctx, key)
+	b.observer.ObserveOperation(OperationDelete, time.Since(start), err)
+
+	return err
+}
+
+func (b *Backend[T]) Close() {
+	b.backend.Close()
+}
@@ -0,0 +1,116 @@
+package instrumented_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/m-zajac/smartcache"
+	"github.com/m-zajac/smartcache/backend/instrumented"
+	"github.com/m-zajac/smartcache/backend/lru"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type observation struct {
+	op  instrumented.Operation
+	d   time.Duration
+	err error
+}
+
+type recordingObserver struct {
+	mu           sync.Mutex
+	observations []observation
+}
+
+func (o *recordingObserver) ObserveOperation(op instrumented.Operation, d time.Duration, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.observations = append(o.observations, observation{op: op, d: d, err: err})
+}
+
+func TestBackend(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	inner, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+	obs := &recordingObserver{}
+	backend, err := instrumented.NewBackend[string](inner, obs)
+	require.NoError(t, err)
+
+	_, err = instrumented.NewBackend[string](nil, obs)
+	assert.Error(t, err)
+	_, err = instrumented.NewBackend[string](inner, nil)
+	assert.Error(t, err)
+
+	data := "testvalue"
+	require.NoError(t, backend.Set(ctx, "key", time.Minute, &smartcache.CacheEntry[string]{
+		Data:    &data,
+		Created: time.Now(),
+	}))
+	entry, err := backend.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, "testvalue", *entry.Data)
+	require.NoError(t, backend.Delete(ctx, "key"))
+
+	require.Len(t, obs.observations, 3)
+	for i, op := range []instrumented.Operation{instrumented.OperationSet, instrumented.OperationGet, instrumented.OperationDelete} {
+		assert.Equal(t, op, obs.observations[i].op)
+		assert.Positive(t, obs.observations[i].d)
+		assert.NoError(t, obs.observations[i].err)
+	}
+}
+
+// failingBackend fails all operations.
+type failingBackend struct {
+	err error
+}
+
+func (b *failingBackend) Get(ctx context.Context, key string) (*smartcache.CacheEntry[string], error) {
+	return nil, b.err
+}
+
+func (b *failingBackend) Set(ctx context.Context, key string, ttl time.Duration, entry *smartcache.CacheEntry[string]) error {
+	return b.err
+}
+
+func (b *failingBackend) Delete(ctx context.Context, key string) error {
+	return b.err
+}
+
+func (b *failingBackend) Close() {}
+
+func TestBackend_Errors(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	backendErr := errors.New("backend failed")
+	obs := &recordingObserver{}
+	backend, err := instrumented.NewBackend[string](&failingBackend{err: backendErr}, obs)
+	require.NoError(t, err)
+
+	cache, err := smartcache.New[string](backend)
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	_, err = cache.Get(ctx, "key", func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		t.Fatal("unexpected fetch")
+		return nil, nil
+	})
+	assert.ErrorIs(t, err, backendErr)
+	assert.ErrorIs(t, backend.Set(ctx, "key", time.Minute, &smartcache.CacheEntry[string]{}), backendErr)
+	assert.ErrorIs(t, backend.Delete(ctx, "key"), backendErr)
+
+	obs.mu.Lock()
+	defer obs.mu.Unlock()
+	require.Len(t, obs.observations, 3)
+	for i, op := range []instrumented.Operation{instrumented.OperationGet, instrumented.OperationSet, instrumented.OperationDelete} {
+		assert.Equal(t, op, obs.observations[i].op)
+		assert.ErrorIs(t, obs.observations[i].err, backendErr)
+	}
+}
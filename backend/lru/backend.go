@@ -2,7 +2,9 @@ package lru
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync/atomic"
 	"time"
 
 	lru "github.com/hashicorp/golang-lru/v2"
@@ -12,9 +14,19 @@ import (
 // Backend for cache that stores data in-memory using LRU cache.
 type Backend[T any] struct {
 	cache *lru.Cache[string, *smartcache.CacheEntry[T]]
+	size  atomic.Int64
 }
 
-var _ smartcache.Backend[string] = &Backend[string]{}
+var (
+	_ smartcache.Backend[string]              = &Backend[string]{}
+	_ smartcache.MultiGetBackend[string]      = &Backend[string]{}
+	_ smartcache.PurgingBackend[string]       = &Backend[string]{}
+	_ smartcache.ListingBackend               = &Backend[string]{}
+	_ smartcache.PurgeableBackend             = &Backend[string]{}
+	_ smartcache.CapacityBackend              = &Backend[string]{}
+	_ smartcache.ResizableBackend             = &Backend[string]{}
+	_ smartcache.LocalEvictingBackend[string] = &Backend[string]{}
+)
 
 func NewBackend[T any](size uint) (*Backend[T], error) {
 	cache, err := lru.New[string, *smartcache.CacheEntry[T]](int(size))
@@ -22,9 +34,34 @@ func NewBackend[T any](size uint) (*Backend[T], error) {
 		return nil, fmt.Errorf("creating lru cache: %w", err)
 	}
 
-	return &Backend[T]{
+	return newBackend(cache, size), nil
+}
+
+// NewBackendWithEvict works like NewBackend, but calls onEvict with the key of every entry leaving the backend:
+// evicted when the backend is full or resized, purged, or deleted. It's called after the entry is removed,
+// outside of the backend's lock.
+func NewBackendWithEvict[T any](size uint, onEvict func(key string)) (*Backend[T], error) {
+	if onEvict == nil {
+		return nil, errors.New("evict function is nil")
+	}
+
+	cache, err := lru.NewWithEvict(int(size), func(key string, _ *smartcache.CacheEntry[T]) {
+		onEvict(key)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating lru cache: %w", err)
+	}
+
+	return newBackend(cache, size), nil
+}
+
+func newBackend[T any](cache *lru.Cache[string, *smartcache.CacheEntry[T]], size uint) *Backend[T] {
+	b := &Backend[T]{
 		cache: cache,
-	}, nil
+	}
+	b.size.Store(int64(size))
+
+	return b
 }
 
 func (b *Backend[T]) Get(ctx context.Context, key string) (*smartcache.CacheEntry[T], error) {
@@ -36,10 +73,76 @@ func (b *Backend[T]) Get(ctx context.Context, key string) (*smartcache.CacheEntr
 	return item, nil
 }
 
+func (b *Backend[T]) GetMulti(ctx context.Context, keys []string) (map[string]*smartcache.CacheEntry[T], error) {
+	entries := make(map[string]*smartcache.CacheEntry[T], len(keys))
+	for _, key := range keys {
+		if item, found := b.cache.Get(key); found {
+			entries[key] = item
+		}
+	}
+
+	return entries, nil
+}
+
 func (b *Backend[T]) Set(ctx context.Context, key string, ttl time.Duration, data *smartcache.CacheEntry[T]) error {
 	_ = b.cache.Add(key, data)
 
 	return nil
 }
 
+func (b *Backend[T]) Delete(ctx context.Context, key string) error {
+	_ = b.cache.Remove(key)
+
+	return nil
+}
+
+// EvictLocal removes the entry like Delete, as all entries are local.
+func (b *Backend[T]) EvictLocal(ctx context.Context, key string) error {
+	return b.Delete(ctx, key)
+}
+
+// PurgeExpired removes entries for which the expired function returns true.
+func (b *Backend[T]) PurgeExpired(ctx context.Context, expired func(key string, entry *smartcache.CacheEntry[T]) bool) (int, error) {
+	var purged int
+	for _, key := range b.cache.Keys() {
+		entry, found := b.cache.Peek(key)
+		if found && expired(key, entry) {
+			b.cache.Remove(key)
+			purged++
+		}
+	}
+
+	return purged, nil
+}
+
+// Len returns the number of stored keys.
+func (b *Backend[T]) Len(ctx context.Context) (int, error) {
+	return b.cache.Len(), nil
+}
+
+// Cap returns the maximum number of stored keys, the size of the backend.
+func (b *Backend[T]) Cap() int {
+	return int(b.size.Load())
+}
+
+// Resize changes the size of the backend, which has to be > 0. If it shrinks, the least recently used entries
+// are evicted. It returns the number of evicted entries.
+func (b *Backend[T]) Resize(size int) int {
+	b.size.Store(int64(size))
+
+	return b.cache.Resize(size)
+}
+
+// Keys returns the stored keys, from the least recently used one.
+func (b *Backend[T]) Keys(ctx context.Context) ([]string, error) {
+	return b.cache.Keys(), nil
+}
+
+// Purge removes all entries.
+func (b *Backend[T]) Purge(ctx context.Context) error {
+	b.cache.Purge()
+
+	return nil
+}
+
 func (b *Backend[T]) Close() {}
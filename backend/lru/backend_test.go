@@ -2,12 +2,15 @@ package lru_test
 
 import (
 	"context"
+	"fmt"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/m-zajac/smartcache"
 	"github.com/m-zajac/smartcache/backend/lru"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestBackend(t *testing.T) {
@@ -17,6 +20,7 @@ func TestBackend(t *testing.T) {
 
 	backend, err := lru.NewBackend[string](100)
 	assert.NoError(t, err)
+	assert.Equal(t, 100, backend.Cap())
 
 	entry := smartcache.CacheEntry[string]{
 		Data:    ptr("testvalue"),
@@ -30,8 +34,262 @@ func TestBackend(t *testing.T) {
 	gotEntry, err := backend.Get(ctx, key)
 	assert.NoError(t, err)
 	assert.Equal(t, &entry, gotEntry)
+
+	err = backend.Delete(ctx, key)
+	assert.NoError(t, err)
+
+	gotEntry, err = backend.Get(ctx, key)
+	assert.NoError(t, err)
+	assert.Nil(t, gotEntry)
+
+	// Deleting a missing key is fine.
+	assert.NoError(t, backend.Delete(ctx, key))
 }
 
 func ptr[T any](v T) *T {
 	return &v
 }
+
+func TestBackend_GetMulti(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	backend, err := lru.NewBackend[string](100)
+	assert.NoError(t, err)
+
+	entry := smartcache.CacheEntry[string]{
+		Data:    ptr("testvalue"),
+		Created: time.Now(),
+	}
+	err = backend.Set(ctx, "key1", time.Minute, &entry)
+	assert.NoError(t, err)
+
+	entries, err := backend.GetMulti(ctx, []string{"key1", "missing"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]*smartcache.CacheEntry[string]{"key1": &entry}, entries)
+}
+
+func TestBackends_PurgeExpired(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	plain, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+	weighted, err := lru.NewWeightedBackend[string](100, func(entry *smartcache.CacheEntry[string]) int { return 1 })
+	require.NoError(t, err)
+	freshness, err := lru.NewFreshnessBackend[string](100, time.Minute)
+	require.NoError(t, err)
+
+	backends := map[string]interface {
+		smartcache.Backend[string]
+		smartcache.PurgingBackend[string]
+	}{
+		"plain":     plain,
+		"weighted":  weighted,
+		"freshness": freshness,
+	}
+	for name, backend := range backends {
+		backend := backend
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			for _, key := range []string{"keep1", "purge1", "keep2", "purge2"} {
+				require.NoError(t, backend.Set(ctx, key, time.Minute, &smartcache.CacheEntry[string]{
+					Data:    ptr(key),
+					Created: time.Now(),
+				}))
+			}
+
+			purged, err := backend.PurgeExpired(ctx, func(key string, entry *smartcache.CacheEntry[string]) bool {
+				return strings.HasPrefix(key, "purge")
+			})
+			require.NoError(t, err)
+			assert.Equal(t, 2, purged)
+
+			entry, err := backend.Get(ctx, "purge1")
+			require.NoError(t, err)
+			assert.Nil(t, entry)
+			for _, key := range []string{"keep1", "keep2"} {
+				entry, err := backend.Get(ctx, key)
+				require.NoError(t, err)
+				assert.NotNil(t, entry, key)
+			}
+		})
+	}
+}
+
+func TestBackends_Keys(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	plain, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+	weighted, err := lru.NewWeightedBackend[string](100, func(entry *smartcache.CacheEntry[string]) int { return 1 })
+	require.NoError(t, err)
+	freshness, err := lru.NewFreshnessBackend[string](100, time.Minute)
+	require.NoError(t, err)
+
+	backends := map[string]interface {
+		smartcache.Backend[string]
+		smartcache.ListingBackend
+	}{
+		"plain":     plain,
+		"weighted":  weighted,
+		"freshness": freshness,
+	}
+	for name, backend := range backends {
+		backend := backend
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			n, err := backend.Len(ctx)
+			require.NoError(t, err)
+			assert.Zero(t, n)
+
+			for _, key := range []string{"key1", "key2", "key3"} {
+				require.NoError(t, backend.Set(ctx, key, time.Minute, &smartcache.CacheEntry[string]{
+					Data:    ptr(key),
+					Created: time.Now(),
+				}))
+			}
+			require.NoError(t, backend.Delete(ctx, "key2"))
+
+			n, err = backend.Len(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, 2, n)
+
+			keys, err := backend.Keys(ctx)
+			require.NoError(t, err)
+			assert.ElementsMatch(t, []string{"key1", "key3"}, keys)
+		})
+	}
+}
+
+func TestBackends_Purge(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	plain, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+	weighted, err := lru.NewWeightedBackend[string](100, func(entry *smartcache.CacheEntry[string]) int { return 1 })
+	require.NoError(t, err)
+	freshness, err := lru.NewFreshnessBackend[string](100, time.Minute)
+	require.NoError(t, err)
+
+	backends := map[string]interface {
+		smartcache.Backend[string]
+		smartcache.ListingBackend
+		smartcache.PurgeableBackend
+	}{
+		"plain":     plain,
+		"weighted":  weighted,
+		"freshness": freshness,
+	}
+	for name, backend := range backends {
+		backend := backend
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			set := func(key string) {
+				require.NoError(t, backend.Set(ctx, key, time.Minute, &smartcache.CacheEntry[string]{
+					Data:    ptr(key),
+					Created: time.Now(),
+				}))
+			}
+			set("key1")
+			set("key2")
+
+			require.NoError(t, backend.Purge(ctx))
+			n, err := backend.Len(ctx)
+			require.NoError(t, err)
+			assert.Zero(t, n)
+			entry, err := backend.Get(ctx, "key1")
+			require.NoError(t, err)
+			assert.Nil(t, entry)
+
+			// The backend is usable after the purge.
+			set("key3")
+			entry, err = backend.Get(ctx, "key3")
+			require.NoError(t, err)
+			assert.NotNil(t, entry)
+		})
+	}
+}
+
+func TestBackend_Resize(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	backend, err := lru.NewBackend[string](3)
+	require.NoError(t, err)
+
+	set := func(key string) {
+		require.NoError(t, backend.Set(ctx, key, time.Minute, &smartcache.CacheEntry[string]{
+			Data:    ptr(key),
+			Created: time.Now(),
+		}))
+	}
+	present := func(key string) bool {
+		entry, err := backend.Get(ctx, key)
+		require.NoError(t, err)
+		return entry != nil
+	}
+
+	set("key1")
+	set("key2")
+	set("key3")
+
+	// Growing keeps all entries and makes room for more.
+	assert.Zero(t, backend.Resize(5))
+	assert.Equal(t, 5, backend.Cap())
+	set("key4")
+	set("key5")
+	for _, key := range []string{"key1", "key2", "key3", "key4", "key5"} {
+		assert.True(t, present(key), key)
+	}
+
+	// Shrinking evicts the least recently used entries.
+	assert.True(t, present("key1"))
+	assert.Equal(t, 3, backend.Resize(2))
+	assert.Equal(t, 2, backend.Cap())
+	assert.True(t, present("key1"))
+	assert.True(t, present("key5"))
+	for _, key := range []string{"key2", "key3", "key4"} {
+		assert.False(t, present(key), key)
+	}
+}
+
+func TestBackend_OnEvict(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	_, err := lru.NewBackendWithEvict[string](3, nil)
+	require.Error(t, err)
+
+	var evicted []string
+	backend, err := lru.NewBackendWithEvict[string](3, func(key string) {
+		evicted = append(evicted, key)
+	})
+	require.NoError(t, err)
+
+	for i := 1; i <= 5; i++ {
+		key := fmt.Sprintf("key%d", i)
+		require.NoError(t, backend.Set(ctx, key, time.Minute, &smartcache.CacheEntry[string]{
+			Data:    ptr(key),
+			Created: time.Now(),
+		}))
+	}
+	assert.Equal(t, []string{"key1", "key2"}, evicted)
+
+	// Shrinking and deleting remove entries too.
+	assert.Equal(t, 1, backend.Resize(2))
+	assert.Equal(t, []string{"key1", "key2", "key3"}, evicted)
+	require.NoError(t, backend.Delete(ctx, "key4"))
+	assert.Equal(t, []string{"key1", "key2", "key3", "key4"}, evicted)
+}
@@ -0,0 +1,274 @@
+package lru
+
+import (
+	"container/heap"
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/m-zajac/smartcache"
+)
+
+// FreshnessBackend for cache that stores data in-memory using LRU cache, which prefers evicting stale entries.
+// When the cache is full, the entry that has been stale the longest is evicted, even if it was used recently.
+// Only if none of the entries is stale, the least recently used one is evicted.
+//
+// Entries are stale past the primary TTL of the cache, or past their fixed expiration if they have one.
+type FreshnessBackend[T any] struct {
+	size       int
+	primaryTTL time.Duration
+	clock      smartcache.Clock
+
+	mu    sync.Mutex
+	order *list.List
+	stale staleHeap[T]
+	items map[string]*freshnessItem[T]
+}
+
+type freshnessItem[T any] struct {
+	key     string
+	entry   *smartcache.CacheEntry[T]
+	staleAt time.Time
+	element *list.Element
+	index   int
+}
+
+var (
+	_ smartcache.Backend[string]              = &FreshnessBackend[string]{}
+	_ smartcache.MultiGetBackend[string]      = &FreshnessBackend[string]{}
+	_ smartcache.PurgingBackend[string]       = &FreshnessBackend[string]{}
+	_ smartcache.ListingBackend               = &FreshnessBackend[string]{}
+	_ smartcache.PurgeableBackend             = &FreshnessBackend[string]{}
+	_ smartcache.CapacityBackend              = &FreshnessBackend[string]{}
+	_ smartcache.LocalEvictingBackend[string] = &FreshnessBackend[string]{}
+)
+
+// FreshnessOption configures the `FreshnessBackend`.
+type FreshnessOption func(*freshnessConfig) error
+
+type freshnessConfig struct {
+	clock smartcache.Clock
+}
+
+// WithClock sets the clock used to tell which entries are stale. It should be the same as the cache's,
+// see `smartcache.WithClock`. Defaults to the system clock.
+func WithClock(clock smartcache.Clock) FreshnessOption {
+	return func(c *freshnessConfig) error {
+		if clock == nil {
+			return errors.New("clock is nil")
+		}
+		c.clock = clock
+		return nil
+	}
+}
+
+// NewFreshnessBackend creates the backend for the given number of entries.
+// The primary TTL should be the same as the cache's.
+func NewFreshnessBackend[T any](size uint, primaryTTL time.Duration, opts ...FreshnessOption) (*FreshnessBackend[T], error) {
+	if size == 0 {
+		return nil, errors.New("size has to be > 0")
+	}
+	if primaryTTL <= 0 {
+		return nil, errors.New("primary ttl has to be > 0")
+	}
+
+	cfg := freshnessConfig{
+		clock: systemClock{},
+	}
+	for _, opt := range opts {
+		if err := opt(&cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	return &FreshnessBackend[T]{
+		size:       int(size),
+		primaryTTL: primaryTTL,
+		clock:      cfg.clock,
+		order:      list.New(),
+		items:      make(map[string]*freshnessItem[T]),
+	}, nil
+}
+
+func (b *FreshnessBackend[T]) Get(ctx context.Context, key string) (*smartcache.CacheEntry[T], error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.get(key), nil
+}
+
+func (b *FreshnessBackend[T]) GetMulti(ctx context.Context, keys []string) (map[string]*smartcache.CacheEntry[T], error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	entries := make(map[string]*smartcache.CacheEntry[T], len(keys))
+	for _, key := range keys {
+		if entry := b.get(key); entry != nil {
+			entries[key] = entry
+		}
+	}
+
+	return entries, nil
+}
+
+func (b *FreshnessBackend[T]) Set(ctx context.Context, key string, ttl time.Duration, data *smartcache.CacheEntry[T]) error {
+	staleAt := data.Created.Add(b.primaryTTL)
+	if data.FixedExpiration != nil {
+		staleAt = *data.FixedExpiration
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.remove(key)
+
+	it := &freshnessItem[T]{key: key, entry: data, staleAt: staleAt}
+	it.element = b.order.PushFront(it)
+	heap.Push(&b.stale, it)
+	b.items[key] = it
+
+	if len(b.items) > b.size {
+		b.evict(b.clock.Now())
+	}
+
+	return nil
+}
+
+func (b *FreshnessBackend[T]) Delete(ctx context.Context, key string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.remove(key)
+
+	return nil
+}
+
+// EvictLocal removes the entry like Delete, as all entries are local.
+func (b *FreshnessBackend[T]) EvictLocal(ctx context.Context, key string) error {
+	return b.Delete(ctx, key)
+}
+
+// PurgeExpired removes entries for which the expired function returns true.
+func (b *FreshnessBackend[T]) PurgeExpired(ctx context.Context, expired func(key string, entry *smartcache.CacheEntry[T]) bool) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var purged int
+	for key, it := range b.items {
+		if expired(key, it.entry) {
+			b.remove(key)
+			purged++
+		}
+	}
+
+	return purged, nil
+}
+
+// Len returns the number of stored keys.
+func (b *FreshnessBackend[T]) Len(ctx context.Context) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return len(b.items), nil
+}
+
+// Cap returns the maximum number of stored keys, the size of the backend.
+func (b *FreshnessBackend[T]) Cap() int {
+	return b.size
+}
+
+// Keys returns the stored keys, in no particular order.
+func (b *FreshnessBackend[T]) Keys(ctx context.Context) ([]string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	keys := make([]string, 0, len(b.items))
+	for key := range b.items {
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// Purge removes all entries.
+func (b *FreshnessBackend[T]) Purge(ctx context.Context) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.order.Init()
+	b.stale = nil
+	b.items = make(map[string]*freshnessItem[T])
+
+	return nil
+}
+
+func (b *FreshnessBackend[T]) Close() {}
+
+func (b *FreshnessBackend[T]) get(key string) *smartcache.CacheEntry[T] {
+	it, ok := b.items[key]
+	if !ok {
+		return nil
+	}
+	b.order.MoveToFront(it.element)
+
+	return it.entry
+}
+
+// evict removes the stalest entry if it's stale, or the least recently used one otherwise.
+func (b *FreshnessBackend[T]) evict(now time.Time) {
+	if stalest := b.stale[0]; stalest.staleAt.Before(now) {
+		b.remove(stalest.key)
+		return
+	}
+
+	b.remove(b.order.Back().Value.(*freshnessItem[T]).key)
+}
+
+func (b *FreshnessBackend[T]) remove(key string) {
+	it, ok := b.items[key]
+	if !ok {
+		return
+	}
+
+	b.order.Remove(it.element)
+	heap.Remove(&b.stale, it.index)
+	delete(b.items, key)
+}
+
+// systemClock is the default clock of the `FreshnessBackend`.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// staleHeap orders items by the time they become stale, see `container/heap`.
+type staleHeap[T any] []*freshnessItem[T]
+
+func (h staleHeap[T]) Len() int { return len(h) }
+
+func (h staleHeap[T]) Less(i, j int) bool { return h[i].staleAt.Before(h[j].staleAt) }
+
+func (h staleHeap[T]) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *staleHeap[T]) Push(x any) {
+	it := x.(*freshnessItem[T])
+	it.index = len(*h)
+	*h = append(*h, it)
+}
+
+func (h *staleHeap[T]) Pop() any {
+	old := *h
+	n := len(old)
+	it := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+
+	return it
+}
@@ -0,0 +1,128 @@
+package lru_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/m-zajac/smartcache"
+	"github.com/m-zajac/smartcache/backend/lru"
+	"github.com/m-zajac/smartcache/clocktest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFreshnessBackend(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	_, err := lru.NewFreshnessBackend[string](0, time.Minute)
+	require.Error(t, err)
+	_, err = lru.NewFreshnessBackend[string](3, 0)
+	require.Error(t, err)
+
+	const primTTL = time.Minute
+	backend, err := lru.NewFreshnessBackend[string](3, primTTL)
+	require.NoError(t, err)
+	assert.Equal(t, 3, backend.Cap())
+
+	set := func(key string, age time.Duration) {
+		err := backend.Set(ctx, key, time.Hour, &smartcache.CacheEntry[string]{
+			Data:    ptr(key),
+			Created: time.Now().Add(-age),
+		})
+		require.NoError(t, err)
+	}
+	present := func(key string) bool {
+		entry, err := backend.Get(ctx, key)
+		require.NoError(t, err)
+		return entry != nil
+	}
+
+	set("fresh1", 0)
+	set("stale1", primTTL+time.Minute)
+	set("stale2", primTTL+2*time.Minute)
+
+	// Stale entries are evicted first, the stalest one first, even if used recently.
+	assert.True(t, present("stale2"))
+	assert.True(t, present("stale1"))
+	set("fresh2", 0)
+	assert.False(t, present("stale2"))
+	assert.True(t, present("stale1"))
+	assert.True(t, present("fresh1"))
+
+	set("fresh3", 0)
+	assert.False(t, present("stale1"))
+	assert.True(t, present("fresh1"))
+	assert.True(t, present("fresh2"))
+	assert.True(t, present("fresh3"))
+
+	// Without stale entries, the least recently used one is evicted.
+	assert.True(t, present("fresh1"))
+	set("fresh4", 0)
+	assert.False(t, present("fresh2"))
+	assert.True(t, present("fresh1"))
+	assert.True(t, present("fresh3"))
+	assert.True(t, present("fresh4"))
+
+	// Entries past their fixed expiration are stale, so an expired one doesn't push out fresh data.
+	expired := time.Now().Add(-time.Second)
+	require.NoError(t, backend.Set(ctx, "error", time.Hour, &smartcache.CacheEntry[string]{
+		FixedExpiration: &expired,
+	}))
+	assert.False(t, present("error"))
+	assert.True(t, present("fresh1"))
+	assert.True(t, present("fresh3"))
+	assert.True(t, present("fresh4"))
+
+	require.NoError(t, backend.Delete(ctx, "fresh4"))
+	assert.False(t, present("fresh4"))
+
+	entries, err := backend.GetMulti(ctx, []string{"fresh1", "fresh4"})
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestFreshnessBackendClock(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	_, err := lru.NewFreshnessBackend[string](2, time.Minute, lru.WithClock(nil))
+	require.Error(t, err)
+
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := clocktest.New(start)
+	backend, err := lru.NewFreshnessBackend[string](2, time.Minute, lru.WithClock(clock))
+	require.NoError(t, err)
+
+	set := func(key string) {
+		err := backend.Set(ctx, key, time.Hour, &smartcache.CacheEntry[string]{
+			Data:    ptr(key),
+			Created: clock.Now(),
+		})
+		require.NoError(t, err)
+	}
+	present := func(key string) bool {
+		entry, err := backend.Get(ctx, key)
+		require.NoError(t, err)
+		return entry != nil
+	}
+
+	// Entries created by the stopped clock years ago are still fresh, so the least recently used one is evicted.
+	set("old")
+	set("new")
+	assert.True(t, present("old"))
+	clock.Add(30 * time.Second)
+	set("newer")
+	assert.False(t, present("new"))
+	assert.True(t, present("old"))
+
+	// Once the clock moves past the primary TTL, the stalest entry is evicted, even though it was used recently.
+	clock.Add(2 * time.Minute)
+	set("newest")
+	assert.True(t, present("newer"))
+	assert.True(t, present("newest"))
+	assert.False(t, present("old"))
+}
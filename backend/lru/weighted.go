@@ -0,0 +1,195 @@
+package lru
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/m-zajac/smartcache"
+)
+
+// WeightFunc returns the weight of a cache entry, e.g. number of elements of a cached slice.
+type WeightFunc[T any] func(entry *smartcache.CacheEntry[T]) int
+
+// WeightedBackend for cache that stores data in-memory using LRU cache limited by the total weight of entries,
+// instead of their count. It's useful when cached values vary a lot in size.
+//
+// Entries heavier than the max weight are not stored.
+type WeightedBackend[T any] struct {
+	maxWeight int
+	weight    WeightFunc[T]
+
+	mu          sync.Mutex
+	totalWeight int
+	order       *list.List
+	items       map[string]*list.Element
+}
+
+type weightedItem[T any] struct {
+	key    string
+	entry  *smartcache.CacheEntry[T]
+	weight int
+}
+
+var (
+	_ smartcache.Backend[string]              = &WeightedBackend[string]{}
+	_ smartcache.MultiGetBackend[string]      = &WeightedBackend[string]{}
+	_ smartcache.PurgingBackend[string]       = &WeightedBackend[string]{}
+	_ smartcache.ListingBackend               = &WeightedBackend[string]{}
+	_ smartcache.PurgeableBackend             = &WeightedBackend[string]{}
+	_ smartcache.LocalEvictingBackend[string] = &WeightedBackend[string]{}
+)
+
+func NewWeightedBackend[T any](maxWeight int, weight WeightFunc[T]) (*WeightedBackend[T], error) {
+	if maxWeight <= 0 {
+		return nil, errors.New("max weight has to be > 0")
+	}
+	if weight == nil {
+		return nil, errors.New("weight function is nil")
+	}
+
+	return &WeightedBackend[T]{
+		maxWeight: maxWeight,
+		weight:    weight,
+		order:     list.New(),
+		items:     make(map[string]*list.Element),
+	}, nil
+}
+
+func (b *WeightedBackend[T]) Get(ctx context.Context, key string) (*smartcache.CacheEntry[T], error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.get(key), nil
+}
+
+func (b *WeightedBackend[T]) GetMulti(ctx context.Context, keys []string) (map[string]*smartcache.CacheEntry[T], error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	entries := make(map[string]*smartcache.CacheEntry[T], len(keys))
+	for _, key := range keys {
+		if entry := b.get(key); entry != nil {
+			entries[key] = entry
+		}
+	}
+
+	return entries, nil
+}
+
+func (b *WeightedBackend[T]) Set(ctx context.Context, key string, ttl time.Duration, data *smartcache.CacheEntry[T]) error {
+	w := b.weight(data)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.remove(key)
+	if w > b.maxWeight {
+		return nil
+	}
+
+	b.items[key] = b.order.PushFront(&weightedItem[T]{key: key, entry: data, weight: w})
+	b.totalWeight += w
+
+	for b.totalWeight > b.maxWeight {
+		b.remove(b.order.Back().Value.(*weightedItem[T]).key)
+	}
+
+	return nil
+}
+
+func (b *WeightedBackend[T]) Delete(ctx context.Context, key string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.remove(key)
+
+	return nil
+}
+
+// EvictLocal removes the entry like Delete, as all entries are local.
+func (b *WeightedBackend[T]) EvictLocal(ctx context.Context, key string) error {
+	return b.Delete(ctx, key)
+}
+
+// PurgeExpired removes entries for which the expired function returns true.
+func (b *WeightedBackend[T]) PurgeExpired(ctx context.Context, expired func(key string, entry *smartcache.CacheEntry[T]) bool) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var purged int
+	for key, el := range b.items {
+		if expired(key, el.Value.(*weightedItem[T]).entry) {
+			b.remove(key)
+			purged++
+		}
+	}
+
+	return purged, nil
+}
+
+// Len returns the number of stored keys.
+func (b *WeightedBackend[T]) Len(ctx context.Context) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return len(b.items), nil
+}
+
+// Keys returns the stored keys, in no particular order.
+func (b *WeightedBackend[T]) Keys(ctx context.Context) ([]string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	keys := make([]string, 0, len(b.items))
+	for key := range b.items {
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// Purge removes all entries.
+func (b *WeightedBackend[T]) Purge(ctx context.Context) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.order.Init()
+	b.items = make(map[string]*list.Element)
+	b.totalWeight = 0
+
+	return nil
+}
+
+func (b *WeightedBackend[T]) Close() {}
+
+// Weight returns the total weight of stored entries.
+func (b *WeightedBackend[T]) Weight() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.totalWeight
+}
+
+func (b *WeightedBackend[T]) get(key string) *smartcache.CacheEntry[T] {
+	el, ok := b.items[key]
+	if !ok {
+		return nil
+	}
+	b.order.MoveToFront(el)
+
+	return el.Value.(*weightedItem[T]).entry
+}
+
+func (b *WeightedBackend[T]) remove(key string) {
+	el, ok := b.items[key]
+	if !ok {
+		return
+	}
+
+	b.order.Remove(el)
+	delete(b.items, key)
+	b.totalWeight -= el.Value.(*weightedItem[T]).weight
+}
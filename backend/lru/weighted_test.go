@@ -0,0 +1,76 @@
+package lru_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/m-zajac/smartcache"
+	"github.com/m-zajac/smartcache/backend/lru"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWeightedBackend(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	backend, err := lru.NewWeightedBackend[[]int](10, func(entry *smartcache.CacheEntry[[]int]) int {
+		return len(*entry.Data)
+	})
+	require.NoError(t, err)
+
+	set := func(key string, size int) {
+		data := make([]int, size)
+		err := backend.Set(ctx, key, time.Minute, &smartcache.CacheEntry[[]int]{
+			Data:    &data,
+			Created: time.Now(),
+		})
+		require.NoError(t, err)
+	}
+	present := func(key string) bool {
+		entry, err := backend.Get(ctx, key)
+		require.NoError(t, err)
+		return entry != nil
+	}
+
+	set("a", 3)
+	set("b", 3)
+	set("c", 3)
+	assert.Equal(t, 9, backend.Weight())
+
+	// Touch "a", so "b" is the least recently used.
+	assert.True(t, present("a"))
+
+	// Exceeds the cap, "b" should be evicted.
+	set("d", 2)
+	assert.Equal(t, 8, backend.Weight())
+	assert.False(t, present("b"))
+	assert.True(t, present("a"))
+	assert.True(t, present("c"))
+	assert.True(t, present("d"))
+
+	// A heavy entry evicts several light ones.
+	set("e", 9)
+	assert.Equal(t, 9, backend.Weight())
+	assert.True(t, present("e"))
+	assert.False(t, present("a"))
+	assert.False(t, present("c"))
+	assert.False(t, present("d"))
+
+	// Replacing an entry updates the weight.
+	set("e", 1)
+	assert.Equal(t, 1, backend.Weight())
+
+	// Entries heavier than the cap are not stored.
+	set("f", 11)
+	assert.False(t, present("f"))
+	assert.Equal(t, 1, backend.Weight())
+
+	// Deleting frees the weight.
+	require.NoError(t, backend.Delete(ctx, "e"))
+	assert.Equal(t, 0, backend.Weight())
+	assert.False(t, present("e"))
+	require.NoError(t, backend.Delete(ctx, "e"))
+}
@@ -0,0 +1,178 @@
+package memcached
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/m-zajac/smartcache"
+)
+
+// maxTTL is the longest relative expiration accepted by memcached. Longer values are treated as unix timestamps.
+const maxTTL = 30 * 24 * time.Hour
+
+// Backend for cache that stores data in memcached.
+//
+// The data is serialized to JSON. Note that the T type data has to be properly JSON-serializable!
+//
+// The error stored in cache entries will be stored as a string. That means that it's type be lost,
+// and after retrieval it will be a plain new go error.
+//
+// TTLs are rounded up to full seconds and capped at 30 days.
+//
+// The client will be closed when the parent cache is closed.
+type Backend[T any] struct {
+	client    *memcache.Client
+	keyPrefix string
+}
+
+var (
+	_ smartcache.Backend[string]         = &Backend[string]{}
+	_ smartcache.MultiGetBackend[string] = &Backend[string]{}
+)
+
+func NewBackend[T any](client *memcache.Client, keyPrefix string) (*Backend[T], error) {
+	if client == nil {
+		return nil, errors.New("memcache client is nil")
+	}
+
+	return &Backend[T]{
+		client:    client,
+		keyPrefix: keyPrefix,
+	}, nil
+}
+
+func (b *Backend[T]) Get(ctx context.Context, key string) (*smartcache.CacheEntry[T], error) {
+	item, err := b.client.Get(b.keyPrefix + key)
+	if err != nil {
+		if errors.Is(err, memcache.ErrCacheMiss) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("fetching data from memcached: %w", err)
+	}
+
+	return b.deserialize(item.Value)
+}
+
+func (b *Backend[T]) GetMulti(ctx context.Context, keys []string) (map[string]*smartcache.CacheEntry[T], error) {
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = b.keyPrefix + key
+	}
+
+	items, err := b.client.GetMulti(prefixed)
+	if err != nil {
+		return nil, fmt.Errorf("fetching data from memcached: %w", err)
+	}
+
+	entries := make(map[string]*smartcache.CacheEntry[T], len(items))
+	for i, key := range keys {
+		item, ok := items[prefixed[i]]
+		if !ok {
+			continue
+		}
+		entry, err := b.deserialize(item.Value)
+		if err != nil {
+			return nil, err
+		}
+		entries[key] = entry
+	}
+
+	return entries, nil
+}
+
+func (b *Backend[T]) Set(ctx context.Context, key string, ttl time.Duration, entry *smartcache.CacheEntry[T]) error {
+	data, err := b.serialize(entry)
+	if err != nil {
+		return err
+	}
+
+	err = b.client.Set(&memcache.Item{
+		Key:        b.keyPrefix + key,
+		Value:      data,
+		Expiration: expiration(ttl),
+	})
+	if err != nil {
+		return fmt.Errorf("storing data in memcached: %w", err)
+	}
+
+	return nil
+}
+
+func (b *Backend[T]) Delete(ctx context.Context, key string) error {
+	if err := b.client.Delete(b.keyPrefix + key); err != nil && !errors.Is(err, memcache.ErrCacheMiss) {
+		return fmt.Errorf("deleting data from memcached: %w", err)
+	}
+
+	return nil
+}
+
+func (b *Backend[T]) Close() {
+	_ = b.client.Close()
+}
+
+// expiration converts the ttl to memcached expiration in seconds. Zero means no expiration.
+func expiration(ttl time.Duration) int32 {
+	if ttl <= 0 {
+		return 0
+	}
+	if ttl > maxTTL {
+		ttl = maxTTL
+	}
+
+	return int32((ttl + time.Second - 1) / time.Second)
+}
+
+type container[T any] struct {
+	Data            *T         `json:"data"`
+	Err             string     `json:"err"`
+	Created         time.Time  `json:"created"`
+	FixedExpiration *time.Time `json:"fixedExpiration,omitempty"`
+	Epoch           uint64     `json:"epoch,omitempty"`
+	SchemaVersion   string     `json:"schemaVersion,omitempty"`
+}
+
+func (b *Backend[T]) serialize(entry *smartcache.CacheEntry[T]) ([]byte, error) {
+	errStr := ""
+	if entry.Err != nil {
+		errStr = entry.Err.Error()
+	}
+	v, err := json.Marshal(container[T]{
+		Data:            entry.Data,
+		Err:             errStr,
+		Created:         entry.Created,
+		FixedExpiration: entry.FixedExpiration,
+		Epoch:           entry.Epoch,
+		SchemaVersion:   entry.SchemaVersion,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("serializing to json: %w", err)
+	}
+
+	return v, nil
+}
+
+func (b *Backend[T]) deserialize(data []byte) (*smartcache.CacheEntry[T], error) {
+	var c container[T]
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("deserializing json: %w", err)
+	}
+
+	var err error
+	if c.Err != "" {
+		err = errors.New(c.Err)
+	}
+
+	return &smartcache.CacheEntry[T]{
+		Data:            c.Data,
+		Err:             err,
+		Created:         c.Created,
+		FixedExpiration: c.FixedExpiration,
+		Epoch:           c.Epoch,
+		SchemaVersion:   c.SchemaVersion,
+	}, nil
+}
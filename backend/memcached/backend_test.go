@@ -0,0 +1,181 @@
+package memcached_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/m-zajac/smartcache"
+	memcachedbackend "github.com/m-zajac/smartcache/backend/memcached"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackend(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s, addr := runFakeServer(t)
+
+	backend, err := memcachedbackend.NewBackend[string](memcache.New(addr), "testprefix")
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		entry          smartcache.CacheEntry[string]
+		ttl            time.Duration
+		wantExpiration int32
+	}{
+		{
+			name: "simple",
+			entry: smartcache.CacheEntry[string]{
+				Data:    ptr("testvalue"),
+				Created: time.Now().Add(-time.Minute),
+			},
+			ttl:            time.Minute,
+			wantExpiration: 60,
+		},
+		{
+			name: "ttl rounded up",
+			entry: smartcache.CacheEntry[string]{
+				Data:    ptr("testvalue"),
+				Created: time.Now().Add(-time.Minute),
+			},
+			ttl:            time.Millisecond,
+			wantExpiration: 1,
+		},
+		{
+			name: "ttl capped",
+			entry: smartcache.CacheEntry[string]{
+				Data:    ptr("testvalue"),
+				Created: time.Now().Add(-time.Minute),
+			},
+			ttl:            365 * 24 * time.Hour,
+			wantExpiration: 30 * 24 * 60 * 60,
+		},
+		{
+			name: "with error",
+			entry: smartcache.CacheEntry[string]{
+				Created: time.Now().Add(-time.Minute),
+				Err:     errors.New("test error"),
+			},
+			ttl:            time.Minute,
+			wantExpiration: 60,
+		},
+		{
+			name: "with fixed expiration",
+			entry: smartcache.CacheEntry[string]{
+				Data:            ptr("testvalue"),
+				Created:         time.Now().Add(-time.Minute),
+				FixedExpiration: ptr(time.Now().Add(time.Hour)),
+			},
+			ttl:            time.Minute,
+			wantExpiration: 60,
+		},
+		{
+			name: "with epoch and schema version",
+			entry: smartcache.CacheEntry[string]{
+				Data:          ptr("testvalue"),
+				Created:       time.Now().Add(-time.Minute),
+				Epoch:         3,
+				SchemaVersion: "v2",
+			},
+			ttl:            time.Minute,
+			wantExpiration: 60,
+		},
+	}
+	for i, tt := range tests {
+		tt := tt
+		key := "test" + strconv.Itoa(i)
+		t.Run(tt.name, func(t *testing.T) {
+			err := backend.Set(ctx, key, tt.ttl, &tt.entry)
+			assert.NoError(t, err)
+
+			stored, ok := s.get("testprefix" + key)
+			if assert.True(t, ok) {
+				assert.Equal(t, tt.wantExpiration, stored.expiration)
+			}
+
+			gotEntry, err := backend.Get(ctx, key)
+			assert.NoError(t, err)
+			if assert.NotNil(t, gotEntry) {
+				assert.Equal(t, tt.entry.Created.Unix(), gotEntry.Created.Unix())
+				assert.Equal(t, tt.entry.Data, gotEntry.Data)
+				assert.Equal(t, tt.entry.Err, gotEntry.Err)
+				assert.Equal(t, tt.entry.Epoch, gotEntry.Epoch)
+				assert.Equal(t, tt.entry.SchemaVersion, gotEntry.SchemaVersion)
+				if tt.entry.FixedExpiration == nil {
+					assert.Nil(t, gotEntry.FixedExpiration)
+				} else {
+					assert.Equal(t, tt.entry.FixedExpiration.Unix(), gotEntry.FixedExpiration.Unix())
+				}
+			}
+		})
+	}
+
+	// Missing keys are not an error.
+	gotEntry, err := backend.Get(ctx, "missing")
+	assert.NoError(t, err)
+	assert.Nil(t, gotEntry)
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestBackend_GetMulti(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	_, addr := runFakeServer(t)
+
+	backend, err := memcachedbackend.NewBackend[string](memcache.New(addr), "testprefix")
+	assert.NoError(t, err)
+
+	created := time.Now().Add(-time.Minute)
+	for _, key := range []string{"key1", "key2"} {
+		err = backend.Set(ctx, key, time.Minute, &smartcache.CacheEntry[string]{
+			Data:    ptr("value " + key),
+			Created: created,
+		})
+		assert.NoError(t, err)
+	}
+
+	entries, err := backend.GetMulti(ctx, []string{"key1", "missing", "key2"})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	for _, key := range []string{"key1", "key2"} {
+		if assert.Contains(t, entries, key) {
+			assert.Equal(t, "value "+key, *entries[key].Data)
+			assert.Equal(t, created.Unix(), entries[key].Created.Unix())
+		}
+	}
+}
+
+func TestBackend_Delete(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s, addr := runFakeServer(t)
+
+	backend, err := memcachedbackend.NewBackend[string](memcache.New(addr), "testprefix")
+	assert.NoError(t, err)
+
+	entry := &smartcache.CacheEntry[string]{
+		Data:    ptr("testvalue"),
+		Created: time.Now(),
+	}
+	assert.NoError(t, backend.Set(ctx, "key", time.Hour, entry))
+	assert.NoError(t, backend.Delete(ctx, "key"))
+	_, ok := s.get("testprefixkey")
+	assert.False(t, ok)
+
+	got, err := backend.Get(ctx, "key")
+	assert.NoError(t, err)
+	assert.Nil(t, got)
+
+	// Deleting a missing key is fine.
+	assert.NoError(t, backend.Delete(ctx, "key"))
+}
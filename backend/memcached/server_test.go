@@ -0,0 +1,112 @@
+package memcached_test
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeServer is a minimal in-memory memcached server, supporting commands used by the backend.
+// Items never expire, their expiration is only recorded.
+type fakeServer struct {
+	mu    sync.Mutex
+	items map[string]fakeItem
+}
+
+type fakeItem struct {
+	data       []byte
+	expiration int32
+}
+
+func runFakeServer(t *testing.T) (*fakeServer, string) {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listening: %v", err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+
+	s := &fakeServer{items: make(map[string]fakeItem)}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+
+	return s, l.Addr().String()
+}
+
+func (s *fakeServer) get(key string) (fakeItem, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, ok := s.items[key]
+	return item, ok
+}
+
+func (s *fakeServer) set(key string, item fakeItem) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.items[key] = item
+}
+
+func (s *fakeServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	rw := bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+	for {
+		line, err := rw.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			return
+		}
+
+		switch fields[0] {
+		case "get", "gets":
+			for _, key := range fields[1:] {
+				if item, ok := s.get(key); ok {
+					fmt.Fprintf(rw, "VALUE %s 0 %d 1\r\n%s\r\n", key, len(item.data), item.data)
+				}
+			}
+			fmt.Fprint(rw, "END\r\n")
+		case "set":
+			expiration, _ := strconv.ParseInt(fields[3], 10, 32)
+			size, _ := strconv.Atoi(fields[4])
+			data := make([]byte, size+2)
+			if _, err := io.ReadFull(rw, data); err != nil {
+				return
+			}
+			s.set(fields[1], fakeItem{data: data[:size], expiration: int32(expiration)})
+			fmt.Fprint(rw, "STORED\r\n")
+		case "delete":
+			s.mu.Lock()
+			_, ok := s.items[fields[1]]
+			delete(s.items, fields[1])
+			s.mu.Unlock()
+			if ok {
+				fmt.Fprint(rw, "DELETED\r\n")
+			} else {
+				fmt.Fprint(rw, "NOT_FOUND\r\n")
+			}
+		default:
+			fmt.Fprint(rw, "ERROR\r\n")
+		}
+		if err := rw.Flush(); err != nil {
+			return
+		}
+	}
+}
@@ -2,9 +2,11 @@ package redis
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
+	"hash/crc32"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/m-zajac/smartcache"
@@ -13,7 +15,8 @@ import (
 
 // Backend for cache that stores data in redis.
 //
-// The data is serialized to JSON and stored as a JSON string. Note that the T type data has to be properly JSON-serializable!
+// By default the data is serialized to JSON and stored as a JSON string. Note that the T type data has to be properly JSON-serializable!
+// Other serialization formats can be used with `WithCodec`.
 //
 // The error stored in cache entries will be stored as a string. That means that it's type be lost,
 // and after retrieval it will be a plain new go error.
@@ -22,36 +25,208 @@ import (
 type Backend[T any] struct {
 	client    *redis.Client
 	keyPrefix string
+
+	codec      Codec[T]
+	compressor Compressor
+
+	fallbackPrefix string
+	fallbackDecode func([]byte) (*smartcache.CacheEntry[T], error)
+
+	checksum           bool
+	onChecksumMismatch func(error)
 }
 
-var _ smartcache.Backend[string] = &Backend[string]{}
+// ErrChecksumMismatch is reported when a stored entry doesn't match its checksum.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+var (
+	_ smartcache.Backend[string]                = &Backend[string]{}
+	_ smartcache.MultiGetBackend[string]        = &Backend[string]{}
+	_ smartcache.TTLAwareBackend[string]        = &Backend[string]{}
+	_ smartcache.MultiGetWithTTLBackend[string] = &Backend[string]{}
+	_ smartcache.RawBackend                     = &Backend[string]{}
+	_ smartcache.ListingBackend                 = &Backend[string]{}
+	_ smartcache.PurgeableBackend               = &Backend[string]{}
+)
 
-func NewBackend[T any](client *redis.Client, keyPrefix string) (*Backend[T], error) {
+func NewBackend[T any](client *redis.Client, keyPrefix string, options ...Option[T]) (*Backend[T], error) {
 	if client == nil {
 		return nil, errors.New("redis client is nil")
 	}
 
-	return &Backend[T]{
+	b := &Backend[T]{
 		client:    client,
 		keyPrefix: keyPrefix,
-	}, nil
+		codec:     JSONCodec[T]{},
+	}
+	for _, o := range options {
+		if err := o(b); err != nil {
+			return nil, fmt.Errorf("invalid config: %w", err)
+		}
+	}
+
+	return b, nil
 }
 
 func (b *Backend[T]) Get(ctx context.Context, key string) (*smartcache.CacheEntry[T], error) {
 	data, err := b.client.Get(ctx, b.keyPrefix+key).Result()
 	if err != nil {
 		if errors.Is(err, redis.Nil) {
-			return nil, nil
+			return b.getFallback(ctx, key)
+		}
+
+		return nil, fmt.Errorf("fetching data from redis: %w", err)
+	}
+
+	return b.decode(key, []byte(data))
+}
+
+// GetWithTTL returns the entry along with its remaining TTL in redis.
+// The TTL is negative for entries without expiration and for entries read with the fallback prefix.
+func (b *Backend[T]) GetWithTTL(ctx context.Context, key string) (*smartcache.CacheEntry[T], time.Duration, error) {
+	var getCmd *redis.StringCmd
+	var ttlCmd *redis.DurationCmd
+	_, err := b.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		getCmd = pipe.Get(ctx, b.keyPrefix+key)
+		ttlCmd = pipe.PTTL(ctx, b.keyPrefix+key)
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, 0, fmt.Errorf("fetching data from redis: %w", err)
+	}
+
+	data, err := getCmd.Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			entry, err := b.getFallback(ctx, key)
+			return entry, -1, err
+		}
+
+		return nil, 0, fmt.Errorf("fetching data from redis: %w", err)
+	}
+
+	entry, err := b.decode(key, []byte(data))
+	if err != nil || entry == nil {
+		return entry, -1, err
+	}
+
+	return entry, ttlCmd.Val(), nil
+}
+
+// GetRaw returns the serialized entry, as stored in redis.
+func (b *Backend[T]) GetRaw(ctx context.Context, key string) ([]byte, bool, error) {
+	data, err := b.client.Get(ctx, b.keyPrefix+key).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, false, nil
 		}
 
+		return nil, false, fmt.Errorf("fetching data from redis: %w", err)
+	}
+
+	return data, true, nil
+}
+
+func (b *Backend[T]) GetMulti(ctx context.Context, keys []string) (map[string]*smartcache.CacheEntry[T], error) {
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = b.keyPrefix + key
+	}
+
+	values, err := b.client.MGet(ctx, prefixed...).Result()
+	if err != nil {
 		return nil, fmt.Errorf("fetching data from redis: %w", err)
 	}
 
-	return b.deserialize([]byte(data))
+	entries, _, err := b.decodeMulti(ctx, keys, values)
+
+	return entries, err
+}
+
+// GetMultiWithTTL works like GetMulti, and returns the remaining TTLs in redis as well, like GetWithTTL.
+// TTLs of entries read with the fallback prefix are not returned.
+func (b *Backend[T]) GetMultiWithTTL(ctx context.Context, keys []string) (map[string]*smartcache.CacheEntry[T], map[string]time.Duration, error) {
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = b.keyPrefix + key
+	}
+
+	var mgetCmd *redis.SliceCmd
+	ttlCmds := make([]*redis.DurationCmd, len(keys))
+	_, err := b.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		mgetCmd = pipe.MGet(ctx, prefixed...)
+		for i, key := range prefixed {
+			ttlCmds[i] = pipe.PTTL(ctx, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("fetching data from redis: %w", err)
+	}
+
+	entries, stored, err := b.decodeMulti(ctx, keys, mgetCmd.Val())
+	if err != nil {
+		return nil, nil, err
+	}
+	ttls := make(map[string]time.Duration, len(entries))
+	for i, key := range keys {
+		if stored[i] && entries[key] != nil {
+			ttls[key] = ttlCmds[i].Val()
+		}
+	}
+
+	return entries, ttls, nil
+}
+
+// decodeMulti decodes the values read with MGET for the keys, reading missing ones with the fallback prefix.
+// Stored reports the keys decoded from the values.
+func (b *Backend[T]) decodeMulti(ctx context.Context, keys []string, values []any) (entries map[string]*smartcache.CacheEntry[T], stored []bool, err error) {
+	entries = make(map[string]*smartcache.CacheEntry[T], len(keys))
+	stored = make([]bool, len(keys))
+	for i, v := range values {
+		var entry *smartcache.CacheEntry[T]
+		if data, ok := v.(string); ok {
+			entry, err = b.decode(keys[i], []byte(data))
+			stored[i] = true
+		} else {
+			entry, err = b.getFallback(ctx, keys[i])
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if entry != nil {
+			entries[keys[i]] = entry
+		}
+	}
+
+	return entries, stored, nil
+}
+
+// getFallback reads the entry under the fallback prefix, if configured.
+func (b *Backend[T]) getFallback(ctx context.Context, key string) (*smartcache.CacheEntry[T], error) {
+	if b.fallbackDecode == nil {
+		return nil, nil
+	}
+
+	data, err := b.client.Get(ctx, b.fallbackPrefix+key).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("fetching fallback data from redis: %w", err)
+	}
+
+	entry, err := b.fallbackDecode(data)
+	if err != nil {
+		return nil, fmt.Errorf("decoding fallback data: %w", err)
+	}
+
+	return entry, nil
 }
 
 func (b *Backend[T]) Set(ctx context.Context, key string, ttl time.Duration, entry *smartcache.CacheEntry[T]) error {
-	data, err := b.serialize(entry)
+	data, err := b.encode(entry)
 	if err != nil {
 		return err
 	}
@@ -61,50 +236,153 @@ func (b *Backend[T]) Set(ctx context.Context, key string, ttl time.Duration, ent
 	return cmd.Err()
 }
 
+func (b *Backend[T]) Delete(ctx context.Context, key string) error {
+	if err := b.client.Del(ctx, b.keyPrefix+key).Err(); err != nil {
+		return fmt.Errorf("deleting data from redis: %w", err)
+	}
+
+	return nil
+}
+
+// Len returns the number of keys with the backend's prefix. The keys are scanned, see `Keys`.
+func (b *Backend[T]) Len(ctx context.Context) (int, error) {
+	keys, err := b.Keys(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(keys), nil
+}
+
+// Keys returns the keys with the backend's prefix, without the prefix. Keys are read with SCAN,
+// so redis is not blocked, but keys added or removed during the scan may be missed.
+// Keys under the fallback prefix are not included.
+func (b *Backend[T]) Keys(ctx context.Context) ([]string, error) {
+	var keys []string
+	seen := make(map[string]struct{})
+	iter := b.client.Scan(ctx, 0, escapePattern(b.keyPrefix)+"*", scanCount).Iterator()
+	for iter.Next(ctx) {
+		// SCAN may return a key more than once.
+		key := strings.TrimPrefix(iter.Val(), b.keyPrefix)
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		keys = append(keys, key)
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("scanning keys in redis: %w", err)
+	}
+
+	return keys, nil
+}
+
+// scanCount is the number of keys requested per SCAN call.
+const scanCount = 1000
+
+// escapePattern escapes special characters of redis glob-style patterns.
+func escapePattern(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			sb.WriteByte('\\')
+		}
+		sb.WriteRune(r)
+	}
+
+	return sb.String()
+}
+
+// Purge removes all keys with the backend's prefix. Keys are found with SCAN, see `Keys`,
+// and deleted in batches. Other data in the database is kept, including keys under the fallback prefix.
+func (b *Backend[T]) Purge(ctx context.Context) error {
+	batch := make([]string, 0, scanCount)
+	iter := b.client.Scan(ctx, 0, escapePattern(b.keyPrefix)+"*", scanCount).Iterator()
+	for iter.Next(ctx) {
+		batch = append(batch, iter.Val())
+		if len(batch) < scanCount {
+			continue
+		}
+		if err := b.client.Del(ctx, batch...).Err(); err != nil {
+			return fmt.Errorf("deleting data from redis: %w", err)
+		}
+		batch = batch[:0]
+	}
+	if err := iter.Err(); err != nil {
+		return fmt.Errorf("scanning keys in redis: %w", err)
+	}
+	if len(batch) > 0 {
+		if err := b.client.Del(ctx, batch...).Err(); err != nil {
+			return fmt.Errorf("deleting data from redis: %w", err)
+		}
+	}
+
+	return nil
+}
+
 func (b *Backend[T]) Close() {
 	_ = b.client.Close()
 }
 
-type container[T any] struct {
-	Data            *T         `json:"data"`
-	Err             string     `json:"err"`
-	Created         time.Time  `json:"created"`
-	FixedExpiration *time.Time `json:"fixedExpiration,omitempty"`
+// encode serializes the entry, compresses it and prepends the checksum, if enabled.
+func (b *Backend[T]) encode(entry *smartcache.CacheEntry[T]) ([]byte, error) {
+	data, err := b.codec.Marshal(entry)
+	if err != nil {
+		return nil, err
+	}
+	if b.compressor != nil {
+		if data, err = compress(b.compressor, data); err != nil {
+			return nil, err
+		}
+	}
+	if !b.checksum {
+		return data, nil
+	}
+
+	sealed := make([]byte, 0, checksumLen+len(data))
+	sealed = append(sealed, fmt.Sprintf("%08x:", crc32.ChecksumIEEE(data))...)
+
+	return append(sealed, data...), nil
 }
 
-func (b *Backend[T]) serialize(entry *smartcache.CacheEntry[T]) ([]byte, error) {
-	errStr := ""
-	if entry.Err != nil {
-		errStr = entry.Err.Error()
+// decode verifies the checksum, if enabled, decompresses the data, if compressed, and deserializes the entry.
+// An entry that fails the verification is reported and treated as missing.
+func (b *Backend[T]) decode(key string, data []byte) (*smartcache.CacheEntry[T], error) {
+	if b.checksum {
+		payload, ok := verifyChecksum(data)
+		if !ok {
+			if b.onChecksumMismatch != nil {
+				b.onChecksumMismatch(fmt.Errorf("reading key '%s': %w", key, ErrChecksumMismatch))
+			}
+			return nil, nil
+		}
+		data = payload
 	}
-	v, err := json.Marshal(container[T]{
-		Data:            entry.Data,
-		Err:             errStr,
-		Created:         entry.Created,
-		FixedExpiration: entry.FixedExpiration,
-	})
-	if err != nil {
-		return nil, fmt.Errorf("serializing to json: %w", err)
+	if isCompressed(data) {
+		var err error
+		if data, err = decompress(b.compressor, data); err != nil {
+			return nil, fmt.Errorf("reading key '%s': %w", key, err)
+		}
 	}
 
-	return v, nil
+	return b.codec.Unmarshal(data)
 }
 
-func (b *Backend[T]) deserialize(data []byte) (*smartcache.CacheEntry[T], error) {
-	var c container[T]
-	if err := json.Unmarshal(data, &c); err != nil {
-		return nil, fmt.Errorf("deserializing json: %w", err)
+// checksumLen is the length of the checksum prefix: 8 hex digits and a separator.
+const checksumLen = 9
+
+// verifyChecksum returns the payload of the checksummed data and whether it matches the checksum.
+func verifyChecksum(data []byte) ([]byte, bool) {
+	if len(data) < checksumLen || data[checksumLen-1] != ':' {
+		return nil, false
 	}
 
-	var err error
-	if c.Err != "" {
-		err = errors.New(c.Err)
+	sum, err := strconv.ParseUint(string(data[:checksumLen-1]), 16, 32)
+	if err != nil {
+		return nil, false
 	}
+	payload := data[checksumLen:]
 
-	return &smartcache.CacheEntry[T]{
-		Data:            c.Data,
-		Err:             err,
-		Created:         c.Created,
-		FixedExpiration: c.FixedExpiration,
-	}, nil
+	return payload, uint32(sum) == crc32.ChecksumIEEE(payload)
 }
@@ -3,6 +3,8 @@ package redis_test
 import (
 	"context"
 	"errors"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -11,6 +13,7 @@ import (
 	redisbackend "github.com/m-zajac/smartcache/backend/redis"
 	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestBackend(t *testing.T) {
@@ -71,6 +74,26 @@ func TestBackend(t *testing.T) {
 			ttl:         time.Minute,
 			wantExpired: false,
 		},
+		{
+			name: "with epoch",
+			entry: smartcache.CacheEntry[string]{
+				Data:    ptr("testvalue"),
+				Created: time.Now().Add(-time.Minute),
+				Epoch:   3,
+			},
+			ttl:         time.Minute,
+			wantExpired: false,
+		},
+		{
+			name: "with schema version",
+			entry: smartcache.CacheEntry[string]{
+				Data:          ptr("testvalue"),
+				Created:       time.Now().Add(-time.Minute),
+				SchemaVersion: "v2",
+			},
+			ttl:         time.Minute,
+			wantExpired: false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -88,6 +111,8 @@ func TestBackend(t *testing.T) {
 				assert.Equal(t, tt.entry.Created.Unix(), gotEntry.Created.Unix())
 				assert.Equal(t, tt.entry.Data, gotEntry.Data)
 				assert.Equal(t, tt.entry.Err, gotEntry.Err)
+				assert.Equal(t, tt.entry.Epoch, gotEntry.Epoch)
+				assert.Equal(t, tt.entry.SchemaVersion, gotEntry.SchemaVersion)
 				if tt.entry.FixedExpiration == nil {
 					assert.Nil(t, gotEntry.FixedExpiration)
 				} else {
@@ -102,3 +127,400 @@ func TestBackend(t *testing.T) {
 func ptr[T any](v T) *T {
 	return &v
 }
+
+func TestBackend_ReadFallbackPrefix(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := miniredis.RunT(t)
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr: s.Addr(),
+	})
+
+	created := time.Now().Add(-time.Minute)
+	decode := func(data []byte) (*smartcache.CacheEntry[string], error) {
+		v := string(data)
+		return &smartcache.CacheEntry[string]{
+			Data:    &v,
+			Created: created,
+		}, nil
+	}
+
+	backend, err := redisbackend.NewBackend[string](rdb, "new:", redisbackend.WithReadFallbackPrefix("legacy:", decode))
+	assert.NoError(t, err)
+
+	// Value written by a legacy system.
+	assert.NoError(t, s.Set("legacy:key", "legacy value"))
+
+	gotEntry, err := backend.Get(ctx, "key")
+	assert.NoError(t, err)
+	if assert.NotNil(t, gotEntry) {
+		assert.Equal(t, "legacy value", *gotEntry.Data)
+		assert.Equal(t, created, gotEntry.Created)
+	}
+
+	// Value under the primary prefix takes precedence.
+	err = backend.Set(ctx, "key", time.Minute, &smartcache.CacheEntry[string]{
+		Data:    ptr("new value"),
+		Created: time.Now(),
+	})
+	assert.NoError(t, err)
+
+	gotEntry, err = backend.Get(ctx, "key")
+	assert.NoError(t, err)
+	if assert.NotNil(t, gotEntry) {
+		assert.Equal(t, "new value", *gotEntry.Data)
+	}
+
+	// Missing in both.
+	gotEntry, err = backend.Get(ctx, "missing")
+	assert.NoError(t, err)
+	assert.Nil(t, gotEntry)
+}
+
+func TestBackend_GetMulti(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := miniredis.RunT(t)
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr: s.Addr(),
+	})
+
+	backend, err := redisbackend.NewBackend[string](rdb, "testprefix")
+	assert.NoError(t, err)
+
+	created := time.Now().Add(-time.Minute)
+	for _, key := range []string{"key1", "key2"} {
+		err = backend.Set(ctx, key, time.Minute, &smartcache.CacheEntry[string]{
+			Data:    ptr("value " + key),
+			Created: created,
+		})
+		assert.NoError(t, err)
+	}
+
+	entries, err := backend.GetMulti(ctx, []string{"key1", "missing", "key2"})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	for _, key := range []string{"key1", "key2"} {
+		if assert.Contains(t, entries, key) {
+			assert.Equal(t, "value "+key, *entries[key].Data)
+			assert.Equal(t, created.Unix(), entries[key].Created.Unix())
+		}
+	}
+}
+
+func TestBackend_GetWithTTL(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := miniredis.RunT(t)
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr: s.Addr(),
+	})
+
+	backend, err := redisbackend.NewBackend[string](rdb, "testprefix")
+	assert.NoError(t, err)
+
+	entry := &smartcache.CacheEntry[string]{
+		Data:    ptr("testvalue"),
+		Created: time.Now(),
+	}
+	assert.NoError(t, backend.Set(ctx, "key", time.Hour, entry))
+
+	got, ttl, err := backend.GetWithTTL(ctx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, "testvalue", *got.Data)
+	assert.Equal(t, time.Hour, ttl)
+
+	s.SetTTL("testprefixkey", time.Minute)
+	_, ttl, err = backend.GetWithTTL(ctx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Minute, ttl)
+
+	got, ttl, err = backend.GetWithTTL(ctx, "missing")
+	assert.NoError(t, err)
+	assert.Nil(t, got)
+	assert.Negative(t, ttl)
+}
+
+func TestBackend_GetMultiWithTTL(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := miniredis.RunT(t)
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr: s.Addr(),
+	})
+
+	backend, err := redisbackend.NewBackend[string](rdb, "testprefix")
+	assert.NoError(t, err)
+
+	for _, key := range []string{"key1", "key2"} {
+		assert.NoError(t, backend.Set(ctx, key, time.Hour, &smartcache.CacheEntry[string]{
+			Data:    ptr("value " + key),
+			Created: time.Now(),
+		}))
+	}
+	s.SetTTL("testprefixkey2", time.Minute)
+
+	entries, ttls, err := backend.GetMultiWithTTL(ctx, []string{"key1", "missing", "key2"})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	for _, key := range []string{"key1", "key2"} {
+		if assert.Contains(t, entries, key) {
+			assert.Equal(t, "value "+key, *entries[key].Data)
+		}
+	}
+	assert.Equal(t, map[string]time.Duration{"key1": time.Hour, "key2": time.Minute}, ttls)
+}
+
+func TestBackend_GetRaw(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := miniredis.RunT(t)
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr: s.Addr(),
+	})
+
+	backend, err := redisbackend.NewBackend[string](rdb, "testprefix")
+	assert.NoError(t, err)
+
+	entry := &smartcache.CacheEntry[string]{
+		Data:    ptr("testvalue"),
+		Created: time.Now(),
+	}
+	assert.NoError(t, backend.Set(ctx, "key", time.Hour, entry))
+
+	stored, err := s.Get("testprefixkey")
+	assert.NoError(t, err)
+
+	raw, found, err := backend.GetRaw(ctx, "key")
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, []byte(stored), raw)
+
+	raw, found, err = backend.GetRaw(ctx, "missing")
+	assert.NoError(t, err)
+	assert.False(t, found)
+	assert.Nil(t, raw)
+}
+
+func TestBackend_Delete(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := miniredis.RunT(t)
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr: s.Addr(),
+	})
+
+	backend, err := redisbackend.NewBackend[string](rdb, "testprefix")
+	assert.NoError(t, err)
+
+	entry := &smartcache.CacheEntry[string]{
+		Data:    ptr("testvalue"),
+		Created: time.Now(),
+	}
+	assert.NoError(t, backend.Set(ctx, "key", time.Hour, entry))
+	assert.NoError(t, backend.Delete(ctx, "key"))
+	assert.False(t, s.Exists("testprefixkey"))
+
+	got, err := backend.Get(ctx, "key")
+	assert.NoError(t, err)
+	assert.Nil(t, got)
+
+	// Deleting a missing key is fine.
+	assert.NoError(t, backend.Delete(ctx, "key"))
+}
+
+func TestBackend_Keys(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := miniredis.RunT(t)
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr: s.Addr(),
+	})
+
+	// Pattern characters in the prefix are matched literally.
+	backend, err := redisbackend.NewBackend[string](rdb, "test*prefix:")
+	require.NoError(t, err)
+
+	n, err := backend.Len(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	for _, key := range []string{"key1", "key2", "key3"} {
+		require.NoError(t, backend.Set(ctx, key, time.Hour, &smartcache.CacheEntry[string]{
+			Data:    ptr(key),
+			Created: time.Now(),
+		}))
+	}
+	require.NoError(t, s.Set("test-prefix:other", "value"))
+	require.NoError(t, s.Set("unrelated", "value"))
+
+	n, err = backend.Len(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+
+	keys, err := backend.Keys(ctx)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"key1", "key2", "key3"}, keys)
+}
+
+func TestBackend_Purge(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := miniredis.RunT(t)
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr: s.Addr(),
+	})
+
+	backend, err := redisbackend.NewBackend[string](rdb, "testprefix:")
+	require.NoError(t, err)
+
+	for i := 0; i < 2500; i++ {
+		require.NoError(t, backend.Set(ctx, strconv.Itoa(i), time.Hour, &smartcache.CacheEntry[string]{
+			Data:    ptr("testvalue"),
+			Created: time.Now(),
+		}))
+	}
+	require.NoError(t, s.Set("other:key", "value"))
+
+	require.NoError(t, backend.Purge(ctx))
+
+	n, err := backend.Len(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+	got, err := backend.Get(ctx, "1")
+	require.NoError(t, err)
+	assert.Nil(t, got)
+
+	// Data outside of the prefix is kept.
+	assert.True(t, s.Exists("other:key"))
+}
+
+func TestBackend_Checksum(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := miniredis.RunT(t)
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr: s.Addr(),
+	})
+
+	var mismatches []error
+	backend, err := redisbackend.NewBackend[string](rdb, "testprefix", redisbackend.WithChecksum[string](func(err error) {
+		mismatches = append(mismatches, err)
+	}))
+	assert.NoError(t, err)
+
+	entry := &smartcache.CacheEntry[string]{
+		Data:    ptr("testvalue"),
+		Created: time.Now(),
+	}
+	for _, key := range []string{"key", "tampered", "unchecked"} {
+		assert.NoError(t, backend.Set(ctx, key, time.Hour, entry))
+	}
+
+	got, err := backend.Get(ctx, "key")
+	assert.NoError(t, err)
+	if assert.NotNil(t, got) {
+		assert.Equal(t, "testvalue", *got.Data)
+	}
+
+	// Still a valid entry, but not the one that was stored.
+	stored, err := s.Get("testprefixtampered")
+	assert.NoError(t, err)
+	assert.NoError(t, s.Set("testprefixtampered", strings.Replace(stored, "testvalue", "testvaluf", 1)))
+
+	// Entry stored without the checksum.
+	stored, err = s.Get("testprefixunchecked")
+	assert.NoError(t, err)
+	assert.NoError(t, s.Set("testprefixunchecked", stored[9:]))
+
+	for _, key := range []string{"tampered", "unchecked"} {
+		got, err = backend.Get(ctx, key)
+		assert.NoError(t, err)
+		assert.Nil(t, got)
+
+		got, _, err = backend.GetWithTTL(ctx, key)
+		assert.NoError(t, err)
+		assert.Nil(t, got)
+	}
+
+	entries, err := backend.GetMulti(ctx, []string{"key", "tampered", "unchecked"})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Contains(t, entries, "key")
+
+	assert.Len(t, mismatches, 6)
+	for _, err := range mismatches {
+		assert.ErrorIs(t, err, redisbackend.ErrChecksumMismatch)
+	}
+}
+
+func TestBackend_Compression(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := miniredis.RunT(t)
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr: s.Addr(),
+	})
+
+	_, err := redisbackend.NewBackend[string](rdb, "testprefix", redisbackend.WithCompression[string](nil))
+	assert.Error(t, err)
+
+	// Value stored before enabling the compression.
+	plain, err := redisbackend.NewBackend[string](rdb, "testprefix")
+	assert.NoError(t, err)
+	created := time.Now().Add(-time.Minute)
+	assert.NoError(t, plain.Set(ctx, "old", time.Hour, &smartcache.CacheEntry[string]{
+		Data:    ptr("old value"),
+		Created: created,
+	}))
+
+	backend, err := redisbackend.NewBackend[string](rdb, "testprefix", redisbackend.WithCompression[string](redisbackend.GzipCompressor{}))
+	assert.NoError(t, err)
+
+	large := strings.Repeat("compressible ", 1000)
+	assert.NoError(t, backend.Set(ctx, "new", time.Hour, &smartcache.CacheEntry[string]{
+		Data:    &large,
+		Created: created,
+	}))
+
+	stored, err := s.Get("testprefixnew")
+	assert.NoError(t, err)
+	assert.Less(t, len(stored), len(large)/10)
+
+	got, err := backend.Get(ctx, "new")
+	assert.NoError(t, err)
+	if assert.NotNil(t, got) {
+		assert.Equal(t, large, *got.Data)
+		assert.Equal(t, created.Unix(), got.Created.Unix())
+	}
+
+	got, err = backend.Get(ctx, "old")
+	assert.NoError(t, err)
+	if assert.NotNil(t, got) {
+		assert.Equal(t, "old value", *got.Data)
+	}
+
+	// Compressed values can't be read without the compressor.
+	_, err = plain.Get(ctx, "new")
+	assert.Error(t, err)
+}
@@ -0,0 +1,79 @@
+package redis
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/m-zajac/smartcache"
+)
+
+// Codec serializes cache entries stored in redis.
+type Codec[T any] interface {
+	Marshal(entry *smartcache.CacheEntry[T]) ([]byte, error)
+	Unmarshal(data []byte) (*smartcache.CacheEntry[T], error)
+}
+
+// container is the serialized form of a cache entry, shared by the codecs.
+type container[T any] struct {
+	Data            *T         `json:"data" msgpack:"data"`
+	Err             string     `json:"err" msgpack:"err,omitempty"`
+	Created         time.Time  `json:"created" msgpack:"created"`
+	FixedExpiration *time.Time `json:"fixedExpiration,omitempty" msgpack:"fixedExpiration,omitempty"`
+	Epoch           uint64     `json:"epoch,omitempty" msgpack:"epoch,omitempty"`
+	SchemaVersion   string     `json:"schemaVersion,omitempty" msgpack:"schemaVersion,omitempty"`
+}
+
+func newContainer[T any](entry *smartcache.CacheEntry[T]) container[T] {
+	errStr := ""
+	if entry.Err != nil {
+		errStr = entry.Err.Error()
+	}
+
+	return container[T]{
+		Data:            entry.Data,
+		Err:             errStr,
+		Created:         entry.Created,
+		FixedExpiration: entry.FixedExpiration,
+		Epoch:           entry.Epoch,
+		SchemaVersion:   entry.SchemaVersion,
+	}
+}
+
+func (c container[T]) entry() *smartcache.CacheEntry[T] {
+	var err error
+	if c.Err != "" {
+		err = errors.New(c.Err)
+	}
+
+	return &smartcache.CacheEntry[T]{
+		Data:            c.Data,
+		Err:             err,
+		Created:         c.Created,
+		FixedExpiration: c.FixedExpiration,
+		Epoch:           c.Epoch,
+		SchemaVersion:   c.SchemaVersion,
+	}
+}
+
+// JSONCodec serializes entries to JSON. It's the default codec.
+type JSONCodec[T any] struct{}
+
+func (JSONCodec[T]) Marshal(entry *smartcache.CacheEntry[T]) ([]byte, error) {
+	v, err := json.Marshal(newContainer(entry))
+	if err != nil {
+		return nil, fmt.Errorf("serializing to json: %w", err)
+	}
+
+	return v, nil
+}
+
+func (JSONCodec[T]) Unmarshal(data []byte) (*smartcache.CacheEntry[T], error) {
+	var c container[T]
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("deserializing json: %w", err)
+	}
+
+	return c.entry(), nil
+}
@@ -0,0 +1,31 @@
+package redis
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+
+	"github.com/m-zajac/smartcache"
+)
+
+// GobCodec serializes entries with encoding/gob. It handles types JSON can't, like maps with non-string keys,
+// but interface values in the T type have to be registered with `gob.Register`.
+type GobCodec[T any] struct{}
+
+func (GobCodec[T]) Marshal(entry *smartcache.CacheEntry[T]) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(newContainer(entry)); err != nil {
+		return nil, fmt.Errorf("serializing to gob: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (GobCodec[T]) Unmarshal(data []byte) (*smartcache.CacheEntry[T], error) {
+	var c container[T]
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&c); err != nil {
+		return nil, fmt.Errorf("deserializing gob: %w", err)
+	}
+
+	return c.entry(), nil
+}
@@ -0,0 +1,29 @@
+package redis
+
+import (
+	"fmt"
+
+	"github.com/m-zajac/smartcache"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// MsgpackCodec serializes entries to MessagePack, which is more compact and faster than JSON.
+type MsgpackCodec[T any] struct{}
+
+func (MsgpackCodec[T]) Marshal(entry *smartcache.CacheEntry[T]) ([]byte, error) {
+	v, err := msgpack.Marshal(newContainer(entry))
+	if err != nil {
+		return nil, fmt.Errorf("serializing to msgpack: %w", err)
+	}
+
+	return v, nil
+}
+
+func (MsgpackCodec[T]) Unmarshal(data []byte) (*smartcache.CacheEntry[T], error) {
+	var c container[T]
+	if err := msgpack.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("deserializing msgpack: %w", err)
+	}
+
+	return c.entry(), nil
+}
@@ -0,0 +1,59 @@
+package redis
+
+import (
+	"fmt"
+
+	"github.com/m-zajac/smartcache"
+)
+
+// Format tags of the codecs, see `TaggedCodec`.
+const (
+	FormatJSON    byte = 0x01
+	FormatGob     byte = 0x02
+	FormatMsgpack byte = 0x03
+)
+
+// TaggedCodec prefixes serialized entries with a format tag byte and detects the format on reads,
+// so a single backend reads entries of every format, e.g. during a migration from JSON to msgpack.
+// Entries are written in one format. Untagged entries, stored without the TaggedCodec, are read as JSON.
+type TaggedCodec[T any] struct {
+	format byte
+	codecs map[byte]Codec[T]
+}
+
+// NewTaggedCodec creates the codec writing entries in the format, one of `FormatJSON`, `FormatGob`, `FormatMsgpack`.
+func NewTaggedCodec[T any](format byte) (TaggedCodec[T], error) {
+	c := TaggedCodec[T]{
+		format: format,
+		codecs: map[byte]Codec[T]{
+			FormatJSON:    JSONCodec[T]{},
+			FormatGob:     GobCodec[T]{},
+			FormatMsgpack: MsgpackCodec[T]{},
+		},
+	}
+	if _, ok := c.codecs[format]; !ok {
+		return TaggedCodec[T]{}, fmt.Errorf("unknown format %#x", format)
+	}
+
+	return c, nil
+}
+
+func (c TaggedCodec[T]) Marshal(entry *smartcache.CacheEntry[T]) ([]byte, error) {
+	data, err := c.codecs[c.format].Marshal(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte{c.format}, data...), nil
+}
+
+func (c TaggedCodec[T]) Unmarshal(data []byte) (*smartcache.CacheEntry[T], error) {
+	if len(data) > 0 {
+		if codec, ok := c.codecs[data[0]]; ok {
+			return codec.Unmarshal(data[1:])
+		}
+	}
+
+	// Untagged JSON starts with a brace, which is not a tag.
+	return JSONCodec[T]{}.Unmarshal(data)
+}
@@ -0,0 +1,212 @@
+package redis_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/m-zajac/smartcache"
+	redisbackend "github.com/m-zajac/smartcache/backend/redis"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type codecTestData struct {
+	Name   string
+	Scores map[int]float64
+}
+
+func TestCodecs(t *testing.T) {
+	t.Parallel()
+
+	codecs := map[string]redisbackend.Codec[codecTestData]{
+		"json":    redisbackend.JSONCodec[codecTestData]{},
+		"gob":     redisbackend.GobCodec[codecTestData]{},
+		"msgpack": redisbackend.MsgpackCodec[codecTestData]{},
+	}
+	entries := map[string]*smartcache.CacheEntry[codecTestData]{
+		"data": {
+			Data:    &codecTestData{Name: "name", Scores: map[int]float64{1: 0.5, 2: 1.5}},
+			Created: time.Now().Add(-time.Minute),
+		},
+		"error": {
+			Err:     errors.New("test error"),
+			Created: time.Now().Add(-time.Minute),
+		},
+		"fixed expiration": {
+			Data:            &codecTestData{Name: "name"},
+			Created:         time.Now().Add(-time.Minute),
+			FixedExpiration: ptr(time.Now().Add(time.Hour)),
+		},
+		"epoch and schema version": {
+			Data:          &codecTestData{Name: "name"},
+			Created:       time.Now().Add(-time.Minute),
+			Epoch:         3,
+			SchemaVersion: "v2",
+		},
+	}
+
+	for codecName, codec := range codecs {
+		for entryName, entry := range entries {
+			codec, entry := codec, entry
+			t.Run(codecName+", "+entryName, func(t *testing.T) {
+				t.Parallel()
+
+				data, err := codec.Marshal(entry)
+				require.NoError(t, err)
+				got, err := codec.Unmarshal(data)
+				require.NoError(t, err)
+
+				assert.True(t, entry.Created.Equal(got.Created), "created: %s, got %s", entry.Created, got.Created)
+				if entry.FixedExpiration == nil {
+					assert.Nil(t, got.FixedExpiration)
+				} else if assert.NotNil(t, got.FixedExpiration) {
+					assert.True(t, entry.FixedExpiration.Equal(*got.FixedExpiration))
+				}
+				assert.Equal(t, entry.Err, got.Err)
+				assert.Equal(t, entry.Epoch, got.Epoch)
+				assert.Equal(t, entry.SchemaVersion, got.SchemaVersion)
+				if entry.Data == nil {
+					assert.Nil(t, got.Data)
+				} else if assert.NotNil(t, got.Data) {
+					assert.Equal(t, entry.Data.Name, got.Data.Name)
+					assert.Equal(t, len(entry.Data.Scores), len(got.Data.Scores))
+					for k, v := range entry.Data.Scores {
+						assert.Equal(t, v, got.Data.Scores[k])
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestBackend_WithCodec(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := miniredis.RunT(t)
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr: s.Addr(),
+	})
+
+	_, err := redisbackend.NewBackend[string](rdb, "testprefix", redisbackend.WithCodec[string](nil))
+	assert.Error(t, err)
+
+	backend, err := redisbackend.NewBackend[string](rdb, "testprefix", redisbackend.WithCodec[string](redisbackend.MsgpackCodec[string]{}))
+	require.NoError(t, err)
+
+	entry := &smartcache.CacheEntry[string]{
+		Data:    ptr("testvalue"),
+		Created: time.Now(),
+	}
+	require.NoError(t, backend.Set(ctx, "key", time.Hour, entry))
+
+	stored, err := s.Get("testprefixkey")
+	require.NoError(t, err)
+	expected, err := redisbackend.MsgpackCodec[string]{}.Marshal(entry)
+	require.NoError(t, err)
+	assert.Equal(t, string(expected), stored)
+
+	got, err := backend.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, "testvalue", *got.Data)
+	assert.True(t, entry.Created.Equal(got.Created))
+}
+
+func TestTaggedCodec(t *testing.T) {
+	t.Parallel()
+
+	_, err := redisbackend.NewTaggedCodec[string](0x7f)
+	assert.Error(t, err)
+
+	ctx := context.Background()
+	s := miniredis.RunT(t)
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr: s.Addr(),
+	})
+
+	codec, err := redisbackend.NewTaggedCodec[string](redisbackend.FormatMsgpack)
+	require.NoError(t, err)
+	backend, err := redisbackend.NewBackend[string](rdb, "testprefix", redisbackend.WithCodec[string](codec))
+	require.NoError(t, err)
+
+	// An entry stored before the migration, as untagged JSON.
+	legacy, err := redisbackend.JSONCodec[string]{}.Marshal(&smartcache.CacheEntry[string]{
+		Data:    ptr("legacy"),
+		Created: time.Now(),
+	})
+	require.NoError(t, err)
+	require.NoError(t, s.Set("testprefixlegacy", string(legacy)))
+
+	entry := &smartcache.CacheEntry[string]{
+		Data:    ptr("new"),
+		Created: time.Now(),
+	}
+	require.NoError(t, backend.Set(ctx, "new", time.Hour, entry))
+
+	// The new entry is tagged msgpack.
+	stored, err := s.Get("testprefixnew")
+	require.NoError(t, err)
+	expected, err := redisbackend.MsgpackCodec[string]{}.Marshal(entry)
+	require.NoError(t, err)
+	assert.Equal(t, string(append([]byte{redisbackend.FormatMsgpack}, expected...)), stored)
+
+	got, err := backend.Get(ctx, "legacy")
+	require.NoError(t, err)
+	assert.Equal(t, "legacy", *got.Data)
+
+	got, err = backend.Get(ctx, "new")
+	require.NoError(t, err)
+	assert.Equal(t, "new", *got.Data)
+	assert.True(t, entry.Created.Equal(got.Created))
+
+	// Tagged entries of other formats are readable too.
+	jsonCodec, err := redisbackend.NewTaggedCodec[string](redisbackend.FormatJSON)
+	require.NoError(t, err)
+	tagged, err := jsonCodec.Marshal(&smartcache.CacheEntry[string]{Data: ptr("tagged json")})
+	require.NoError(t, err)
+	got, err = codec.Unmarshal(tagged)
+	require.NoError(t, err)
+	assert.Equal(t, "tagged json", *got.Data)
+}
+
+func TestBackend_Pair(t *testing.T) {
+	t.Parallel()
+
+	type meta struct {
+		Version int
+	}
+
+	ctx := context.Background()
+	s := miniredis.RunT(t)
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr: s.Addr(),
+	})
+
+	backend, err := redisbackend.NewBackend[smartcache.Pair[string, meta]](rdb, "testprefix")
+	require.NoError(t, err)
+	cache, err := smartcache.New[smartcache.Pair[string, meta]](backend)
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	fetchFunc := func(ctx context.Context, key string) (*string, *meta, error) {
+		return ptr("data"), &meta{Version: 2}, nil
+	}
+	for _, want := range []smartcache.ResultType{smartcache.Miss, smartcache.HotHit} {
+		result, err := smartcache.GetPair(ctx, cache, "key", fetchFunc)
+		require.NoError(t, err)
+		assert.Equal(t, want, result.Type)
+		assert.Equal(t, "data", *result.Data.First)
+		assert.Equal(t, meta{Version: 2}, *result.Data.Second)
+	}
+
+	stored, err := s.Get("testprefixkey")
+	require.NoError(t, err)
+	assert.Contains(t, stored, `"data":{"first":"data","second":{"Version":2}}`)
+}
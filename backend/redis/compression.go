@@ -0,0 +1,71 @@
+package redis
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Compressor compresses serialized entries, see `WithCompression`.
+type Compressor interface {
+	Compress(data []byte) ([]byte, error)
+	Decompress(data []byte) ([]byte, error)
+}
+
+// compressedHeader marks compressed values. None of the codecs produces data starting with a zero byte,
+// so values stored without compression remain readable.
+const compressedHeader = 0x00
+
+func compress(c Compressor, data []byte) ([]byte, error) {
+	compressed, err := c.Compress(data)
+	if err != nil {
+		return nil, fmt.Errorf("compressing: %w", err)
+	}
+
+	return append([]byte{compressedHeader}, compressed...), nil
+}
+
+func isCompressed(data []byte) bool {
+	return len(data) > 0 && data[0] == compressedHeader
+}
+
+func decompress(c Compressor, data []byte) ([]byte, error) {
+	if c == nil {
+		return nil, errors.New("data is compressed, but compression is not enabled")
+	}
+
+	decompressed, err := c.Decompress(data[1:])
+	if err != nil {
+		return nil, fmt.Errorf("decompressing: %w", err)
+	}
+
+	return decompressed, nil
+}
+
+// GzipCompressor compresses data with gzip.
+type GzipCompressor struct{}
+
+func (GzipCompressor) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (GzipCompressor) Decompress(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return io.ReadAll(r)
+}
@@ -0,0 +1,97 @@
+package redis
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/m-zajac/smartcache"
+	"github.com/redis/go-redis/v9"
+)
+
+// InvalidationChannel is a `smartcache.InvalidationBus` using a redis pub/sub channel.
+// Messages are tagged with an ID of the instance, so keys published by the instance are not delivered back to it.
+type InvalidationChannel struct {
+	client  *redis.Client
+	channel string
+	id      string
+}
+
+var _ smartcache.InvalidationBus = &InvalidationChannel{}
+
+// NewInvalidationChannel creates the bus publishing keys to the channel. Every cache instance needs its own bus.
+func NewInvalidationChannel(client *redis.Client, channel string) (*InvalidationChannel, error) {
+	if client == nil {
+		return nil, errors.New("redis client is nil")
+	}
+	if channel == "" {
+		return nil, errors.New("channel is empty")
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("generating instance id: %w", err)
+	}
+
+	return &InvalidationChannel{
+		client:  client,
+		channel: channel,
+		id:      hex.EncodeToString(id),
+	}, nil
+}
+
+// WithInvalidationChannel creates the cache option enabling `smartcache.WithInvalidationBus` with a new `InvalidationChannel`.
+// Every cache instance needs its own option.
+func WithInvalidationChannel(client *redis.Client, channel string) smartcache.Option {
+	bus, err := NewInvalidationChannel(client, channel)
+	if err != nil {
+		return smartcache.FailingOption(fmt.Errorf("invalidation channel: %w", err))
+	}
+
+	return smartcache.WithInvalidationBus(bus)
+}
+
+func (c *InvalidationChannel) Publish(ctx context.Context, key string) error {
+	return c.client.Publish(ctx, c.channel, c.id+":"+key).Err()
+}
+
+func (c *InvalidationChannel) Subscribe(ctx context.Context) (<-chan string, error) {
+	sub := c.client.Subscribe(ctx, c.channel)
+	// Wait for the confirmation, so no keys published after returning are missed.
+	if _, err := sub.Receive(ctx); err != nil {
+		_ = sub.Close()
+		return nil, fmt.Errorf("subscribing to channel '%s': %w", c.channel, err)
+	}
+
+	keys := make(chan string)
+	go func() {
+		defer close(keys)
+		defer sub.Close()
+
+		messages := sub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+				id, key, found := strings.Cut(msg.Payload, ":")
+				if !found || id == c.id {
+					continue
+				}
+				select {
+				case keys <- key:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return keys, nil
+}
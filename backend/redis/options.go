@@ -0,0 +1,68 @@
+package redis
+
+import (
+	"errors"
+
+	"github.com/m-zajac/smartcache"
+)
+
+// Option allows to configure backend settings.
+type Option[T any] func(*Backend[T]) error
+
+// WithReadFallbackPrefix allows reading entries written under a different key prefix, e.g. by a legacy system during a migration.
+// If a key is missing under the backend's prefix, it's read under the fallback prefix and decoded with the provided function.
+// Entries are never written under the fallback prefix.
+func WithReadFallbackPrefix[T any](prefix string, decode func([]byte) (*smartcache.CacheEntry[T], error)) Option[T] {
+	return func(b *Backend[T]) error {
+		if decode == nil {
+			return errors.New("decode function is nil")
+		}
+
+		b.fallbackPrefix = prefix
+		b.fallbackDecode = decode
+
+		return nil
+	}
+}
+
+// WithChecksum enables CRC32 checksums of stored entries, guarding against corrupted data.
+// Entries failing the verification are treated as missing, so they are fetched again, and reported to the onMismatch
+// function, if not nil. The reported error wraps ErrChecksumMismatch.
+// Entries stored without the checksum, e.g. before enabling the option, fail the verification too.
+func WithChecksum[T any](onMismatch func(error)) Option[T] {
+	return func(b *Backend[T]) error {
+		b.checksum = true
+		b.onChecksumMismatch = onMismatch
+
+		return nil
+	}
+}
+
+// WithCodec sets the codec serializing stored entries. The default is `JSONCodec`.
+// Entries stored with a different codec can't be read, so changing the codec requires a new key prefix,
+// unless it's changed to a `TaggedCodec`, which detects the format of every entry.
+func WithCodec[T any](codec Codec[T]) Option[T] {
+	return func(b *Backend[T]) error {
+		if codec == nil {
+			return errors.New("codec is nil")
+		}
+
+		b.codec = codec
+
+		return nil
+	}
+}
+
+// WithCompression enables compressing stored entries, e.g. with `GzipCompressor`.
+// Compressed values are marked with a header byte, so values stored before enabling the compression remain readable.
+func WithCompression[T any](c Compressor) Option[T] {
+	return func(b *Backend[T]) error {
+		if c == nil {
+			return errors.New("compressor is nil")
+		}
+
+		b.compressor = c
+
+		return nil
+	}
+}
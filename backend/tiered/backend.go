@@ -0,0 +1,117 @@
+package tiered
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/m-zajac/smartcache"
+)
+
+// Backend for cache that combines a fast local backend (L1), e.g. lru, with a shared one (L2), e.g. redis.
+//
+// Entries are read from L1 first, and from L2 on a L1 miss. Entries found in L2 are copied to L1 as they are,
+// so their `Created` and `FixedExpiration` times, and so their freshness, are the same in both tiers.
+// The copy is stored for the remaining L2 TTL if L2 implements `smartcache.TTLAwareBackend`,
+// otherwise until its fixed expiration or without a TTL. Failing to store the copy doesn't fail the read.
+//
+// Writes and deletes go to both tiers. Note that L1 is local, so deletes made by other instances sharing L2
+// are not visible until the L1 entry is replaced, unless the cache is configured with `smartcache.WithInvalidationBus`.
+//
+// Both backends will be closed when the parent cache is closed.
+type Backend[T any] struct {
+	l1 smartcache.Backend[T]
+	l2 smartcache.Backend[T]
+}
+
+var (
+	_ smartcache.Backend[string]              = &Backend[string]{}
+	_ smartcache.LocalEvictingBackend[string] = &Backend[string]{}
+)
+
+func NewBackend[T any](l1, l2 smartcache.Backend[T]) (*Backend[T], error) {
+	if l1 == nil {
+		return nil, errors.New("l1 backend is nil")
+	}
+	if l2 == nil {
+		return nil, errors.New("l2 backend is nil")
+	}
+
+	return &Backend[T]{
+		l1: l1,
+		l2: l2,
+	}, nil
+}
+
+func (b *Backend[T]) Get(ctx context.Context, key string) (*smartcache.CacheEntry[T], error) {
+	entry, err := b.l1.Get(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("reading l1: %w", err)
+	}
+	if entry != nil {
+		return entry, nil
+	}
+
+	var ttl time.Duration
+	if tb, ok := b.l2.(smartcache.TTLAwareBackend[T]); ok {
+		entry, ttl, err = tb.GetWithTTL(ctx, key)
+	} else {
+		entry, err = b.l2.Get(ctx, key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading l2: %w", err)
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	// A non-positive TTL means L2 doesn't know it.
+	if ttl <= 0 {
+		ttl = 0
+		if entry.FixedExpiration != nil {
+			if ttl = time.Until(*entry.FixedExpiration); ttl <= 0 {
+				return entry, nil
+			}
+		}
+	}
+	_ = b.l1.Set(ctx, key, ttl, entry)
+
+	return entry, nil
+}
+
+func (b *Backend[T]) Set(ctx context.Context, key string, ttl time.Duration, entry *smartcache.CacheEntry[T]) error {
+	if err := b.l2.Set(ctx, key, ttl, entry); err != nil {
+		return fmt.Errorf("writing l2: %w", err)
+	}
+	if err := b.l1.Set(ctx, key, ttl, entry); err != nil {
+		return fmt.Errorf("writing l1: %w", err)
+	}
+
+	return nil
+}
+
+func (b *Backend[T]) Delete(ctx context.Context, key string) error {
+	if err := b.l1.Delete(ctx, key); err != nil {
+		return fmt.Errorf("deleting from l1: %w", err)
+	}
+	if err := b.l2.Delete(ctx, key); err != nil {
+		return fmt.Errorf("deleting from l2: %w", err)
+	}
+
+	return nil
+}
+
+// EvictLocal removes the entry from L1 only, so it's read from L2 again.
+func (b *Backend[T]) EvictLocal(ctx context.Context, key string) error {
+	if err := b.l1.Delete(ctx, key); err != nil {
+		return fmt.Errorf("deleting from l1: %w", err)
+	}
+
+	return nil
+}
+
+func (b *Backend[T]) Close() {
+	b.l1.Close()
+	b.l2.Close()
+}
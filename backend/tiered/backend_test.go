@@ -0,0 +1,235 @@
+package tiered_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/m-zajac/smartcache"
+	"github.com/m-zajac/smartcache/backend/lru"
+	redisbackend "github.com/m-zajac/smartcache/backend/redis"
+	"github.com/m-zajac/smartcache/backend/tiered"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ttlRecordingBackend records TTLs of stored entries.
+type ttlRecordingBackend struct {
+	*lru.Backend[string]
+	ttls map[string]time.Duration
+}
+
+func (b *ttlRecordingBackend) Set(ctx context.Context, key string, ttl time.Duration, entry *smartcache.CacheEntry[string]) error {
+	b.ttls[key] = ttl
+	return b.Backend.Set(ctx, key, ttl, entry)
+}
+
+func newBackends(t *testing.T) (*ttlRecordingBackend, *redisbackend.Backend[string], *miniredis.Miniredis) {
+	t.Helper()
+
+	inner, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+	l1 := &ttlRecordingBackend{Backend: inner, ttls: make(map[string]time.Duration)}
+
+	s := miniredis.RunT(t)
+	l2, err := redisbackend.NewBackend[string](redis.NewClient(&redis.Options{Addr: s.Addr()}), "testprefix")
+	require.NoError(t, err)
+
+	return l1, l2, s
+}
+
+func TestBackend(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	l1, l2, s := newBackends(t)
+
+	backend, err := tiered.NewBackend[string](l1, l2)
+	require.NoError(t, err)
+
+	_, err = tiered.NewBackend[string](nil, l2)
+	assert.Error(t, err)
+	_, err = tiered.NewBackend[string](l1, nil)
+	assert.Error(t, err)
+
+	// Writes go to both tiers.
+	created := time.Now().Add(-time.Minute)
+	data := "testvalue"
+	require.NoError(t, backend.Set(ctx, "key", time.Hour, &smartcache.CacheEntry[string]{
+		Data:    &data,
+		Created: created,
+	}))
+	assert.True(t, s.Exists("testprefixkey"))
+	inL1, err := l1.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.NotNil(t, inL1)
+
+	// L1 hit.
+	got, err := backend.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.Same(t, inL1, got)
+
+	// L2 hit backfills L1 with the L2 entry times and remaining TTL.
+	fixed := time.Now().Add(time.Hour)
+	require.NoError(t, l2.Set(ctx, "l2key", 10*time.Minute, &smartcache.CacheEntry[string]{
+		Data:            &data,
+		Created:         created,
+		FixedExpiration: &fixed,
+	}))
+	got, err = backend.Get(ctx, "l2key")
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, "testvalue", *got.Data)
+	assert.Equal(t, created.Unix(), got.Created.Unix())
+	assert.Equal(t, fixed.Unix(), got.FixedExpiration.Unix())
+
+	inL1, err = l1.Get(ctx, "l2key")
+	require.NoError(t, err)
+	assert.Same(t, got, inL1)
+	assert.Equal(t, 10*time.Minute, l1.ttls["l2key"])
+
+	// Missing in both.
+	got, err = backend.Get(ctx, "missing")
+	require.NoError(t, err)
+	assert.Nil(t, got)
+
+	// Deletes go to both tiers.
+	require.NoError(t, backend.Delete(ctx, "l2key"))
+	assert.False(t, s.Exists("testprefixl2key"))
+	got, err = backend.Get(ctx, "l2key")
+	require.NoError(t, err)
+	assert.Nil(t, got)
+}
+
+func TestBackend_Cache(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	l1, l2, _ := newBackends(t)
+
+	backend, err := tiered.NewBackend[string](l1, l2)
+	require.NoError(t, err)
+
+	const primTTL = time.Minute
+	cache, err := smartcache.New[string](backend, smartcache.WithTTL(primTTL, time.Hour))
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	// Entry written by another instance is warm, also after being copied to L1.
+	data := "testvalue"
+	require.NoError(t, l2.Set(ctx, "key", time.Hour, &smartcache.CacheEntry[string]{
+		Data:    &data,
+		Created: time.Now().Add(-2 * primTTL),
+	}))
+
+	fetched := make(chan struct{}, 2)
+	fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		fetched <- struct{}{}
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	for i := 0; i < 2; i++ {
+		result, err := cache.Get(ctx, "key", fetchFunc)
+		require.NoError(t, err)
+		assert.Equal(t, smartcache.WarmHit, result.Type)
+	}
+}
+
+func TestBackend_Invalidation(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: s.Addr()})
+
+	backend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+	_, err = smartcache.New[string](backend, redisbackend.WithInvalidationChannel(nil, "invalidations"))
+	assert.Error(t, err)
+	_, err = smartcache.New[string](backend, redisbackend.WithInvalidationChannel(client, ""))
+	assert.Error(t, err)
+
+	// Two instances with local L1s, sharing L2.
+	newCache := func() (*smartcache.Cache[string], *lru.Backend[string]) {
+		l1, err := lru.NewBackend[string](100)
+		require.NoError(t, err)
+		l2, err := redisbackend.NewBackend[string](client, "testprefix")
+		require.NoError(t, err)
+		backend, err := tiered.NewBackend[string](l1, l2)
+		require.NoError(t, err)
+
+		cache, err := smartcache.New[string](
+			backend,
+			smartcache.WithTTL(time.Minute, time.Hour),
+			redisbackend.WithInvalidationChannel(client, "invalidations"),
+		)
+		require.NoError(t, err)
+		t.Cleanup(cache.Close)
+
+		return cache, l1
+	}
+	cache1, l11 := newCache()
+	cache2, l12 := newCache()
+	inL1 := func(l1 *lru.Backend[string]) bool {
+		entry, err := l1.Get(ctx, "key")
+		require.NoError(t, err)
+		return entry != nil
+	}
+
+	var fetches atomic.Int32
+	fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		fetches.Add(1)
+		data := "fetched"
+		return &smartcache.FetchResult[string]{Data: &data}, nil
+	}
+
+	// The second instance copies the entry set by the first one to its L1.
+	data := "data"
+	require.NoError(t, cache1.Set(ctx, "key", &data))
+	result, err := cache2.Get(ctx, "key", fetchFunc)
+	require.NoError(t, err)
+	assert.Equal(t, smartcache.HotHit, result.Type)
+	assert.Equal(t, "data", *result.Data)
+	require.True(t, inL1(l12))
+
+	// A delete on the first instance evicts the L1 entry of the second one.
+	require.NoError(t, cache1.Delete(ctx, "key"))
+	require.Eventually(t, func() bool { return !inL1(l12) }, time.Second, time.Millisecond)
+	result, err = cache2.Get(ctx, "key", fetchFunc)
+	require.NoError(t, err)
+	assert.Equal(t, smartcache.Miss, result.Type)
+	assert.EqualValues(t, 1, fetches.Load())
+
+	// So does an update, and the second instance reads the new data from L2.
+	updated := "updated"
+	_, err = cache1.Update(ctx, "key", &updated)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		result, err := cache2.Lookup(ctx, "key")
+		return err == nil && *result.Data == "updated"
+	}, time.Second, time.Millisecond)
+
+	// Instances don't evict their own entries.
+	assert.True(t, inL1(l11))
+
+	// Expire and ForceRefresh change the shared entry as well.
+	_, err = cache2.Get(ctx, "key", fetchFunc)
+	require.NoError(t, err)
+	require.True(t, inL1(l12))
+	require.NoError(t, cache1.Expire(ctx, "key"))
+	require.Eventually(t, func() bool { return !inL1(l12) }, time.Second, time.Millisecond)
+	result, err = cache2.Lookup(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, smartcache.WarmHit, result.Type)
+
+	require.True(t, inL1(l12))
+	_, err = cache1.ForceRefresh(ctx, "key", fetchFunc)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		result, err := cache2.Lookup(ctx, "key")
+		return err == nil && result.Type == smartcache.HotHit && *result.Data == "fetched"
+	}, time.Second, time.Millisecond)
+}
@@ -0,0 +1,102 @@
+package smartcache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MultiGetBackend is an optional interface for backends able to read many keys in a single call.
+type MultiGetBackend[T any] interface {
+	// GetMulti returns cache data for the given keys.
+	// Keys that are not found should be absent from the returned map.
+	GetMulti(ctx context.Context, keys []string) (map[string]*CacheEntry[T], error)
+}
+
+// MultiGetWithTTLBackend is an optional interface for backends able to read many keys in a single call,
+// along with their remaining TTLs. A `TTLAwareBackend` has to implement it to be used with `WithBatchedGet`.
+type MultiGetWithTTLBackend[T any] interface {
+	// GetMultiWithTTL returns cache data for the given keys and their remaining TTLs observed by the backend.
+	// Keys that are not found should be absent from the returned maps. Negative or missing TTL means it's unknown.
+	GetMultiWithTTL(ctx context.Context, keys []string) (map[string]*CacheEntry[T], map[string]time.Duration, error)
+}
+
+type batchResult[T any] struct {
+	entry *CacheEntry[T]
+	ttl   time.Duration
+	err   error
+}
+
+// batcher accumulates backend reads issued within a time window and performs them as a single multi get.
+// If the backend implements `MultiGetWithTTLBackend`, the remaining TTLs are read as well.
+type batcher[T any] struct {
+	ctx        context.Context
+	backend    MultiGetBackend[T]
+	ttlBackend MultiGetWithTTLBackend[T]
+	window     time.Duration
+
+	mu      sync.Mutex
+	pending map[string][]chan batchResult[T]
+}
+
+func newBatcher[T any](ctx context.Context, backend MultiGetBackend[T], window time.Duration) *batcher[T] {
+	tb, _ := backend.(MultiGetWithTTLBackend[T])
+
+	return &batcher[T]{
+		ctx:        ctx,
+		backend:    backend,
+		ttlBackend: tb,
+		window:     window,
+	}
+}
+
+// get reads the key in the next batch. The remaining TTL is negative if it's unknown.
+func (b *batcher[T]) get(ctx context.Context, key string) (*CacheEntry[T], time.Duration, error) {
+	ch := make(chan batchResult[T], 1)
+
+	b.mu.Lock()
+	if b.pending == nil {
+		// First read in the batch, schedule the flush.
+		b.pending = make(map[string][]chan batchResult[T])
+		time.AfterFunc(b.window, b.flush)
+	}
+	b.pending[key] = append(b.pending[key], ch)
+	b.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return nil, -1, ctx.Err()
+	case r := <-ch:
+		return r.entry, r.ttl, r.err
+	}
+}
+
+func (b *batcher[T]) flush() {
+	b.mu.Lock()
+	pending := b.pending
+	b.pending = nil
+	b.mu.Unlock()
+
+	keys := make([]string, 0, len(pending))
+	for key := range pending {
+		keys = append(keys, key)
+	}
+
+	var entries map[string]*CacheEntry[T]
+	var ttls map[string]time.Duration
+	var err error
+	if b.ttlBackend != nil {
+		entries, ttls, err = b.ttlBackend.GetMultiWithTTL(b.ctx, keys)
+	} else {
+		entries, err = b.backend.GetMulti(b.ctx, keys)
+	}
+	for key, chs := range pending {
+		ttl, ok := ttls[key]
+		if !ok {
+			ttl = -1
+		}
+		for _, ch := range chs {
+			ch <- batchResult[T]{entry: entries[key], ttl: ttl, err: err}
+		}
+	}
+}
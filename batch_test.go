@@ -0,0 +1,126 @@
+package smartcache_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/m-zajac/smartcache"
+	"github.com/m-zajac/smartcache/backend/lru"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingBackend counts calls to the wrapped lru backend.
+type countingBackend struct {
+	*lru.Backend[string]
+	gets      atomic.Int32
+	multiGets atomic.Int32
+}
+
+func (b *countingBackend) Get(ctx context.Context, key string) (*smartcache.CacheEntry[string], error) {
+	b.gets.Add(1)
+	return b.Backend.Get(ctx, key)
+}
+
+func (b *countingBackend) GetMulti(ctx context.Context, keys []string) (map[string]*smartcache.CacheEntry[string], error) {
+	b.multiGets.Add(1)
+	return b.Backend.GetMulti(ctx, keys)
+}
+
+func newCountingBackend(t testing.TB) *countingBackend {
+	backend, err := lru.NewBackend[string](1000)
+	require.NoError(t, err)
+
+	return &countingBackend{Backend: backend}
+}
+
+func TestCache_BatchedGet(t *testing.T) {
+	t.Parallel()
+
+	backend := newCountingBackend(t)
+	cache, err := smartcache.New[string](
+		backend,
+		smartcache.WithBatchedGet(100*time.Millisecond),
+	)
+	require.NoError(t, err)
+
+	fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		v := "value " + key
+		return &smartcache.FetchResult[string]{
+			Data: &v,
+		}, nil
+	}
+
+	numKeys := 10
+	var wg sync.WaitGroup
+	for i := 0; i < numKeys; i++ {
+		wg.Add(1)
+		go func(key string) {
+			defer wg.Done()
+
+			result, err := cache.Get(context.Background(), key, fetchFunc)
+			assert.NoError(t, err)
+			assert.Equal(t, smartcache.Miss, result.Type)
+			assert.Equal(t, "value "+key, *result.Data)
+		}(fmt.Sprintf("key%d", i))
+	}
+	wg.Wait()
+
+	// All concurrent reads should be collapsed into a single backend call.
+	assert.EqualValues(t, 1, backend.multiGets.Load())
+	assert.EqualValues(t, 0, backend.gets.Load())
+}
+
+func TestCache_BatchedGetUnsupportedBackend(t *testing.T) {
+	t.Parallel()
+
+	backend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+
+	// Hide the GetMulti method.
+	_, err = smartcache.New[string](
+		struct{ smartcache.Backend[string] }{backend},
+		smartcache.WithBatchedGet(time.Millisecond),
+	)
+	assert.Error(t, err)
+}
+
+func BenchmarkCache_BatchedGet(b *testing.B) {
+	for _, batched := range []bool{false, true} {
+		b.Run(fmt.Sprintf("batched=%t", batched), func(b *testing.B) {
+			var options []smartcache.Option
+			if batched {
+				options = append(options, smartcache.WithBatchedGet(500*time.Microsecond))
+			}
+
+			backend := newCountingBackend(b)
+			cache, err := smartcache.New[string](backend, options...)
+			require.NoError(b, err)
+			defer cache.Close()
+
+			data := "some data"
+			fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+				return &smartcache.FetchResult[string]{
+					Data: &data,
+				}, nil
+			}
+
+			var counter atomic.Int64
+			b.SetParallelism(64)
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				ctx := context.Background()
+				for pb.Next() {
+					key := fmt.Sprintf("key%d", counter.Add(1)%100)
+					_, _ = cache.Get(ctx, key, fetchFunc)
+				}
+			})
+			b.ReportMetric(float64(backend.gets.Load()+backend.multiGets.Load())/float64(b.N), "backend-calls/op")
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package smartcache
+
+import (
+	"math"
+	"sync"
+)
+
+// bloomFilter tells keys that are definitely not stored in the backend, see `WithBloomFilter`.
+//
+// Bloom filters can't remove keys, so the filter keeps two generations: keys are added to the current one,
+// and looked up in both. When the current generation is full, it replaces the previous one, and a new one is started.
+// Deleted and evicted keys are dropped this way eventually, and the filter doesn't fill up.
+// Keys found in the backend are added to the current generation again, so keys in use are never dropped.
+type bloomFilter struct {
+	bits     uint64 // Number of bits in a generation.
+	hashes   uint64
+	capacity uint
+
+	mu       sync.Mutex
+	current  []uint64
+	previous []uint64
+	added    uint
+}
+
+// newBloomFilter creates a filter with generations sized for the expected number of keys and the false positive rate.
+func newBloomFilter(expectedKeys uint, fpRate float64) *bloomFilter {
+	n := float64(expectedKeys)
+	bits := uint64(math.Ceil(-n * math.Log(fpRate) / (math.Ln2 * math.Ln2)))
+	hashes := uint64(math.Round(float64(bits) / n * math.Ln2))
+	if hashes < 1 {
+		hashes = 1
+	}
+
+	return &bloomFilter{
+		bits:     bits,
+		hashes:   hashes,
+		capacity: expectedKeys,
+		current:  make([]uint64, (bits+63)/64),
+		previous: make([]uint64, (bits+63)/64),
+	}
+}
+
+// mayContain checks if the key may have been added. False means the key was definitely not added.
+func (f *bloomFilter) mayContain(key string) bool {
+	h1, h2 := bloomHashes(key)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.has(f.current, h1, h2) || f.has(f.previous, h1, h2)
+}
+
+// add adds the key to the current generation, unless it's already there.
+func (f *bloomFilter) add(key string) {
+	h1, h2 := bloomHashes(key)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.has(f.current, h1, h2) {
+		return
+	}
+	if f.added >= f.capacity {
+		f.previous, f.current = f.current, f.previous
+		for i := range f.current {
+			f.current[i] = 0
+		}
+		f.added = 0
+	}
+	for i := uint64(0); i < f.hashes; i++ {
+		bit := (h1 + i*h2) % f.bits
+		f.current[bit/64] |= 1 << (bit % 64)
+	}
+	f.added++
+}
+
+func (f *bloomFilter) has(generation []uint64, h1, h2 uint64) bool {
+	for i := uint64(0); i < f.hashes; i++ {
+		bit := (h1 + i*h2) % f.bits
+		if generation[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// bloomHashes returns two independent hashes of the key, combined to get the bit positions (double hashing).
+func bloomHashes(key string) (h1, h2 uint64) {
+	h := fnvHash(key)
+
+	return h, mix64(h) | 1
+}
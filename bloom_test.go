@@ -0,0 +1,56 @@
+package smartcache
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBloomFilter(t *testing.T) {
+	const keys = 1000
+	const fpRate = 0.01
+	f := newBloomFilter(keys, fpRate)
+
+	for i := 0; i < keys; i++ {
+		f.add(fmt.Sprintf("key%d", i))
+	}
+	for i := 0; i < keys; i++ {
+		assert.True(t, f.mayContain(fmt.Sprintf("key%d", i)), "no false negatives")
+	}
+
+	var falsePositives int
+	for i := 0; i < 10*keys; i++ {
+		if f.mayContain(fmt.Sprintf("other%d", i)) {
+			falsePositives++
+		}
+	}
+	assert.Less(t, float64(falsePositives)/(10*keys), 2*fpRate)
+}
+
+func TestBloomFilter_Generations(t *testing.T) {
+	f := newBloomFilter(10, 0.01)
+
+	f.add("kept")
+	for i := 0; i < 9; i++ {
+		f.add(fmt.Sprintf("first%d", i))
+	}
+
+	// The first generation is full, its keys are still found in the previous generation.
+	for i := 0; i < 10; i++ {
+		f.add(fmt.Sprintf("second%d", i))
+	}
+	assert.True(t, f.mayContain("kept"))
+	assert.True(t, f.mayContain("first0"))
+
+	// Keys found in the previous generation can be added to the current one, so they're not dropped.
+	f.add("kept")
+	for i := 0; i < 9; i++ {
+		f.add(fmt.Sprintf("third%d", i))
+	}
+	f.add("fourth")
+	assert.True(t, f.mayContain("kept"))
+	assert.True(t, f.mayContain("fourth"))
+	assert.False(t, f.mayContain("first0"), "dropped with its generation")
+	assert.False(t, f.mayContain("second0"), "dropped with its generation")
+}
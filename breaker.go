@@ -0,0 +1,139 @@
+package smartcache
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned on a miss, when fetches are short-circuited by the circuit breaker.
+// See `WithCircuitBreaker`.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// BreakerState is the state of the circuit breaker, see `WithCircuitBreaker`.
+type BreakerState int
+
+const (
+	// BreakerClosed lets all fetches through.
+	BreakerClosed BreakerState = iota
+	// BreakerOpen short-circuits all fetches until the cooldown passes.
+	BreakerOpen
+	// BreakerHalfOpen lets a single probe fetch through, which decides if the breaker closes or opens again.
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "Closed"
+	case BreakerOpen:
+		return "Open"
+	case BreakerHalfOpen:
+		return "HalfOpen"
+	default:
+		return fmt.Sprintf("Unknown(%d)", int(s))
+	}
+}
+
+// circuitBreaker counts consecutive fetch failures, and short-circuits fetches once there are too many.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+	opens    uint64
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+	}
+}
+
+// allow checks if a fetch can be made. In the half-open state only one fetch at a time is allowed.
+// Every allowed fetch has to be followed by `done` or `abandon`.
+func (b *circuitBreaker) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if now.Sub(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state = BreakerHalfOpen
+		b.probing = true
+		return true
+	case BreakerHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+// done records the result of an allowed fetch.
+func (b *circuitBreaker) done(now time.Time, failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerHalfOpen {
+		b.probing = false
+		if failed {
+			b.open(now)
+		} else {
+			b.state = BreakerClosed
+			b.failures = 0
+		}
+		return
+	}
+
+	if !failed {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.state == BreakerClosed && b.failures >= b.threshold {
+		b.open(now)
+	}
+}
+
+// abandon ends an allowed fetch without recording its result, e.g. when the caller gave up on it,
+// which says nothing about the upstream. In the half-open state, the next fetch is let through as a probe.
+func (b *circuitBreaker) abandon() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerHalfOpen {
+		b.probing = false
+	}
+}
+
+func (b *circuitBreaker) open(now time.Time) {
+	b.state = BreakerOpen
+	b.openedAt = now
+	b.opens++
+}
+
+// snapshot returns the state and the number of times the breaker opened.
+func (b *circuitBreaker) snapshot(now time.Time) (BreakerState, uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state := b.state
+	if state == BreakerOpen && now.Sub(b.openedAt) >= b.cooldown {
+		// The next fetch will be a probe.
+		state = BreakerHalfOpen
+	}
+
+	return state, b.opens
+}
@@ -0,0 +1,185 @@
+package smartcache_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/m-zajac/smartcache"
+	"github.com/m-zajac/smartcache/backend/lru"
+	"github.com/m-zajac/smartcache/clocktest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache_CircuitBreaker(t *testing.T) {
+	t.Parallel()
+
+	backend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+
+	_, err = smartcache.New[string](backend, smartcache.WithCircuitBreaker(0, time.Minute))
+	require.Error(t, err)
+	_, err = smartcache.New[string](backend, smartcache.WithCircuitBreaker(1, 0))
+	require.Error(t, err)
+
+	const primTTL = time.Minute
+	const secTTL = time.Hour
+	const cooldown = 10 * time.Second
+	clock := clocktest.New(time.Now())
+	cache, err := smartcache.New[string](
+		backend,
+		smartcache.WithTTL(primTTL, secTTL),
+		smartcache.WithCircuitBreaker(2, cooldown),
+		smartcache.WithClock(clock),
+	)
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	fetchErr := errors.New("upstream down")
+	var fail atomic.Bool
+	var calls atomic.Int32
+	probeStarted := make(chan struct{}, 1)
+	var releaseProbe chan struct{}
+	fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		calls.Add(1)
+		if releaseProbe != nil {
+			probeStarted <- struct{}{}
+			<-releaseProbe
+		}
+		if fail.Load() {
+			return nil, fetchErr
+		}
+		data := "data " + key
+		return &smartcache.FetchResult[string]{Data: &data}, nil
+	}
+
+	ctx := context.Background()
+	old := "old"
+	require.NoError(t, backend.Set(ctx, "expired", secTTL, &smartcache.CacheEntry[string]{
+		Data:    &old,
+		Created: clock.Now().Add(-2 * secTTL),
+	}))
+
+	// Consecutive failures open the breaker.
+	fail.Store(true)
+	for _, key := range []string{"a", "b"} {
+		_, err := cache.Get(ctx, key, fetchFunc)
+		require.ErrorIs(t, err, fetchErr)
+	}
+	assert.Equal(t, smartcache.BreakerOpen, cache.Stats().BreakerState)
+	assert.EqualValues(t, 1, cache.Stats().BreakerOpens)
+
+	// While open, fetches are short-circuited, expired data is served if available.
+	_, err = cache.Get(ctx, "c", fetchFunc)
+	assert.ErrorIs(t, err, smartcache.ErrCircuitOpen)
+	result, err := cache.Get(ctx, "expired", fetchFunc)
+	assert.ErrorIs(t, err, smartcache.ErrCircuitOpen)
+	assert.Equal(t, smartcache.StaleHit, result.Type)
+	assert.Equal(t, old, *result.Data)
+	assert.EqualValues(t, 2, calls.Load())
+
+	// After the cooldown, a failed probe opens the breaker again.
+	clock.Add(cooldown)
+	assert.Equal(t, smartcache.BreakerHalfOpen, cache.Stats().BreakerState)
+	_, err = cache.Get(ctx, "c", fetchFunc)
+	assert.ErrorIs(t, err, fetchErr)
+	assert.Equal(t, smartcache.BreakerOpen, cache.Stats().BreakerState)
+	assert.EqualValues(t, 2, cache.Stats().BreakerOpens)
+	assert.EqualValues(t, 3, calls.Load())
+
+	// Only a single probe runs at a time, and a successful one closes the breaker.
+	clock.Add(cooldown)
+	fail.Store(false)
+	releaseProbe = make(chan struct{})
+	probeDone := make(chan error)
+	go func() {
+		_, err := cache.Get(ctx, "c", fetchFunc)
+		probeDone <- err
+	}()
+	<-probeStarted
+	_, err = cache.Get(ctx, "d", fetchFunc)
+	assert.ErrorIs(t, err, smartcache.ErrCircuitOpen)
+	close(releaseProbe)
+	require.NoError(t, <-probeDone)
+	releaseProbe = nil
+
+	assert.Equal(t, smartcache.BreakerClosed, cache.Stats().BreakerState)
+	result, err = cache.Get(ctx, "d", fetchFunc)
+	require.NoError(t, err)
+	assert.Equal(t, smartcache.Miss, result.Type)
+	assert.EqualValues(t, 5, calls.Load())
+}
+
+func TestCache_CircuitBreakerCancelledCaller(t *testing.T) {
+	t.Parallel()
+
+	backend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+
+	const cooldown = 10 * time.Second
+	clock := clocktest.New(time.Now())
+	limiter := smartcache.NewLimiter(1)
+	cache, err := smartcache.New[string](
+		backend,
+		smartcache.WithCircuitBreaker(1, cooldown),
+		smartcache.WithFetchLimiter(limiter),
+		smartcache.WithClock(clock),
+	)
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	cancellingFetch := func(cancel context.CancelFunc) smartcache.FetchFunc[string] {
+		return func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+			cancel()
+			return nil, ctx.Err()
+		}
+	}
+
+	// Callers giving up don't open the breaker.
+	callerCtx, cancel := context.WithCancel(context.Background())
+	_, err = cache.Get(callerCtx, "a", cancellingFetch(cancel))
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, smartcache.BreakerClosed, cache.Stats().BreakerState)
+
+	callerCtx, cancel = context.WithCancel(context.Background())
+	_, errs := cache.GetMulti(callerCtx, []string{"b"}, func(ctx context.Context, keys []string) (map[string]*smartcache.FetchResult[string], error) {
+		cancel()
+		return nil, ctx.Err()
+	})
+	assert.ErrorIs(t, errs["b"], context.Canceled)
+	assert.Equal(t, smartcache.BreakerClosed, cache.Stats().BreakerState)
+
+	ctx := context.Background()
+	fetchErr := errors.New("upstream down")
+	_, err = cache.Get(ctx, "c", func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		return nil, fetchErr
+	})
+	require.ErrorIs(t, err, fetchErr)
+	assert.Equal(t, smartcache.BreakerOpen, cache.Stats().BreakerState)
+
+	// A probe whose caller gives up waiting for the limiter doesn't open the breaker again.
+	clock.Add(cooldown)
+	require.NoError(t, limiter.Acquire(ctx))
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, err = cache.Get(timeoutCtx, "c", func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		t.Error("unexpected fetch")
+		return nil, nil
+	})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	limiter.Release()
+	assert.Equal(t, smartcache.BreakerHalfOpen, cache.Stats().BreakerState)
+	assert.EqualValues(t, 1, cache.Stats().BreakerOpens)
+
+	// The next probe is let through.
+	result, err := cache.Get(ctx, "c", func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		data := "data"
+		return &smartcache.FetchResult[string]{Data: &data}, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "data", *result.Data)
+	assert.Equal(t, smartcache.BreakerClosed, cache.Stats().BreakerState)
+}
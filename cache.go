@@ -4,10 +4,76 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"runtime/debug"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// ErrTooManyInFlight is returned on a miss, when the number of keys fetched concurrently reached the limit.
+// See `WithMaxInFlightKeys`.
+var ErrTooManyInFlight = errors.New("too many keys fetched concurrently")
+
+// ErrFetchBudgetExceeded is returned by `GetWithin`, when the fetch didn't finish within the budget.
+var ErrFetchBudgetExceeded = errors.New("fetch budget exceeded")
+
+// ErrFetchInProgress is returned on a miss, when the data is being fetched in the background.
+// See `WithNonBlockingMiss`.
+var ErrFetchInProgress = errors.New("fetch in progress")
+
+// ErrNotSupported is returned when the backend doesn't support the requested operation.
+var ErrNotSupported = errors.New("operation not supported by the backend")
+
+// ErrCloseTimeout is returned by `CloseWithTimeout`, when closing didn't finish within the timeout.
+var ErrCloseTimeout = errors.New("close timed out")
+
+// ErrCacheMiss is returned by `Lookup`, when there's no hot or warm entry for the key.
+var ErrCacheMiss = errors.New("cache miss")
+
+// CachedError wraps an error served from the cache, which was returned by an earlier fetch.
+// Errors returned by fetches made during the call are not wrapped, so `errors.As` tells them apart,
+// while `errors.Is` still matches the original error.
+type CachedError struct {
+	Err error
+}
+
+func (e *CachedError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *CachedError) Unwrap() error {
+	return e.Err
+}
+
+// cachedErr wraps the error of a cached entry, if there is one.
+func cachedErr(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &CachedError{Err: err}
+}
+
+// PanicError is returned when a fetch function panics, so a single failing key doesn't crash the process.
+// Panics in background refreshes are passed to the background error handler.
+type PanicError struct {
+	// Value is the value the function panicked with.
+	Value any
+	// Stack is the stack trace of the panicking goroutine.
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("fetch function panicked: %v", e.Value)
+}
+
+// recoverFetch converts a panic of a fetch function into a `PanicError`. It has to be deferred directly.
+func recoverFetch(err *error) {
+	if r := recover(); r != nil {
+		*err = &PanicError{Value: r, Stack: debug.Stack()}
+	}
+}
+
 type ResultType int
 
 // Result types.
@@ -15,25 +81,41 @@ const (
 	Miss ResultType = iota
 	WarmHit
 	HotHit
+	// StaleHit means that data was served as a fallback, because fetching it or reading it from the backend failed.
+	StaleHit
+	// FallbackHit means that the fetch failed with nothing cached, and the fallback value was served.
+	// See `WithFallback`.
+	FallbackHit
 )
 
 func (t ResultType) String() string {
 	switch t {
 	case Miss:
-		return "miss"
+		return "Miss"
 	case WarmHit:
-		return "warmHit"
+		return "WarmHit"
 	case HotHit:
-		return "hotHit"
+		return "HotHit"
+	case StaleHit:
+		return "StaleHit"
+	case FallbackHit:
+		return "FallbackHit"
 	default:
-		return ""
+		return fmt.Sprintf("Unknown(%d)", int(t))
 	}
 }
 
 type Result[T any] struct {
 	Data *T
 	Type ResultType
-	Age  time.Duration
+	// Age is the age of the served data at the time of the call. It's zero for a Miss, as the data was just fetched.
+	Age time.Duration
+	// CreatedAt is the creation time of the served data, e.g. for building Last-Modified headers.
+	// For a Miss, it's the creation time of the fetched data.
+	CreatedAt time.Time
+	// RefreshErr is the error of the last failed background refresh of a WarmHit.
+	// It is non-fatal, the data can still be used. Only set if `WithRefreshErrors` option is used.
+	RefreshErr error
 }
 
 // Backend can store and retrieve cache data by key.
@@ -44,13 +126,64 @@ type Backend[T any] interface {
 	// Set stores cache data by key.
 	// It should obey the ttl value without inspecting the entry.
 	Set(ctx context.Context, key string, ttl time.Duration, data *CacheEntry[T]) error
+	// Delete removes cache data by key. Deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
 	// Closes the backend.
 	Close()
 }
 
+// TTLAwareBackend is an optional interface of a backend that can report the remaining TTL of stored entries.
+// The cache prefers the remaining TTL over its own computation when classifying entries, so changes made
+// by external processes (like an EXPIRE command) or clock skew are taken into account.
+type TTLAwareBackend[T any] interface {
+	// GetWithTTL returns cache data by key and its remaining TTL observed by the backend.
+	// Negative TTL means it's unknown.
+	GetWithTTL(ctx context.Context, key string) (*CacheEntry[T], time.Duration, error)
+}
+
+// RawBackend is an optional interface of a backend that can return the stored data without deserializing it.
+type RawBackend interface {
+	// GetRaw returns the stored representation of the entry. The bool is false if the key is not found.
+	GetRaw(ctx context.Context, key string) ([]byte, bool, error)
+}
+
+// ListingBackend is an optional interface of a backend that can count and enumerate stored keys, e.g. for admin tooling.
+type ListingBackend interface {
+	// Len returns the number of stored keys.
+	Len(ctx context.Context) (int, error)
+	// Keys returns the stored keys, in no particular order.
+	Keys(ctx context.Context) ([]string, error)
+}
+
+// CapacityBackend is an optional interface of a listing backend holding a bounded number of entries.
+// See `Cache.Utilization`.
+type CapacityBackend interface {
+	ListingBackend
+	// Cap returns the maximum number of stored keys.
+	Cap() int
+}
+
+// ResizableBackend is an optional interface of a backend that can change its size at runtime.
+// See `Cache.ResizeBackend`.
+type ResizableBackend interface {
+	// Resize changes the maximum number of stored keys, evicting entries if needed, and returns the number of evicted ones.
+	Resize(size int) int
+}
+
+// PurgeableBackend is an optional interface of a backend that can remove all stored entries at once.
+// See `Cache.Purge`.
+type PurgeableBackend interface {
+	// Purge removes all entries of the backend, and only them: other data sharing the storage must be kept.
+	Purge(ctx context.Context) error
+}
+
 // FetchFunc fetches data to be cached.
 type FetchFunc[T any] func(ctx context.Context, key string) (*FetchResult[T], error)
 
+// RefreshProbeFunc cheaply checks if the data changed since the current entry was fetched,
+// e.g. by comparing versions. See `WithRefreshProbe`.
+type RefreshProbeFunc[T any] func(ctx context.Context, key string, current *T) (changed bool, err error)
+
 // FetchResult is a container for cached item.
 type FetchResult[T any] struct {
 	// Data contains the result to store in cache.
@@ -58,34 +191,125 @@ type FetchResult[T any] struct {
 	// CreatedAt is a time when the data was fetched as fresh.
 	// Optional, defaults to the function call time.
 	CreatedAt time.Time
+	// Type optionally overrides the result type reported by Get on a miss,
+	// e.g. `WarmHit` when the fetch itself was served by an intermediate cache.
+	// It only affects the returned result, the entry is stored and expires according to the TTLs as usual.
+	// Ignored for background refreshes.
+	Type ResultType
+	// TTL optionally overrides the TTLs for this entry, e.g. with a cache-control hint of the upstream.
+	// The entry expires at CreatedAt + TTL, which is stored as its `CacheEntry.FixedExpiration`.
+	// Like other entries with a fixed expiration, it's served as a HotHit until it expires, regardless of the primary TTL,
+	// and then it's a Miss - there's no warm period with a background refresh.
+	// A negative TTL, or one already passed since CreatedAt, is ignored with a warning written to the logger
+	// set with `WithLogger`, and the entry expires according to the cache TTLs.
+	TTL time.Duration
 }
 
 // ErrorTTLFunc defines if and for how long to cache errors returned by `FetchFunc`.
 // If it returns 0, error will not be cached.
 type ErrorTTLFunc func(err error) time.Duration
 
+// KeyedErrorTTLFunc works like ErrorTTLFunc, but gets the key of the failed fetch as well.
+type KeyedErrorTTLFunc func(key string, err error) time.Duration
+
+// KeyFreshnessFunc decides if data for the key can be served after the primary TTL expired, while it's refreshed in the background.
+type KeyFreshnessFunc func(key string) (warmEnabled bool)
+
 // BackgroundErrorHandler is a handler for `FetchFunc` errors, if they happen during a background refresh.
 type BackgroundErrorHandler func(err error)
 
 type request struct {
+	key           string
 	requests      uint
 	lock          chan struct{}
 	updatePending bool
+	// fetchPending is set while the key lock is held by a fetch started by a non-blocking miss.
+	fetchPending bool
+	// fetches is increased every time data fetched while holding the lock is stored.
+	fetches atomic.Uint64
+	// deletes is increased every time the key is deleted.
+	deletes atomic.Uint64
+	// writes is increased every time data is written with `Update`, or invalidated by another instance,
+	// so pending refreshes don't overwrite it.
+	writes atomic.Uint64
+	// idlePrev and idleNext link idle requests kept in the shard, see `requestShard`.
+	idlePrev, idleNext *request
 }
 
 // Cache stores the internal in-memory LRU cache and is responsible for coordinating the cache access.
 type Cache[T any] struct {
 	backend  Backend[T]
-	requests chan map[string]*request
+	batcher  *batcher[T]
+	mirror   *mirror[T]
+	mutation *mutationDetector[T]
+	// fetchCheck detects keys requested with different fetch functions, if enabled.
+	fetchCheck *fetchFuncChecker
+	// bloom predicts keys missing in the backend, if enabled.
+	bloom *bloomFilter
+	// breaker short-circuits fetches on misses, if enabled.
+	breaker *circuitBreaker
+	probe   RefreshProbeFunc[T]
+	// fallback returns the value served when a fetch fails with nothing cached, if set.
+	fallback func(key string) *T
+
+	// refreshesFrom is the time before which warm hits don't trigger background refreshes.
+	refreshesFrom time.Time
+
+	// shards hold the requests for keys. A shard is owned by whoever received it from the channel.
+	shards []chan *requestShard
+
+	// refreshQueue holds refreshes waiting for a worker, if the worker pool is enabled.
+	refreshQueue *refreshQueue[T]
+	// refreshSlots limit the number of running background refreshes, if enabled.
+	refreshSlots chan struct{}
 
 	config config
+	stats  stats
+
+	// inFlight is a number of keys currently fetched on a miss.
+	inFlight atomic.Int64
 
 	// ctx is the parent context of background refreshes.
 	// It will be closed when `Close` method is called.
 	ctx       context.Context
 	ctxCancel func()
 
-	wg sync.WaitGroup
+	// refreshCtx is the parent context of background refreshes, derived from ctx.
+	// It is replaced when `CancelAllRefreshes` is called.
+	// refreshGen is increased on every such call.
+	// Both fields can be read while owning any of the shards, and written while owning all of them.
+	refreshCtx    context.Context
+	refreshCancel func()
+	refreshGen    uint64
+
+	// requestPool reuses requests with a free lock, so the hot path doesn't allocate.
+	requestPool sync.Pool
+
+	// tracedKeys are keys with decision tracing enabled, see `TraceKey`.
+	// tracedCount allows skipping the map lookup when no keys are traced.
+	tracedMu    sync.RWMutex
+	tracedKeys  map[string]struct{}
+	tracedCount atomic.Int32
+
+	// epoch is the current generation of entries, see `BumpEpoch`.
+	epoch atomic.Uint64
+
+	// events distributes events to subscribers, see `Subscribe`.
+	events eventHub
+
+	// refreshErrs hold errors of the last failed background refresh per key, see `WithRefreshErrors`.
+	refreshErrsMu sync.Mutex
+	refreshErrs   map[string]error
+
+	// staleServes count warm and stale hits per key since the last successful refresh, see `StaleServeCount`.
+	staleServesMu sync.Mutex
+	staleServes   map[string]int
+
+	// closeMu synchronizes registering new operations in wg with `Close`.
+	closeMu sync.RWMutex
+	wg      sync.WaitGroup
+	// backendClosed makes sure the backend is closed once, by whichever close call gets there first.
+	backendClosed sync.Once
 }
 
 // New creates a new cache.
@@ -99,8 +323,11 @@ func New[T any](backend Backend[T], options ...Option) (*Cache[T], error) {
 		primaryTTL:             time.Minute,
 		secondaryTTL:           time.Hour,
 		backgroundFetchTimeout: time.Minute,
-		backgroundErrorHandler: func(err error) {},                         // Empty function to avoid nil checks.
-		errorTTLFunc:           func(err error) time.Duration { return 0 }, // Don't cache errors.
+		backgroundErrorHandler: func(err error) {}, // Empty function to avoid nil checks.
+		shardCount:             1,
+		clock:                  realClock{},
+		heapUsage:              heapAlloc,
+		heapSampleInterval:     defaultHeapSampleInterval,
 	}
 
 	// Apply all user options.
@@ -110,177 +337,1632 @@ func New[T any](backend Backend[T], options ...Option) (*Cache[T], error) {
 		}
 	}
 
-	requestsCh := make(chan map[string]*request, 1)
-	requestsCh <- make(map[string]*request)
+	if cfg.shardHash == nil {
+		cfg.shardHash = fnvHash
+	}
+	if cfg.errorTTLFunc == nil {
+		// Errors are cached only with the error TTLs.
+		errorTTL := cfg.errorPrimaryTTL
+		cfg.errorTTLFunc = func(key string, err error) time.Duration { return errorTTL }
+	}
+	shards := make([]chan *requestShard, cfg.shardCount)
+	for i := range shards {
+		shards[i] = make(chan *requestShard, 1)
+		shards[i] <- newRequestShard(cfg.expectedKeys / cfg.shardCount)
+	}
+
+	var probe RefreshProbeFunc[T]
+	if cfg.refreshProbe != nil {
+		p, ok := cfg.refreshProbe.(RefreshProbeFunc[T])
+		if !ok {
+			return nil, fmt.Errorf("invalid config: refresh probe type %T doesn't match the cache type", cfg.refreshProbe)
+		}
+		probe = p
+	}
+
+	var fallback func(key string) *T
+	if cfg.fallback != nil {
+		f, ok := cfg.fallback.(func(key string) *T)
+		if !ok {
+			return nil, fmt.Errorf("invalid config: fallback type %T doesn't match the cache type", cfg.fallback)
+		}
+		fallback = f
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
-	return &Cache[T]{
-		backend:   backend,
-		requests:  requestsCh,
-		config:    cfg,
-		ctx:       ctx,
-		ctxCancel: cancel,
-	}, nil
+
+	var b *batcher[T]
+	if cfg.batchWindow > 0 {
+		mb, ok := backend.(MultiGetBackend[T])
+		if !ok {
+			cancel()
+			return nil, errors.New("invalid config: batched get requires a backend implementing MultiGetBackend")
+		}
+		if _, ok := backend.(TTLAwareBackend[T]); ok {
+			if _, ok := backend.(MultiGetWithTTLBackend[T]); !ok {
+				cancel()
+				return nil, errors.New("invalid config: batched get with a TTLAwareBackend requires it to implement MultiGetWithTTLBackend")
+			}
+		}
+		b = newBatcher(ctx, mb, cfg.batchWindow)
+	}
+
+	var m *mirror[T]
+	if cfg.resilienceMirrorSize > 0 {
+		m = newMirror[T](cfg.resilienceMirrorSize)
+	}
+
+	var md *mutationDetector[T]
+	if cfg.mutationDetection {
+		md = newMutationDetector[T]()
+	}
+
+	var fc *fetchFuncChecker
+	if cfg.fetchFuncCheck {
+		fc = newFetchFuncChecker()
+	}
+
+	var bf *bloomFilter
+	if cfg.bloomExpectedKeys > 0 {
+		bf = newBloomFilter(cfg.bloomExpectedKeys, cfg.bloomFPRate)
+	}
+
+	var slots chan struct{}
+	if cfg.maxBackgroundRefreshes > 0 {
+		if cfg.refreshWorkers > 0 {
+			cancel()
+			return nil, errors.New("invalid config: max background refreshes can't be used with refresh workers")
+		}
+		slots = make(chan struct{}, cfg.maxBackgroundRefreshes)
+	}
+
+	var cb *circuitBreaker
+	if cfg.breakerThreshold > 0 {
+		cb = newCircuitBreaker(cfg.breakerThreshold, cfg.breakerCooldown)
+	}
+
+	var rq *refreshQueue[T]
+	if cfg.refreshWorkers > 0 {
+		rq = newRefreshQueue[T](cfg.refreshQueueSize)
+	} else if cfg.refreshQueueSize > 0 {
+		cancel()
+		return nil, errors.New("invalid config: refresh queue requires refresh workers")
+	}
+
+	var invalidations <-chan string
+	if cfg.invalidationBus != nil {
+		keys, err := cfg.invalidationBus.Subscribe(ctx)
+		if err != nil {
+			cancel()
+			return nil, fmt.Errorf("subscribing to invalidations: %w", err)
+		}
+		invalidations = keys
+	}
+
+	refreshCtx, refreshCancel := context.WithCancel(ctx)
+	sc := &Cache[T]{
+		backend:       backend,
+		batcher:       b,
+		mirror:        m,
+		mutation:      md,
+		fetchCheck:    fc,
+		bloom:         bf,
+		breaker:       cb,
+		probe:         probe,
+		fallback:      fallback,
+		refreshesFrom: cfg.clock.Now().Add(cfg.startupRefreshDelay),
+		refreshQueue:  rq,
+		refreshSlots:  slots,
+		shards:        shards,
+		config:        cfg,
+		ctx:           ctx,
+		ctxCancel:     cancel,
+		refreshCtx:    refreshCtx,
+		refreshCancel: refreshCancel,
+		requestPool:   sync.Pool{New: newRequest},
+	}
+
+	for i := 0; i < cfg.refreshWorkers; i++ {
+		sc.wg.Add(1)
+		go sc.runRefreshWorker()
+	}
+	if cfg.memoryThreshold > 0 {
+		sc.wg.Add(1)
+		go sc.runHeapSampler()
+	}
+	if invalidations != nil {
+		sc.wg.Add(1)
+		go sc.runInvalidations(invalidations)
+	}
+
+	return sc, nil
 }
 
-// Close closes the cache.
+// Close closes the cache. It waits for calls and background refreshes in progress, then closes the backend.
 func (sc *Cache[T]) Close() {
-	sc.ctxCancel()
+	sc.cancel()
 	sc.wg.Wait()
+	sc.closeBackend()
+}
+
+// CloseWithTimeout works like Close, but waits for calls and background refreshes in progress, and for closing
+// the backend, at most for the timeout. If they don't finish in time, `ErrCloseTimeout` is returned
+// and they're left to finish in the background.
+func (sc *Cache[T]) CloseWithTimeout(timeout time.Duration) error {
+	sc.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		sc.wg.Wait()
+		sc.closeBackend()
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		return nil
+	case <-timer.C:
+		return ErrCloseTimeout
+	}
+}
+
+// cancel stops accepting new calls and cancels the ones in progress.
+func (sc *Cache[T]) cancel() {
+	sc.closeMu.Lock()
+	sc.ctxCancel()
+	sc.closeMu.Unlock()
+}
+
+func (sc *Cache[T]) closeBackend() {
+	sc.backendClosed.Do(sc.backend.Close)
+}
+
+// CancelAllRefreshes cancels contexts of all in-flight background refreshes, without closing the cache.
+// Cached data is still served, and new refreshes will be started by subsequent warm hits.
+func (sc *Cache[T]) CancelAllRefreshes() {
+	all := make([]*requestShard, len(sc.shards))
+	for i, shard := range sc.shards {
+		all[i] = <-shard
+	}
+	defer func() {
+		for i, shard := range sc.shards {
+			shard <- all[i]
+		}
+	}()
+
+	sc.refreshCancel()
+	sc.refreshCtx, sc.refreshCancel = context.WithCancel(sc.ctx)
+	sc.refreshGen++
+
+	for _, rs := range all {
+		for _, req := range rs.requests {
+			req.updatePending = false
+		}
+	}
+}
+
+// TraceKey enables or disables tracing of the decisions made by Get for the key.
+// While enabled, the cache logs which path was taken for the key and the age of the cached entry.
+// Logs are written to the logger set with `WithLogger`, without a logger tracing has no effect.
+func (sc *Cache[T]) TraceKey(key string, on bool) {
+	sc.tracedMu.Lock()
+	defer sc.tracedMu.Unlock()
+
+	if sc.tracedKeys == nil {
+		sc.tracedKeys = make(map[string]struct{})
+	}
+
+	_, traced := sc.tracedKeys[key]
+	switch {
+	case on && !traced:
+		sc.tracedKeys[key] = struct{}{}
+		sc.tracedCount.Add(1)
+	case !on && traced:
+		delete(sc.tracedKeys, key)
+		sc.tracedCount.Add(-1)
+	}
+}
+
+// GetRaw returns the entry as stored by the backend, without deserializing it. It's meant for debugging.
+// The bool is false if the key is not found. If the backend doesn't implement `RawBackend`, `ErrNotSupported` is returned.
+func (sc *Cache[T]) GetRaw(ctx context.Context, key string) ([]byte, bool, error) {
+	rb, ok := sc.backend.(RawBackend)
+	if !ok {
+		return nil, false, ErrNotSupported
+	}
+
+	return rb.GetRaw(ctx, key)
+}
+
+// Stats returns a snapshot of the cache usage counters.
+func (sc *Cache[T]) Stats() Stats {
+	s := sc.stats.snapshot()
+	if sc.breaker != nil {
+		s.BreakerState, s.BreakerOpens = sc.breaker.snapshot(sc.now())
+	}
+
+	return s
 }
 
 // Get retrieves a value from the cache for a given key. If the value is not found or expired, the function fetches the data using the provided fetchFunc and updates the cache accordingly.
-func (sc *Cache[T]) Get(ctx context.Context, key string, fetchFunc FetchFunc[T]) (Result[T], error) {
+//
+// Serving a HotHit doesn't allocate, as long as the backend's Get doesn't allocate either.
+// The call can be modified with options, like `NoStale`.
+func (sc *Cache[T]) Get(ctx context.Context, key string, fetchFunc FetchFunc[T], opts ...GetOpt) (Result[T], error) {
+	return sc.get(ctx, key, fetchFunc, newGetOptions[T](opts))
+}
+
+// newGetOptions merges the options of a Get call.
+func newGetOptions[T any](opts []GetOpt) getOptions[T] {
+	var o getOptions[T]
+	for _, opt := range opts {
+		o.noStale = o.noStale || opt.noStale
+		if opt.coordinationKey != "" {
+			o.coordinationKey = opt.coordinationKey
+		}
+	}
+
+	return o
+}
+
+// GetOpt modifies a single Get call.
+type GetOpt struct {
+	noStale         bool
+	coordinationKey string
+}
+
+// NoStale makes the Get call treat data past the primary TTL as a Miss and fetch it synchronously,
+// e.g. for critical reads which must never use stale data. Stale data is not served as a fallback either.
+// Other calls for the key are not affected, and the stored entry is not modified.
+func NoStale() GetOpt {
+	return GetOpt{noStale: true}
+}
+
+// CoordinationKey makes the Get call coordinate with other calls on the given key instead of the storage key.
+// Calls sharing the coordination key wait for each other: only one of them fetches at a time, and warm hits
+// trigger at most one background refresh. The data is still read from and written under the storage key.
+//
+// The coordination key can be coarser than the storage key, e.g. a tenant ID for keys prefixed with it,
+// to fetch a single key of the tenant at a time. Calls waiting for the lock read the backend again, so they
+// get the data fetched for their key in the meantime. It can be finer as well, e.g. a storage key with
+// a request variant, so calls of different variants don't wait for each other, and may fetch the same key
+// concurrently. The last stored fetch wins.
+//
+// Coordination keys and storage keys share the same lock space, so a coordination key equal to a storage key
+// coordinates with calls for that key. Other operations, like Set or Delete, always coordinate on the storage key,
+// so they don't wait for calls using a different coordination key.
+func CoordinationKey(key string) GetOpt {
+	return GetOpt{coordinationKey: key}
+}
+
+// GetPreferData works like Get, but prefers usable data over errors: if there's data to return, the error is dropped.
+// This covers entries holding both data and a cached error, and expired data served with `WithServeExpiredOnError`.
+// An error is returned only if there's no data at all.
+func (sc *Cache[T]) GetPreferData(ctx context.Context, key string, fetchFunc FetchFunc[T]) (Result[T], error) {
+	return preferData(sc.get(ctx, key, fetchFunc, getOptions[T]{}))
+}
+
+// preferData drops the error if there's data to return, see `Cache.GetPreferData`.
+func preferData[T any](result Result[T], err error) (Result[T], error) {
+	if err != nil && result.Data != nil {
+		return result, nil
+	}
+
+	return result, err
+}
+
+// GetWithEntry works like Get, but uses the provided entry instead of reading it from the backend.
+// It's useful for batch front-ends, which already read entries on their own, e.g. with a single multi get.
+// A nil entry is treated as missing.
+func (sc *Cache[T]) GetWithEntry(ctx context.Context, key string, entry *CacheEntry[T], fetchFunc FetchFunc[T]) (Result[T], error) {
+	return sc.get(ctx, key, fetchFunc, getOptions[T]{
+		entry:    sc.clampCreated(entry),
+		hasEntry: true,
+	})
+}
+
+// Lookup returns cached data for the key, but never fetches it. A Miss result is returned with `ErrCacheMiss`
+// if the entry is missing or expired. Warm hits don't trigger background refreshes.
+func (sc *Cache[T]) Lookup(ctx context.Context, key string) (Result[T], error) {
 	var result Result[T]
 
-	if err := sc.ctx.Err(); err != nil {
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
+	if err := sc.validateKey(key); err != nil {
 		return result, err
 	}
+
+	entry, classified, err := sc.getEntry(ctx, key)
+	if err != nil {
+		return result, fmt.Errorf("cache backend failed for key '%s': %w", key, err)
+	}
+
+	result.Type = sc.classify(key, classified)
+	if result.Type == Miss {
+		return result, ErrCacheMiss
+	}
+	result.Data = entry.Data
+	result.Age = sc.now().Sub(entry.Created)
+	result.CreatedAt = entry.Created
+
+	return result, cachedErr(entry.Err)
+}
+
+// Peek works like Lookup, but reports a missing or expired entry with found set to false instead of an error.
+// It never fetches data and never starts a background refresh. Cached errors are returned along with found set to true.
+func (sc *Cache[T]) Peek(ctx context.Context, key string) (result Result[T], found bool, err error) {
+	result, err = sc.Lookup(ctx, key)
+	if errors.Is(err, ErrCacheMiss) {
+		return result, false, nil
+	}
+	var cached *CachedError
+	if err != nil && !errors.As(err, &cached) {
+		return result, false, err
+	}
+
+	return result, true, err
+}
+
+// getOptions modify the behavior of a single get call.
+type getOptions[T any] struct {
+	// entry is used instead of reading the backend, if hasEntry is set.
+	entry    *CacheEntry[T]
+	hasEntry bool
+	// budget limits the time of waiting for a foreground fetch, if set.
+	budget time.Duration
+	// noStale makes warm data a Miss, and disables stale fallbacks. See `NoStale`.
+	noStale bool
+	// coordinationKey is the key of the lock used instead of the storage key, if set. See `CoordinationKey`.
+	coordinationKey string
+	// checkedFetchFunc is compared by `WithFetchFuncConsistencyCheck` instead of the fetch function, if set.
+	// Adapters like `KeyCache` set it to the caller's function, as their own closures share the same code.
+	checkedFetchFunc any
+}
+
+func (sc *Cache[T]) get(ctx context.Context, key string, fetchFunc FetchFunc[T], opts getOptions[T]) (Result[T], error) {
+	if sc.config.tracer != nil {
+		return sc.tracedGet(ctx, key, fetchFunc, opts)
+	}
+
+	return sc.getOrFetch(ctx, key, fetchFunc, opts)
+}
+
+// getOrFetch implements get.
+func (sc *Cache[T]) getOrFetch(ctx context.Context, key string, fetchFunc FetchFunc[T], opts getOptions[T]) (Result[T], error) {
+	var result Result[T]
+	var start time.Time
+	if sc.config.observer != nil {
+		start = time.Now()
+	}
+
 	if err := ctx.Err(); err != nil {
 		return result, err
 	}
+	if err := sc.validateKey(key); err != nil {
+		return result, err
+	}
+	if sc.fetchCheck != nil {
+		if opts.checkedFetchFunc != nil {
+			sc.fetchCheck.check(key, opts.checkedFetchFunc)
+		} else {
+			sc.fetchCheck.check(key, fetchFunc)
+		}
+	}
 
-	sc.wg.Add(1)
+	if err := sc.enter(); err != nil {
+		return result, err
+	}
 	defer sc.wg.Done()
 
-	// Obtain request with a lock, increase requests count for the key, obtain a lock for the key.
-	requests := <-sc.requests
-	req, found := requests[key]
-	if found {
-		req.requests++
-	} else {
-		req = &request{
-			requests: 1,
-			lock:     make(chan struct{}, 1),
-		}
-		req.lock <- struct{}{}
-		requests[key] = req
-	}
-	lockCh := req.lock
-	sc.requests <- requests
-
-	// On finish: decrease requests count for key, remove the entry if count goes to 0, release the lock.
+	lockKey := key
+	if opts.coordinationKey != "" {
+		lockKey = opts.coordinationKey
+	}
+
+	nonBlocking := sc.config.nonBlockingMiss && opts.budget == 0
+	if nonBlocking && sc.fetchInProgress(lockKey) {
+		result.Type = Miss
+		return result, ErrFetchInProgress
+	}
+
+	req, joinedAt := sc.acquire(lockKey)
+	handedOff := false // Set if the key lock is passed to a fetch running in the background.
 	defer func() {
-		requests := <-sc.requests
-		requests[key].requests--
-		if requests[key].requests == 0 {
-			delete(requests, key)
+		if !handedOff {
+			sc.release(lockKey, req)
 		}
-		sc.requests <- requests
-
-		lockCh <- struct{}{}
 	}()
 
-	<-lockCh
-
-	entry, err := sc.backend.Get(ctx, key)
+	entry, classified, err := opts.entry, opts.entry, error(nil)
+	if !opts.hasEntry {
+		entry, classified, err = sc.getEntry(ctx, key)
+	}
 	if err != nil {
+		if mirrored := sc.getMirrored(key); mirrored != nil && !opts.noStale {
+			// Degrade to the last locally seen data.
+			result.Type = StaleHit
+			result.Data = mirrored.Data
+			result.Age = sc.now().Sub(mirrored.Created)
+			result.CreatedAt = mirrored.Created
+			sc.observeGet(key, &result, start)
+
+			return result, nil
+		}
+
 		return result, fmt.Errorf("cache backend failed for key '%s': %w", key, err)
 	}
+	defer sc.observeGet(key, &result, start)
+	sc.setMirrored(key, entry)
 
-	switch {
+	resultType := sc.classify(key, classified)
+	if opts.noStale && resultType == WarmHit {
+		resultType = Miss
+	}
+	if sc.isTraced(key) {
+		sc.traceDecision(key, resultType, entry)
+	}
+	if resultType != Miss && req.fetches.Load() != joinedAt {
+		// Data was fetched by another call while this one was waiting for the lock.
+		sc.stats.coalescedFollower.Add(1)
+	}
+
+	switch resultType {
 	// Cached data is stale or missing, fetchFunc has to be called immmediately.
-	case entry == nil || entry.IsExpired(sc.config.secondaryTTL):
+	case Miss:
 		result.Type = Miss
 		result.Age = 0
 
-		fetchCtx, cancel := sc.newForegroundContext(ctx)
-		defer cancel()
+		if limit := sc.config.maxInFlightKeys; limit > 0 {
+			if sc.inFlight.Add(1) > int64(limit) {
+				sc.inFlight.Add(-1)
+				return result, ErrTooManyInFlight
+			}
+			defer func() {
+				// A fetch handed off to the background releases the slot when it finishes.
+				if !handedOff {
+					sc.inFlight.Add(-1)
+				}
+			}()
+		}
+
+		if nonBlocking {
+			handedOff = true
+			sc.fetchNonBlocking(key, req, fetchFunc)
+			return result, ErrFetchInProgress
+		}
 
-		item, err := sc.fetchToCacheEntry(fetchCtx, key, fetchFunc)
+		var item *CacheEntry[T]
+		var fetchedType ResultType
+		if opts.budget > 0 {
+			handedOff = true
+			item, fetchedType, err = sc.fetchWithin(ctx, key, req, fetchFunc, opts.budget)
+		} else {
+			item, fetchedType, err = sc.fetchAndStore(ctx, key, req, fetchFunc)
+		}
 		if err != nil {
+			usable := !opts.noStale && entry != nil && entry.Data != nil
+			shortened := errors.Is(err, ErrFetchBudgetExceeded) || errors.Is(err, ErrCircuitOpen)
+			if shortened && usable {
+				// Expired data can be used until the fetch finishes, or the upstream recovers.
+				result.Type = StaleHit
+				result.Data = entry.Data
+				result.Age = sc.now().Sub(entry.Created)
+				result.CreatedAt = entry.Created
+			}
+			if sc.config.serveExpiredOnError && usable {
+				// The backend still holds expired data, which is better than nothing.
+				result.Type = StaleHit
+				result.Data = entry.Data
+				result.Age = sc.now().Sub(entry.Created)
+				result.CreatedAt = entry.Created
+			}
+			if result.Type == Miss && sc.fallback != nil {
+				// There's nothing to serve, but the caller can still use the default.
+				result.Type = FallbackHit
+				result.Data = sc.fallback(key)
+			}
+
 			return result, err
 		}
 
-		if err := sc.backend.Set(ctx, key, sc.config.secondaryTTL, item); err != nil {
-			return result, fmt.Errorf("failed to update cache for key '%s': %w", key, err)
+		if fetchedType != Miss {
+			result.Type = fetchedType
 		}
-
 		result.Data = item.Data
+		result.CreatedAt = item.Created
 
 		return result, item.Err
 
 	// Cached data is fresh.
-	case !entry.IsExpired(sc.config.primaryTTL):
+	case HotHit:
 		result.Type = HotHit
 		result.Data = entry.Data
-		result.Age = time.Since(entry.Created)
+		result.Age = sc.now().Sub(entry.Created)
+		result.CreatedAt = entry.Created
 
-		return result, entry.Err
+		return result, cachedErr(entry.Err)
 
 	// Cached data can be returned, but needs a refresh in the background.
 	default:
 		result.Type = WarmHit
 		result.Data = entry.Data
-		result.Age = time.Since(entry.Created)
+		result.Age = sc.now().Sub(entry.Created)
+		result.CreatedAt = entry.Created
+		result.RefreshErr = sc.getRefreshErr(key)
+
+		if sc.now().Before(sc.refreshesFrom) {
+			// Background refreshes are deferred right after the start.
+			if sc.isTraced(key) {
+				sc.config.logger.Printf("smartcache: trace key '%s': background refresh deferred after startup", key)
+			}
+			return result, cachedErr(entry.Err)
+		}
 
-		requests := <-sc.requests
-		defer func() { sc.requests <- requests }()
+		shard := sc.shard(lockKey)
+		rs := <-shard
+		defer func() { shard <- rs }()
 
-		if requests[key].updatePending {
+		if req.updatePending {
 			// There's already a background update pending,
 			// the data can be returned immediately.
-			return result, entry.Err
+			if sc.isTraced(key) {
+				sc.config.logger.Printf("smartcache: trace key '%s': background refresh already pending", key)
+			}
+			return result, cachedErr(entry.Err)
+		}
+
+		if sc.refreshSlots != nil {
+			select {
+			case sc.refreshSlots <- struct{}{}: // Released when the refresh finishes.
+			default:
+				sc.stats.refreshesSkipped.Add(1)
+				if sc.isTraced(key) {
+					sc.config.logger.Printf("smartcache: trace key '%s': background refresh skipped, too many running", key)
+				}
+				return result, cachedErr(entry.Err)
+			}
 		}
 
 		// Initiate data refresh in the background.
-		requests[key].updatePending = true
-		requests[key].requests++
+		sc.startRefresh(ctx, req, key, entry, fetchFunc)
 
-		sc.wg.Add(1)
-		go func() {
-			defer sc.wg.Done()
+		return result, cachedErr(entry.Err)
+	}
+}
 
-			bkgCtx, cancel := sc.newBackgroundContext()
-			defer cancel()
+// GetWithin works like Get, but waits for the fetch on a miss at most for the budget, or until ctx is done.
+// If the fetch doesn't finish in time, `ErrFetchBudgetExceeded` is returned, along with expired data as a StaleHit
+// if the backend still has it. The fetch continues in the background and stores the data for later calls.
+func (sc *Cache[T]) GetWithin(ctx context.Context, key string, fetchFunc FetchFunc[T], budget time.Duration) (Result[T], error) {
+	if budget <= 0 {
+		return Result[T]{}, ErrFetchBudgetExceeded
+	}
 
-			item, err := sc.fetchToCacheEntry(bkgCtx, key, fetchFunc)
-			if err != nil {
-				sc.config.backgroundErrorHandler(err)
-			} else {
-				if err = sc.backend.Set(bkgCtx, key, sc.config.secondaryTTL, item); err != nil {
-					sc.config.backgroundErrorHandler(fmt.Errorf("failed to update cache for key '%s': %w", key, err))
-				}
-			}
+	return sc.get(ctx, key, fetchFunc, getOptions[T]{
+		budget: budget,
+	})
+}
 
-			requests := <-sc.requests
-			requests[key].requests--
-			requests[key].updatePending = false
-			if requests[key].requests == 0 {
-				delete(requests, key)
-			}
-			sc.requests <- requests
-		}()
+// RefreshAndWait fetches fresh data for a key in the foreground, stores it in the cache and returns it as a HotHit.
+// It is meant for cache warming jobs: the per-key lock is held during the fetch, so it's safe to call it in bulk,
+// concurrently with other warming jobs or regular Get calls - they will wait for the result instead of fetching again.
+func (sc *Cache[T]) RefreshAndWait(ctx context.Context, key string, fetchFunc FetchFunc[T]) (Result[T], error) {
+	return sc.fetchNow(ctx, key, fetchFunc, HotHit)
+}
 
-		return result, entry.Err
-	}
+// ForceRefresh works like Get on a Miss, regardless of the cached data: it fetches the data in the foreground,
+// stores it in the cache and returns it as a Miss. It's useful when the caller knows the upstream data changed.
+// The per-key lock is held during the fetch, so concurrent Get calls wait for the result instead of fetching again.
+func (sc *Cache[T]) ForceRefresh(ctx context.Context, key string, fetchFunc FetchFunc[T]) (Result[T], error) {
+	return sc.fetchNow(ctx, key, fetchFunc, Miss)
 }
 
-func (sc *Cache[T]) fetchToCacheEntry(ctx context.Context, key string, fetchFunc FetchFunc[T]) (*CacheEntry[T], error) {
-	data, err := fetchFunc(ctx, key)
-	if err != nil {
-		errTTL := sc.config.errorTTLFunc(err)
-		if errTTL == 0 {
-			return newEmptyExpiredCacheEntry[T](), err
-		}
+// fetchNow fetches and stores the data while holding the key lock, and returns it with the result type.
+func (sc *Cache[T]) fetchNow(ctx context.Context, key string, fetchFunc FetchFunc[T], resultType ResultType) (Result[T], error) {
+	var result Result[T]
+
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
+	if err := sc.validateKey(key); err != nil {
+		return result, err
+	}
 
-		return newErrCacheEntry[T](err, errTTL), nil
+	if err := sc.enter(); err != nil {
+		return result, err
+	}
+	defer sc.wg.Done()
+
+	req, _ := sc.acquire(key)
+	defer sc.release(key, req)
+
+	fetchCtx, cancel := sc.newForegroundContext(ctx)
+	defer cancel()
+
+	item, _, err := sc.fetchToCacheEntry(fetchCtx, key, fetchFunc, false)
+	if err != nil {
+		return result, err
+	}
+
+	if err := sc.setEntry(ctx, key, sc.backendTTL(key, item), item); err != nil {
+		return result, fmt.Errorf("failed to update cache for key '%s': %w", key, err)
+	}
+	req.fetches.Add(1)
+	sc.setMirrored(key, item)
+	sc.setRefreshErr(key, nil)
+	sc.resetStaleServes(key)
+
+	result.Type = resultType
+	result.Data = item.Data
+	result.Age = sc.now().Sub(item.Created)
+	result.CreatedAt = item.Created
+
+	if err := sc.publishInvalidation(ctx, key); err != nil {
+		return result, err
+	}
+
+	return result, item.Err
+}
+
+// Set stores fresh data for the key, without calling a fetch function, e.g. to warm the cache from a snapshot.
+// The per-key lock is held while storing, so it doesn't race with Get calls fetching the key.
+// Background refreshes of the key requested before Set don't overwrite its data, like with Update.
+func (sc *Cache[T]) Set(ctx context.Context, key string, data *T) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := sc.validateKey(key); err != nil {
+		return err
+	}
+
+	if err := sc.enter(); err != nil {
+		return err
+	}
+	defer sc.wg.Done()
+
+	req, _ := sc.acquire(key)
+	defer sc.release(key, req)
+
+	item := newOKCacheEntry(data, sc.now())
+	item.Epoch = sc.epoch.Load()
+	item.SchemaVersion = sc.config.schemaVersion
+
+	// Increased before storing, so a refresh holding the lock after the write always notices it.
+	req.writes.Add(1)
+	if err := sc.setEntry(ctx, key, sc.backendTTL(key, item), item); err != nil {
+		return fmt.Errorf("failed to update cache for key '%s': %w", key, err)
+	}
+	req.fetches.Add(1)
+	sc.setMirrored(key, item)
+	sc.setRefreshErr(key, nil)
+	sc.resetStaleServes(key)
+
+	return sc.publishInvalidation(ctx, key)
+}
+
+// Update stores fresh data for the key, like Set, and returns it as a HotHit. It's meant for write-through updates,
+// when the application changes the data and already knows the new value. Background refreshes of the key
+// requested before the update are cancelled, or don't store their data if the fetch already finished,
+// so older data can't overwrite the updated one.
+func (sc *Cache[T]) Update(ctx context.Context, key string, data *T) (Result[T], error) {
+	var result Result[T]
+
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
+	if err := sc.validateKey(key); err != nil {
+		return result, err
+	}
+
+	if err := sc.enter(); err != nil {
+		return result, err
+	}
+	defer sc.wg.Done()
+
+	req, _ := sc.acquire(key)
+	defer sc.release(key, req)
+
+	item := newOKCacheEntry(data, sc.now())
+	item.Epoch = sc.epoch.Load()
+	item.SchemaVersion = sc.config.schemaVersion
+
+	// Increased before storing, so a refresh holding the lock after the update always notices it.
+	req.writes.Add(1)
+	if err := sc.setEntry(ctx, key, sc.backendTTL(key, item), item); err != nil {
+		return result, fmt.Errorf("failed to update cache for key '%s': %w", key, err)
+	}
+	req.fetches.Add(1)
+	sc.setMirrored(key, item)
+	sc.setRefreshErr(key, nil)
+	sc.resetStaleServes(key)
+
+	result.Type = HotHit
+	result.Data = item.Data
+	result.CreatedAt = item.Created
+
+	return result, sc.publishInvalidation(ctx, key)
+}
+
+// Delete removes the entry for the key from the cache. A background refresh of the key that is in progress
+// won't store its data after the deletion.
+func (sc *Cache[T]) Delete(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := sc.validateKey(key); err != nil {
+		return err
+	}
+
+	if err := sc.enter(); err != nil {
+		return err
+	}
+	defer sc.wg.Done()
+
+	req, _ := sc.acquire(key)
+	defer sc.release(key, req)
+
+	// Increased before deleting, so a refresh storing data after the deletion always notices it.
+	req.deletes.Add(1)
+	if err := sc.backend.Delete(ctx, key); err != nil {
+		return fmt.Errorf("failed to delete cache for key '%s': %w", key, err)
+	}
+	if sc.mirror != nil {
+		sc.mirror.remove(key)
+	}
+	sc.setRefreshErr(key, nil)
+	sc.resetStaleServes(key)
+
+	return sc.publishInvalidation(ctx, key)
+}
+
+// BumpEpoch invalidates all entries stored so far, without enumerating them.
+// Every entry stores the epoch it was fetched in, and entries from older epochs are treated as misses.
+// The epoch is local to the cache instance: other instances sharing the backend are not affected.
+func (sc *Cache[T]) BumpEpoch() {
+	sc.epoch.Add(1)
+}
+
+// Purge removes all entries from the backend, e.g. when a config change invalidates all cached data.
+// If the backend doesn't implement `PurgeableBackend`, `ErrNotSupported` is returned.
+// The epoch is bumped as well (see `BumpEpoch`), so data of fetches and refreshes in progress doesn't repopulate
+// the cache: it's stored, but treated as a miss.
+func (sc *Cache[T]) Purge(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	pb, ok := sc.backend.(PurgeableBackend)
+	if !ok {
+		return ErrNotSupported
+	}
+
+	if err := sc.enter(); err != nil {
+		return err
+	}
+	defer sc.wg.Done()
+
+	sc.epoch.Add(1)
+	if err := pb.Purge(ctx); err != nil {
+		return fmt.Errorf("failed to purge cache: %w", err)
+	}
+	if sc.mirror != nil {
+		sc.mirror.clear()
+	}
+
+	sc.refreshErrsMu.Lock()
+	sc.refreshErrs = nil
+	sc.refreshErrsMu.Unlock()
+	sc.staleServesMu.Lock()
+	sc.staleServes = nil
+	sc.staleServesMu.Unlock()
+
+	return nil
+}
+
+// Utilization returns the fraction of the backend's capacity in use, from 0 to 1, e.g. for deciding when to grow
+// the cache. If the backend doesn't implement `CapacityBackend`, `ErrNotSupported` is returned.
+func (sc *Cache[T]) Utilization(ctx context.Context) (float64, error) {
+	cb, ok := sc.backend.(CapacityBackend)
+	if !ok {
+		return 0, ErrNotSupported
+	}
+
+	n, err := cb.Len(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("cache backend failed: %w", err)
+	}
+
+	return float64(n) / float64(cb.Cap()), nil
+}
+
+// ResizeBackend changes the size of the backend at runtime, e.g. to grow it when too many entries are evicted.
+// It returns the number of entries evicted by shrinking it. If the backend doesn't implement `ResizableBackend`,
+// `ErrNotSupported` is returned.
+func (sc *Cache[T]) ResizeBackend(size int) (int, error) {
+	if size <= 0 {
+		return 0, errors.New("size has to be > 0")
+	}
+	rb, ok := sc.backend.(ResizableBackend)
+	if !ok {
+		return 0, ErrNotSupported
+	}
+
+	return rb.Resize(size), nil
+}
+
+// Expire marks the cached data for the key as stale, without removing it.
+// The next Get returns it as a WarmHit and triggers a background refresh, so the old data is still available
+// if the refresh fails. Entries without data (like cached errors), already stale entries and missing keys
+// are left untouched.
+func (sc *Cache[T]) Expire(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := sc.validateKey(key); err != nil {
+		return err
+	}
+
+	if err := sc.enter(); err != nil {
+		return err
+	}
+	defer sc.wg.Done()
+
+	req, _ := sc.acquire(key)
+	defer sc.release(key, req)
+
+	entry, err := sc.backend.Get(ctx, key)
+	if err != nil {
+		return fmt.Errorf("cache backend failed for key '%s': %w", key, err)
+	}
+	if entry == nil || entry.Data == nil || entry.FixedExpiration != nil {
+		return nil
+	}
+	now := sc.now()
+	primaryTTL, secondaryTTL := sc.ttls(key, entry)
+	if entry.IsExpiredAt(primaryTTL, now) {
+		return nil
+	}
+
+	// Move the entry right past the primary TTL, keeping the rest of the secondary TTL for stale serving.
+	expired := *entry
+	expired.Created = now.Add(-primaryTTL - time.Nanosecond)
+	ttl := expired.Created.Add(secondaryTTL).Sub(now)
+	if err := sc.setEntry(ctx, key, ttl, &expired); err != nil {
+		return fmt.Errorf("failed to update cache for key '%s': %w", key, err)
+	}
+	sc.setMirrored(key, &expired)
+
+	return sc.publishInvalidation(ctx, key)
+}
+
+// ConfirmValid tells the cache that the data for the key was just accepted downstream, e.g. a token was used
+// successfully, so it's fresh again: the entry's creation time is moved to now, which restarts its TTLs.
+// Entries without data (like cached errors), entries with a fixed expiration, misses and missing keys
+// are left untouched.
+func (sc *Cache[T]) ConfirmValid(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := sc.validateKey(key); err != nil {
+		return err
+	}
+
+	if err := sc.enter(); err != nil {
+		return err
+	}
+	defer sc.wg.Done()
+
+	req, _ := sc.acquire(key)
+	defer sc.release(key, req)
+
+	entry, err := sc.backend.Get(ctx, key)
+	if err != nil {
+		return fmt.Errorf("cache backend failed for key '%s': %w", key, err)
+	}
+	if entry == nil || entry.Data == nil || entry.FixedExpiration != nil || sc.classify(key, entry) == Miss {
+		return nil
+	}
+
+	confirmed := *entry
+	confirmed.Created = sc.now()
+	if err := sc.setEntry(ctx, key, sc.backendTTL(key, &confirmed), &confirmed); err != nil {
+		return fmt.Errorf("failed to update cache for key '%s': %w", key, err)
+	}
+	sc.setMirrored(key, &confirmed)
+	sc.resetStaleServes(key)
+
+	return nil
+}
+
+// ReportInvalid tells the cache that the data for the key was rejected downstream, e.g. a token was revoked.
+// The entry is removed like with Delete, so the next Get fetches the data again.
+func (sc *Cache[T]) ReportInvalid(ctx context.Context, key string) error {
+	return sc.Delete(ctx, key)
+}
+
+// validateKey checks the key with the configured validator.
+func (sc *Cache[T]) validateKey(key string) error {
+	if sc.config.keyValidator == nil {
+		return nil
+	}
+
+	return sc.config.keyValidator(key)
+}
+
+// enter registers a running operation, so `Close` can wait for it to finish.
+// It fails if the cache is already closed.
+func (sc *Cache[T]) enter() error {
+	sc.closeMu.RLock()
+	defer sc.closeMu.RUnlock()
+
+	if err := sc.ctx.Err(); err != nil {
+		return err
+	}
+	sc.wg.Add(1)
+
+	return nil
+}
+
+// acquire increases the requests count for the key and waits for the key lock.
+// The returned request has to be passed to `release` when done.
+// joinedAt is the request's fetches count from before waiting for the lock.
+func (sc *Cache[T]) acquire(key string) (req *request, joinedAt uint64) {
+	// Obtain request with a lock, increase requests count for the key, obtain a lock for the key.
+	shard := sc.shard(key)
+	rs := <-shard
+	req, found := rs.requests[key]
+	switch {
+	case !found:
+		req = sc.requestPool.Get().(*request)
+		req.key = key
+		rs.requests[key] = req
+	case req.requests == 0:
+		// Reuse an idle request.
+		rs.removeIdle(req)
+	}
+	req.requests++
+	joinedAt = req.fetches.Load()
+	shard <- rs
+
+	<-req.lock
+
+	return req, joinedAt
+}
+
+// release decreases the requests count for the key, removes the entry if the count goes to 0, and releases the key lock.
+func (sc *Cache[T]) release(key string, req *request) {
+	shard := sc.shard(key)
+	rs := <-shard
+	req.fetchPending = false
+	removed := sc.done(rs, key)
+	shard <- rs
+
+	req.lock <- struct{}{}
+
+	// The removed request has no callers and its lock is being released, so it can be reused.
+	if removed != nil {
+		sc.requestPool.Put(removed)
+	}
+}
+
+// shard returns the shard for the key.
+func (sc *Cache[T]) shard(key string) chan *requestShard {
+	if len(sc.shards) == 1 {
+		return sc.shards[0]
+	}
+
+	return sc.shards[sc.config.shardHash(key)%uint64(len(sc.shards))]
+}
+
+// fnvHash is the default shard hash, 64-bit FNV-1a.
+func fnvHash(key string) uint64 {
+	const (
+		offset64 = 14695981039346656037
+		prime64  = 1099511628211
+	)
+
+	h := uint64(offset64)
+	for i := 0; i < len(key); i++ {
+		h ^= uint64(key[i])
+		h *= prime64
+	}
+
+	return h
+}
+
+// mix64 mixes the bits of the hash (splitmix64 finalizer), so similar inputs give unrelated outputs.
+func mix64(h uint64) uint64 {
+	h ^= h >> 30
+	h *= 0xbf58476d1ce4e5b9
+	h ^= h >> 27
+	h *= 0x94d049bb133111eb
+	h ^= h >> 31
+
+	return h
+}
+
+// done decreases the requests count for the key, and removes the entry if the count goes to 0.
+// With the lock pool enabled, the entry is kept as idle instead, and the least recently used idle entry
+// is removed if there are too many of them.
+// It has to be called while owning the shard. The removed request is returned.
+func (sc *Cache[T]) done(rs *requestShard, key string) *request {
+	req := rs.requests[key]
+	req.requests--
+	if req.requests > 0 {
+		return nil
+	}
+	req.updatePending = false
+
+	if size := sc.config.lockPoolSize; size > 0 {
+		rs.pushIdle(req)
+		if rs.idleCount <= size {
+			return nil
+		}
+
+		return rs.evictIdle()
+	}
+
+	delete(rs.requests, key)
+
+	return req
+}
+
+// newRequest creates a request with a free lock.
+func newRequest() any {
+	req := &request{
+		lock: make(chan struct{}, 1),
+	}
+	req.lock <- struct{}{}
+
+	return req
+}
+
+// setMirrored stores the entry in the resilience mirror, if enabled.
+func (sc *Cache[T]) setMirrored(key string, entry *CacheEntry[T]) {
+	if sc.mirror != nil && entry != nil && entry.Data != nil {
+		sc.mirror.add(key, entry)
+	}
+}
+
+// getMirrored returns the entry from the resilience mirror, if enabled.
+func (sc *Cache[T]) getMirrored(key string) *CacheEntry[T] {
+	if sc.mirror == nil {
+		return nil
+	}
+
+	return sc.mirror.get(key)
+}
+
+// StaleServeCount returns the number of times the key was served as a WarmHit or StaleHit since the data
+// was last fetched. Keys read heavily while stale are good candidates for proactive warming.
+func (sc *Cache[T]) StaleServeCount(key string) int {
+	sc.staleServesMu.Lock()
+	defer sc.staleServesMu.Unlock()
+
+	return sc.staleServes[key]
+}
+
+// resetStaleServes resets the stale serve count of the key, after the data was fetched.
+func (sc *Cache[T]) resetStaleServes(key string) {
+	sc.staleServesMu.Lock()
+	defer sc.staleServesMu.Unlock()
+
+	delete(sc.staleServes, key)
+}
+
+// setRefreshErr records the result of a refresh of the key, if refresh errors are enabled.
+func (sc *Cache[T]) setRefreshErr(key string, err error) {
+	if !sc.config.refreshErrors {
+		return
+	}
+
+	sc.refreshErrsMu.Lock()
+	defer sc.refreshErrsMu.Unlock()
+
+	if err == nil {
+		delete(sc.refreshErrs, key)
+		return
+	}
+	if sc.refreshErrs == nil {
+		sc.refreshErrs = make(map[string]error)
+	}
+	sc.refreshErrs[key] = err
+}
+
+// getRefreshErr returns the error of the last failed refresh of the key.
+func (sc *Cache[T]) getRefreshErr(key string) error {
+	if !sc.config.refreshErrors {
+		return nil
+	}
+
+	sc.refreshErrsMu.Lock()
+	defer sc.refreshErrsMu.Unlock()
+
+	return sc.refreshErrs[key]
+}
+
+// isTraced checks if decisions for the key should be logged.
+func (sc *Cache[T]) isTraced(key string) bool {
+	if sc.config.logger == nil || sc.tracedCount.Load() == 0 {
+		return false
+	}
+
+	sc.tracedMu.RLock()
+	defer sc.tracedMu.RUnlock()
+
+	_, traced := sc.tracedKeys[key]
+
+	return traced
+}
+
+func (sc *Cache[T]) traceDecision(key string, resultType ResultType, entry *CacheEntry[T]) {
+	if entry == nil {
+		sc.config.logger.Printf("smartcache: trace key '%s': decision %s, entry missing", key, resultType)
+		return
+	}
+
+	sc.config.logger.Printf("smartcache: trace key '%s': decision %s, entry age %s", key, resultType, sc.now().Sub(entry.Created))
+}
+
+// classify decides how the entry should be served: a Miss means that the data has to be fetched immediately.
+func (sc *Cache[T]) classify(key string, entry *CacheEntry[T]) ResultType {
+	if entry == nil || entry.Epoch < sc.epoch.Load() || entry.SchemaVersion != sc.config.schemaVersion {
+		return Miss
+	}
+
+	primaryTTL, secondaryTTL := sc.ttls(key, entry)
+	now := sc.now()
+	switch {
+	case entry.IsExpiredAt(secondaryTTL, now):
+		return Miss
+	case !entry.IsExpiredAt(primaryTTL, now) && !sc.errorRefreshDue(entry, now):
+		return HotHit
+	case sc.config.keyFreshnessFunc != nil && !sc.config.keyFreshnessFunc(key):
+		// Serving warm data is disabled for the key.
+		return Miss
+	default:
+		return WarmHit
+	}
+}
+
+// errorRefreshDue checks if the entry is a cached error due for a background retry, see `WithErrorRefreshAfter`.
+func (sc *Cache[T]) errorRefreshDue(entry *CacheEntry[T], now time.Time) bool {
+	return sc.config.errorRefreshAfter > 0 && entry.Err != nil && entry.FixedExpiration != nil &&
+		entry.Created.Add(sc.config.errorRefreshAfter).Before(now)
+}
+
+// ttls returns the primary and secondary TTL for the key's entry, extended if the upstream is degraded.
+func (sc *Cache[T]) ttls(key string, entry *CacheEntry[T]) (primary, secondary time.Duration) {
+	primary, secondary = sc.baseTTLs(key, entry)
+	if sc.config.healthSource != nil && sc.config.healthSource() == Degraded {
+		primary = sc.degradedTTL(primary)
+		secondary = sc.degradedTTL(secondary)
+	}
+
+	return primary, secondary
+}
+
+// baseTTLs returns the primary and secondary TTL for the key's entry.
+// Error entries have separate TTLs if `WithErrorTTLs` option is used.
+func (sc *Cache[T]) baseTTLs(key string, entry *CacheEntry[T]) (primary, secondary time.Duration) {
+	primary, secondary = sc.config.primaryTTL, sc.config.secondaryTTL
+	if entry.Err != nil && sc.config.errorPrimaryTTL > 0 {
+		primary, secondary = sc.config.errorPrimaryTTL, sc.config.errorSecondaryTTL
+	}
+	if sc.config.ttlJitter > 0 {
+		secondary = sc.jitteredTTL(key, entry, primary, secondary)
+	}
+
+	return primary, secondary
+}
+
+// jitteredTTL returns the secondary TTL randomized by up to ±`WithTTLJitter` fraction, but not below the primary TTL.
+// The random factor is derived from the key and the entry's creation time, so it's the same on every read
+// of the entry, also in other instances sharing the backend, and different for every write.
+func (sc *Cache[T]) jitteredTTL(key string, entry *CacheEntry[T], primary, secondary time.Duration) time.Duration {
+	// Close creation times give unrelated factors thanks to the mixing.
+	h := mix64(fnvHash(key) ^ uint64(entry.Created.UnixNano()))
+	u := float64(h>>11) / (1 << 53) // Uniform in [0, 1).
+
+	jittered := time.Duration(float64(secondary) * (1 + sc.config.ttlJitter*(2*u-1)))
+	if jittered < primary {
+		return primary
+	}
+
+	return jittered
+}
+
+// degradedTTL returns the ttl extended for a degraded upstream, see `WithHealthSource`.
+func (sc *Cache[T]) degradedTTL(ttl time.Duration) time.Duration {
+	return time.Duration(float64(ttl) * sc.config.degradedTTLFactor)
+}
+
+// getEntry reads the entry from the backend, in a batch if batching is enabled.
+// Besides the entry it returns the entry to classify, which accounts for the TTL reported by a TTL-aware backend.
+// Entries created in the future are read as created now, see `clampCreated`.
+func (sc *Cache[T]) getEntry(ctx context.Context, key string) (entry, classified *CacheEntry[T], err error) {
+	// Keys not in the filter are definitely not in the backend, keys found in the backend are kept in the filter.
+	if sc.bloom != nil && !sc.bloom.mayContain(key) {
+		return nil, nil, nil
+	}
+	entry, classified, err = sc.readEntry(ctx, key)
+	if sc.bloom != nil && entry != nil {
+		sc.bloom.add(key)
+	}
+
+	if clamped := sc.clampCreated(entry); clamped != entry {
+		if classified == entry {
+			classified = clamped
+		} else {
+			classified = sc.clampCreated(classified)
+		}
+		entry = clamped
+	}
+
+	return entry, classified, err
+}
+
+// readEntry implements getEntry.
+func (sc *Cache[T]) readEntry(ctx context.Context, key string) (entry, classified *CacheEntry[T], err error) {
+	if sc.batcher != nil {
+		entry, ttl, err := sc.batcher.get(ctx, key)
+		return entry, sc.withObservedTTL(key, entry, ttl), err
+	}
+
+	if tb, ok := sc.backend.(TTLAwareBackend[T]); ok {
+		entry, ttl, err := tb.GetWithTTL(ctx, key)
+		return entry, sc.withObservedTTL(key, entry, ttl), err
+	}
+
+	entry, err = sc.backend.Get(ctx, key)
+	return entry, entry, err
+}
+
+// setEntry stores the entry in the backend.
+func (sc *Cache[T]) setEntry(ctx context.Context, key string, ttl time.Duration, entry *CacheEntry[T]) error {
+	if sc.bloom != nil {
+		// Added before storing, so concurrent reads never miss the entry.
+		sc.bloom.add(key)
+	}
+
+	return sc.backend.Set(ctx, key, ttl, entry)
+}
+
+// clampCreated returns the entry with the creation time moved to now, if it's in the future.
+// Clock skew between instances sharing the backend can produce such entries, which would be served
+// with a negative age. The entry itself is not modified.
+func (sc *Cache[T]) clampCreated(entry *CacheEntry[T]) *CacheEntry[T] {
+	if entry == nil {
+		return nil
+	}
+	now := sc.now()
+	if !entry.Created.After(now) {
+		return entry
+	}
+
+	clamped := *entry
+	clamped.Created = now
+
+	return &clamped
+}
+
+// withObservedTTL returns the entry adjusted to expire no later than the remaining TTL observed by the backend.
+// The entry itself is not modified.
+func (sc *Cache[T]) withObservedTTL(key string, entry *CacheEntry[T], ttl time.Duration) *CacheEntry[T] {
+	if entry == nil || ttl < 0 {
+		return entry
+	}
+
+	expiration := sc.now().Add(ttl)
+	adjusted := *entry
+	if entry.FixedExpiration != nil {
+		if !entry.FixedExpiration.After(expiration) {
+			return entry
+		}
+		adjusted.FixedExpiration = &expiration
+
+		return &adjusted
+	}
+
+	// Entries are stored for the backend TTL, so the observed TTL tells how old the entry really is.
+	created := expiration.Add(-sc.backendTTL(key, entry))
+	if !created.Before(entry.Created) {
+		return entry
+	}
+	adjusted.Created = created
+
+	return &adjusted
+}
+
+// backendTTL returns the ttl for storing the entry in the backend.
+// Entries with a fixed expiration outliving the secondary TTL (like cached errors) are kept until that expiration.
+func (sc *Cache[T]) backendTTL(key string, entry *CacheEntry[T]) time.Duration {
+	_, ttl := sc.baseTTLs(key, entry)
+	if sc.config.healthSource != nil {
+		// The entry has to be available if the upstream degrades before it expires.
+		ttl = sc.degradedTTL(ttl)
+	}
+	if entry.FixedExpiration != nil {
+		if d := entry.FixedExpiration.Sub(sc.now()); d > ttl {
+			ttl = d
+		}
+	}
+
+	return ttl
+}
+
+// observeGet is called with the final result of every Get call that reached the backend.
+// start is the time the call started, it's only needed with a metrics observer.
+func (sc *Cache[T]) observeGet(key string, result *Result[T], start time.Time) {
+	sc.stats.countResult(result.Type)
+	if sc.config.observer != nil {
+		sc.config.observer.ObserveGet(result.Type, time.Since(start))
+	}
+
+	cached := result.Type == HotHit || result.Type == WarmHit || result.Type == StaleHit
+	if cached && sc.config.freshnessTarget > 0 && result.Age > sc.config.freshnessTarget {
+		sc.stats.staleServedBeyondTarget.Add(1)
+	}
+
+	if result.Type == WarmHit || result.Type == StaleHit {
+		sc.staleServesMu.Lock()
+		if sc.staleServes == nil {
+			sc.staleServes = make(map[string]int)
+		}
+		sc.staleServes[key]++
+		sc.staleServesMu.Unlock()
+	}
+
+	if sc.config.accessTrace != nil {
+		sc.config.accessTrace.record(time.Now(), key, result.Type)
+	}
+
+	if sc.mutation != nil {
+		sc.mutation.check(key, result.Data)
+	}
+
+	if sc.events.count.Load() > 0 {
+		dropped := sc.events.publish(Event{
+			Key:  key,
+			Type: result.Type,
+			Age:  result.Age,
+			Time: time.Now(),
+		})
+		sc.stats.eventsDropped.Add(uint64(dropped))
+	}
+}
+
+// fetchAndStore fetches data for the key in the foreground and stores it in the backend.
+// It has to be called while holding the key lock.
+func (sc *Cache[T]) fetchAndStore(ctx context.Context, key string, req *request, fetchFunc FetchFunc[T]) (*CacheEntry[T], ResultType, error) {
+	fetchCtx, cancel := sc.newForegroundContext(ctx)
+	defer cancel()
+
+	item, fetchedType, err := sc.fetchToCacheEntry(fetchCtx, key, fetchFunc, false)
+	if err != nil {
+		return item, fetchedType, err
+	}
+
+	if err := sc.setEntry(ctx, key, sc.backendTTL(key, item), item); err != nil {
+		return item, fetchedType, fmt.Errorf("failed to update cache for key '%s': %w", key, err)
+	}
+	sc.storedFetch(key, req, item)
+
+	return item, fetchedType, nil
+}
+
+// fetchWithin fetches data for the key and stores it in the backend in a separate goroutine,
+// waiting for the result at most for the budget or until ctx is done.
+// It takes over the key lock, which is released when the fetch is finished.
+func (sc *Cache[T]) fetchWithin(ctx context.Context, key string, req *request, fetchFunc FetchFunc[T], budget time.Duration) (*CacheEntry[T], ResultType, error) {
+	done := sc.fetchDetached(key, req, fetchFunc)
+
+	timer := time.NewTimer(budget)
+	defer timer.Stop()
+
+	select {
+	case o := <-done:
+		return o.item, o.fetchedType, o.err
+	case <-timer.C:
+		return nil, Miss, ErrFetchBudgetExceeded
+	case <-ctx.Done():
+		return nil, Miss, fmt.Errorf("%w: %v", ErrFetchBudgetExceeded, ctx.Err())
+	}
+}
+
+// fetchNonBlocking fetches and stores the data in the background, marking the key as being fetched until it's done.
+// The key lock held by the caller is released when the fetch finishes.
+func (sc *Cache[T]) fetchNonBlocking(key string, req *request, fetchFunc FetchFunc[T]) {
+	shard := sc.shard(req.key)
+	rs := <-shard
+	req.fetchPending = true
+	shard <- rs
+
+	sc.fetchDetached(key, req, fetchFunc)
+}
+
+// fetchInProgress checks if the key is being fetched in the background after a non-blocking miss.
+func (sc *Cache[T]) fetchInProgress(key string) bool {
+	shard := sc.shard(key)
+	rs := <-shard
+	req, found := rs.requests[key]
+	pending := found && req.fetchPending
+	shard <- rs
+
+	return pending
+}
+
+// fetchOutcome is the outcome of a fetch running in the background.
+type fetchOutcome[T any] struct {
+	item        *CacheEntry[T]
+	fetchedType ResultType
+	err         error
+}
+
+// fetchDetached fetches and stores the data in the background, then releases the key lock held by the caller,
+// and the in-flight slot if `WithMaxInFlightKeys` is set. The outcome is sent to the returned channel,
+// which doesn't have to be read.
+func (sc *Cache[T]) fetchDetached(key string, req *request, fetchFunc FetchFunc[T]) <-chan fetchOutcome[T] {
+	done := make(chan fetchOutcome[T], 1)
+
+	sc.wg.Add(1)
+	go func() {
+		defer sc.wg.Done()
+		defer sc.release(req.key, req) // The lock may be held for a coordination key.
+		if sc.config.maxInFlightKeys > 0 {
+			defer sc.inFlight.Add(-1)
+		}
+
+		// The fetch may outlive the call, so it doesn't use the call's context.
+		bkgCtx, cancel := sc.newBackgroundContext(sc.ctx)
+		defer cancel()
+
+		var o fetchOutcome[T]
+		o.item, o.fetchedType, o.err = sc.fetchToCacheEntry(bkgCtx, key, fetchFunc, false)
+		if o.err == nil {
+			if err := sc.setEntry(bkgCtx, key, sc.backendTTL(key, o.item), o.item); err != nil {
+				o.err = fmt.Errorf("failed to update cache for key '%s': %w", key, err)
+			} else {
+				sc.storedFetch(key, req, o.item)
+			}
+		}
+		done <- o
+	}()
+
+	return done
+}
+
+// storedFetch updates the state after data fetched while holding the key lock was stored.
+func (sc *Cache[T]) storedFetch(key string, req *request, item *CacheEntry[T]) {
+	req.fetches.Add(1)
+	sc.stats.fetchLeader.Add(1)
+	sc.setMirrored(key, item)
+	sc.setRefreshErr(key, nil)
+	sc.resetStaleServes(key)
+}
+
+// fetchToCacheEntry calls the fetch function and converts its result to a cache entry.
+// The result type set by the fetch function is returned as well. Background is set for background refreshes.
+func (sc *Cache[T]) fetchToCacheEntry(ctx context.Context, key string, fetchFunc FetchFunc[T], background bool) (*CacheEntry[T], ResultType, error) {
+	failed := true // Unless the fetch succeeds.
+	if b := sc.breaker; b != nil && !background {
+		if !b.allow(sc.now()) {
+			return newEmptyExpiredCacheEntry[T](sc.now()), Miss, fmt.Errorf("fetching key '%s': %w", key, ErrCircuitOpen)
+		}
+		defer func() {
+			if ctx.Err() != nil {
+				// Cancellations and deadlines of the caller are not upstream failures.
+				b.abandon()
+				return
+			}
+			b.done(sc.now(), failed)
+		}()
+	}
+
+	if l := sc.config.fetchLimiter; l != nil {
+		if err := l.Acquire(ctx); err != nil {
+			return newEmptyExpiredCacheEntry[T](sc.now()), Miss, fmt.Errorf("waiting for fetch limiter for key '%s': %w", key, err)
+		}
+		defer l.Release()
+	}
+
+	// The epoch is read before the fetch, so data fetched while the epoch is bumped is already invalid.
+	epoch := sc.epoch.Load()
+
+	ctx, endSpan := sc.startFetchSpan(ctx, SpanFetch, key)
+	start := time.Now()
+	data, err := callFetch(ctx, key, fetchFunc)
+	failed = err != nil
+	sc.observeFetch(start, err, background)
+	endSpan(err)
+
+	return sc.toCacheEntry(ctx, key, data, err, epoch)
+}
+
+// callFetch calls the fetch function, converting a panic into an error.
+func callFetch[T any](ctx context.Context, key string, fetchFunc FetchFunc[T]) (data *FetchResult[T], err error) {
+	defer recoverFetch(&err)
+
+	return fetchFunc(ctx, key)
+}
+
+// observeFetch records the fetch function call started at the given time.
+func (sc *Cache[T]) observeFetch(start time.Time, err error, background bool) {
+	d := time.Since(start)
+	if err != nil {
+		sc.stats.fetchErrors.Add(1)
+	}
+	sc.stats.countFetch(d, background)
+	if sc.config.observer != nil {
+		sc.config.observer.ObserveFetch(d, err, background)
+	}
+}
+
+// toCacheEntry converts the fetch result for the key to a cache entry from the given epoch.
+// The error is returned only if it's not cached. Errors of a fetch whose ctx is done are never cached,
+// as they come from the caller giving up, not from the upstream.
+func (sc *Cache[T]) toCacheEntry(ctx context.Context, key string, data *FetchResult[T], err error, epoch uint64) (*CacheEntry[T], ResultType, error) {
+	var entry *CacheEntry[T]
+	resultType := Miss
+	switch {
+	case err == nil:
+		created := data.CreatedAt
+		if created.IsZero() {
+			created = sc.now()
+		}
+		entry = newOKCacheEntry(data.Data, created)
+		if sc.validFetchTTL(key, created, data.TTL) {
+			exp := created.Add(data.TTL)
+			entry.FixedExpiration = &exp
+		}
+		resultType = data.Type
+	case ctx.Err() != nil:
+		return newEmptyExpiredCacheEntry[T](sc.now()), Miss, err
+	default:
+		errTTL := sc.config.errorTTLFunc(key, err)
+		if errTTL == 0 {
+			return newEmptyExpiredCacheEntry[T](sc.now()), Miss, err
+		}
+		if sc.config.errorPrimaryTTL > 0 {
+			// The error expires with the error TTLs, see `WithErrorTTLs`.
+			entry = &CacheEntry[T]{Err: err, Created: sc.now()}
+		} else {
+			entry = newErrCacheEntry[T](err, errTTL, sc.now())
+		}
+	}
+	entry.Epoch = epoch
+	entry.SchemaVersion = sc.config.schemaVersion
+
+	return entry, resultType, nil
+}
+
+// validFetchTTL checks the TTL returned by the fetch function for the key. Zero means it's not set.
+// A negative TTL, or one that makes the entry expired right away, would make every read a Miss,
+// so it's reported to the logger, and the cache-wide TTLs are used instead.
+func (sc *Cache[T]) validFetchTTL(key string, created time.Time, ttl time.Duration) bool {
+	if ttl == 0 {
+		return false
+	}
+	if ttl > 0 && created.Add(ttl).After(sc.now()) {
+		return true
 	}
 
-	created := data.CreatedAt
-	if created.IsZero() {
-		created = time.Now()
+	if sc.config.logger != nil {
+		sc.config.logger.Printf("smartcache: key '%s': fetch result TTL %s expires the entry right away, using the cache TTLs", key, ttl)
 	}
 
-	return newOKCacheEntry(data.Data, created), nil
+	return false
 }
 
-func (sc *Cache[T]) newBackgroundContext() (ctx context.Context, cancel func()) {
+func (sc *Cache[T]) newBackgroundContext(parent context.Context) (ctx context.Context, cancel func()) {
 	if sc.config.backgroundFetchTimeout > 0 {
-		return context.WithTimeout(sc.ctx, sc.config.backgroundFetchTimeout)
+		return context.WithTimeout(parent, sc.config.backgroundFetchTimeout)
 	}
 
-	return context.WithCancel(sc.ctx)
+	return context.WithCancel(parent)
 }
 
 func (sc *Cache[T]) newForegroundContext(ctx context.Context) (fgCtx context.Context, cancel func()) {
@@ -4,12 +4,19 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/alicebob/miniredis/v2"
 	"github.com/m-zajac/smartcache"
 	"github.com/m-zajac/smartcache/backend/lru"
+	redisbackend "github.com/m-zajac/smartcache/backend/redis"
+	"github.com/m-zajac/smartcache/clocktest"
+	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -250,3 +257,3459 @@ func TestCache_FailureOnBackgroundUpdate(t *testing.T) {
 	assert.Equal(t, *result1.Data, *result3.Data)
 	assert.Equal(t, smartcache.WarmHit, result3.Type)
 }
+
+func TestCache_RefreshAndWait(t *testing.T) {
+	t.Parallel()
+
+	key := "some-key"
+
+	var calls atomic.Int32
+	fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		v := fmt.Sprintf("data %d", calls.Add(1))
+		return &smartcache.FetchResult[string]{
+			Data: &v,
+		}, nil
+	}
+
+	// RefreshAndWait should always fetch, even when the cached data is fresh.
+	t.Run("returns fresh data", func(t *testing.T) {
+		backend, err := lru.NewBackend[string](100)
+		require.NoError(t, err)
+
+		cache, err := smartcache.New[string](backend)
+		require.NoError(t, err)
+
+		ctx := context.Background()
+
+		result, err := cache.Get(ctx, key, fetchFunc)
+		require.NoError(t, err)
+		assert.Equal(t, smartcache.Miss, result.Type)
+		first := *result.Data
+
+		result, err = cache.RefreshAndWait(ctx, key, fetchFunc)
+		require.NoError(t, err)
+		assert.Equal(t, smartcache.HotHit, result.Type)
+		assert.NotEqual(t, first, *result.Data)
+
+		// Refreshed data should be served from the cache.
+		refreshed := *result.Data
+		result, err = cache.Get(ctx, key, fetchFunc)
+		require.NoError(t, err)
+		assert.Equal(t, smartcache.HotHit, result.Type)
+		assert.Equal(t, refreshed, *result.Data)
+	})
+
+	// Concurrent calls for the same key should wait for the lock,
+	// so a Get issued during the refresh should not fetch again.
+	t.Run("holds the lock", func(t *testing.T) {
+		backend, err := lru.NewBackend[string](100)
+		require.NoError(t, err)
+
+		cache, err := smartcache.New[string](backend)
+		require.NoError(t, err)
+
+		ctx := context.Background()
+
+		started := make(chan struct{})
+		ready := make(chan struct{})
+		var blockingCalls atomic.Int32
+		blockingFetch := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+			blockingCalls.Add(1)
+			close(started)
+			<-ready
+			v := "warmed"
+			return &smartcache.FetchResult[string]{
+				Data: &v,
+			}, nil
+		}
+
+		refreshDone := make(chan struct{})
+		go func() {
+			defer close(refreshDone)
+			_, _ = cache.RefreshAndWait(ctx, key, blockingFetch)
+		}()
+		<-started
+
+		getDone := make(chan smartcache.Result[string])
+		go func() {
+			result, _ := cache.Get(ctx, key, blockingFetch)
+			getDone <- result
+		}()
+
+		select {
+		case <-getDone:
+			t.Fatal("Get returned while the key was locked by RefreshAndWait")
+		case <-time.After(100 * time.Millisecond):
+		}
+
+		close(ready)
+		<-refreshDone
+
+		result := <-getDone
+		assert.Equal(t, smartcache.HotHit, result.Type)
+		assert.Equal(t, "warmed", *result.Data)
+		assert.EqualValues(t, 1, blockingCalls.Load())
+	})
+}
+
+func TestCache_ForceRefresh(t *testing.T) {
+	t.Parallel()
+
+	backend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+	cache, err := smartcache.New[string](backend)
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	var calls atomic.Int32
+	fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		v := fmt.Sprintf("data %d", calls.Add(1))
+		return &smartcache.FetchResult[string]{Data: &v}, nil
+	}
+
+	ctx := context.Background()
+	result, err := cache.Get(ctx, "key", fetchFunc)
+	require.NoError(t, err)
+	assert.Equal(t, "data 1", *result.Data)
+
+	// The cached data is fresh, but it's fetched anyway.
+	result, err = cache.ForceRefresh(ctx, "key", fetchFunc)
+	require.NoError(t, err)
+	assert.Equal(t, smartcache.Miss, result.Type)
+	assert.Equal(t, "data 2", *result.Data)
+
+	result, err = cache.Get(ctx, "key", fetchFunc)
+	require.NoError(t, err)
+	assert.Equal(t, smartcache.HotHit, result.Type)
+	assert.Equal(t, "data 2", *result.Data)
+	assert.EqualValues(t, 2, calls.Load())
+}
+
+func TestCache_GetPreferData(t *testing.T) {
+	t.Parallel()
+
+	fetchErr := errors.New("fetch failed")
+	failingFetch := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		return nil, fetchErr
+	}
+	newCache := func(t *testing.T, options ...smartcache.Option) (*smartcache.Cache[string], *lru.Backend[string]) {
+		backend, err := lru.NewBackend[string](100)
+		require.NoError(t, err)
+		cache, err := smartcache.New[string](backend, options...)
+		require.NoError(t, err)
+		t.Cleanup(cache.Close)
+
+		return cache, backend
+	}
+	ctx := context.Background()
+
+	t.Run("cached error over data", func(t *testing.T) {
+		cache, backend := newCache(t)
+		data := "data"
+		require.NoError(t, backend.Set(ctx, "key", time.Hour, &smartcache.CacheEntry[string]{
+			Data:    &data,
+			Err:     fetchErr,
+			Created: time.Now(),
+		}))
+
+		_, err := cache.Get(ctx, "key", failingFetch)
+		assert.ErrorIs(t, err, fetchErr)
+
+		result, err := cache.GetPreferData(ctx, "key", failingFetch)
+		require.NoError(t, err)
+		assert.Equal(t, smartcache.HotHit, result.Type)
+		assert.Equal(t, data, *result.Data)
+	})
+
+	t.Run("cached error without data", func(t *testing.T) {
+		cache, _ := newCache(t, smartcache.WithErrorTTLFunc(func(err error) time.Duration { return time.Minute }))
+
+		_, err := cache.Get(ctx, "key", failingFetch)
+		assert.ErrorIs(t, err, fetchErr)
+
+		result, err := cache.GetPreferData(ctx, "key", failingFetch)
+		assert.ErrorIs(t, err, fetchErr)
+		assert.Equal(t, smartcache.HotHit, result.Type)
+		assert.Nil(t, result.Data)
+	})
+
+	t.Run("expired data served on error", func(t *testing.T) {
+		cache, backend := newCache(t, smartcache.WithTTL(time.Minute, time.Hour), smartcache.WithServeExpiredOnError())
+		data := "old"
+		require.NoError(t, backend.Set(ctx, "key", time.Hour, &smartcache.CacheEntry[string]{
+			Data:    &data,
+			Created: time.Now().Add(-2 * time.Hour),
+		}))
+
+		result, err := cache.GetPreferData(ctx, "key", failingFetch)
+		require.NoError(t, err)
+		assert.Equal(t, smartcache.StaleHit, result.Type)
+		assert.Equal(t, data, *result.Data)
+	})
+}
+
+func TestCache_Set(t *testing.T) {
+	t.Parallel()
+
+	backend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+	cache, err := smartcache.New[string](backend)
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	var calls atomic.Int32
+	fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		calls.Add(1)
+		v := "fetched"
+		return &smartcache.FetchResult[string]{Data: &v}, nil
+	}
+
+	ctx := context.Background()
+	data := "snapshot"
+	require.NoError(t, cache.Set(ctx, "key", &data))
+
+	result, err := cache.Get(ctx, "key", fetchFunc)
+	require.NoError(t, err)
+	assert.Equal(t, smartcache.HotHit, result.Type)
+	assert.Equal(t, data, *result.Data)
+	assert.Zero(t, calls.Load())
+
+	// Set waits for an in-flight fetch of the key, so its data isn't overwritten by the fetch.
+	started := make(chan struct{})
+	ready := make(chan struct{})
+	blockingFetch := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		close(started)
+		<-ready
+		v := "fetched"
+		return &smartcache.FetchResult[string]{Data: &v}, nil
+	}
+	getDone := make(chan struct{})
+	go func() {
+		defer close(getDone)
+		_, _ = cache.Get(ctx, "other", blockingFetch)
+	}()
+	<-started
+
+	setDone := make(chan error)
+	go func() {
+		setDone <- cache.Set(ctx, "other", &data)
+	}()
+	select {
+	case <-setDone:
+		t.Fatal("Set returned while the key was locked by Get")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(ready)
+	<-getDone
+	require.NoError(t, <-setDone)
+
+	result, err = cache.Get(ctx, "other", fetchFunc)
+	require.NoError(t, err)
+	assert.Equal(t, smartcache.HotHit, result.Type)
+	assert.Equal(t, data, *result.Data)
+}
+
+func TestCache_SetDuringRefresh(t *testing.T) {
+	t.Parallel()
+
+	const primTTL = time.Minute
+	ctx := context.Background()
+	clock := clocktest.New(time.Now())
+	backend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+	cache, err := smartcache.New[string](backend, smartcache.WithTTL(primTTL, time.Hour), smartcache.WithClock(clock))
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	old := "old"
+	require.NoError(t, cache.Set(ctx, "key", &old))
+	clock.Add(primTTL + time.Second)
+
+	refreshStarted := make(chan struct{})
+	releaseRefresh := make(chan struct{})
+	fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		close(refreshStarted)
+		<-releaseRefresh
+		data := "refreshed"
+		return &smartcache.FetchResult[string]{Data: &data}, nil
+	}
+	result, err := cache.Get(ctx, "key", fetchFunc)
+	require.NoError(t, err)
+	assert.Equal(t, smartcache.WarmHit, result.Type)
+	<-refreshStarted
+
+	// The refresh fetched before Set, so its data is older and must not overwrite the set one.
+	data := "set"
+	require.NoError(t, cache.Set(ctx, "key", &data))
+
+	close(releaseRefresh)
+	require.Eventually(t, func() bool {
+		return cache.Stats().Refreshes == 1
+	}, time.Second, time.Millisecond)
+
+	result, err = cache.Lookup(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, smartcache.HotHit, result.Type)
+	assert.Equal(t, "set", *result.Data)
+}
+
+func TestCache_FreshnessTarget(t *testing.T) {
+	t.Parallel()
+
+	backend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+
+	cache, err := smartcache.New[string](
+		backend,
+		smartcache.WithTTL(time.Minute, time.Hour),
+		smartcache.WithFreshnessTarget(10*time.Second),
+	)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	fetchFunc := func(age time.Duration) smartcache.FetchFunc[string] {
+		return func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+			v := "data"
+			return &smartcache.FetchResult[string]{
+				Data:      &v,
+				CreatedAt: time.Now().Add(-age),
+			}, nil
+		}
+	}
+
+	// Fresh entry, served within the target.
+	_, err = cache.Get(ctx, "fresh", fetchFunc(0))
+	require.NoError(t, err)
+	result, err := cache.Get(ctx, "fresh", fetchFunc(0))
+	require.NoError(t, err)
+	assert.Equal(t, smartcache.HotHit, result.Type)
+	assert.EqualValues(t, 0, cache.Stats().StaleServedBeyondTarget)
+
+	// Entry older than the target, but still within the primary TTL.
+	_, err = cache.Get(ctx, "old", fetchFunc(20*time.Second))
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		result, err = cache.Get(ctx, "old", fetchFunc(20*time.Second))
+		require.NoError(t, err)
+		assert.Equal(t, smartcache.HotHit, result.Type)
+	}
+	assert.EqualValues(t, 3, cache.Stats().StaleServedBeyondTarget)
+
+	// Expired data served on a fetch failure counts too.
+	staleCache, err := smartcache.New[string](
+		backend,
+		smartcache.WithTTL(time.Minute, time.Hour),
+		smartcache.WithFreshnessTarget(10*time.Second),
+		smartcache.WithServeExpiredOnError(),
+	)
+	require.NoError(t, err)
+	v := "data"
+	require.NoError(t, backend.Set(ctx, "expired", time.Hour, &smartcache.CacheEntry[string]{
+		Data:    &v,
+		Created: time.Now().Add(-2 * time.Hour),
+	}))
+	result, err = staleCache.Get(ctx, "expired", func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		return nil, errors.New("fetch failed")
+	})
+	require.Error(t, err)
+	assert.Equal(t, smartcache.StaleHit, result.Type)
+	assert.EqualValues(t, 1, staleCache.Stats().StaleServedBeyondTarget)
+}
+
+func TestCache_CancelAllRefreshes(t *testing.T) {
+	t.Parallel()
+
+	data := "some data"
+
+	var blocking atomic.Bool
+	var started, exited atomic.Int32
+	fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		if blocking.Load() {
+			started.Add(1)
+			defer exited.Add(1)
+
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+
+		return &smartcache.FetchResult[string]{
+			Data: &data,
+		}, nil
+	}
+
+	backend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+
+	const primTTL = 100 * time.Millisecond
+	cache, err := smartcache.New[string](
+		backend,
+		smartcache.WithTTL(primTTL, time.Hour),
+	)
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	ctx := context.Background()
+	keys := []string{"key1", "key2", "key3"}
+
+	for _, key := range keys {
+		_, err := cache.Get(ctx, key, fetchFunc)
+		require.NoError(t, err)
+	}
+
+	// Make all keys warm, and start blocking refreshes.
+	time.Sleep(primTTL + 10*time.Millisecond)
+	blocking.Store(true)
+	for _, key := range keys {
+		result, err := cache.Get(ctx, key, fetchFunc)
+		require.NoError(t, err)
+		assert.Equal(t, smartcache.WarmHit, result.Type)
+	}
+	require.Eventually(t, func() bool { return started.Load() == 3 }, time.Second, 10*time.Millisecond)
+	assert.EqualValues(t, 0, exited.Load())
+
+	cache.CancelAllRefreshes()
+	require.Eventually(t, func() bool { return exited.Load() == 3 }, time.Second, 10*time.Millisecond)
+
+	// Cached data is still served, and refreshes can start again.
+	for _, key := range keys {
+		result, err := cache.Get(ctx, key, fetchFunc)
+		require.NoError(t, err)
+		assert.Equal(t, smartcache.WarmHit, result.Type)
+		assert.Equal(t, data, *result.Data)
+	}
+	require.Eventually(t, func() bool { return started.Load() == 6 }, time.Second, 10*time.Millisecond)
+}
+
+func TestCache_ErrorTTLLongerThanSecondaryTTL(t *testing.T) {
+	t.Parallel()
+
+	s := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{
+		Addr: s.Addr(),
+	})
+
+	backend, err := redisbackend.NewBackend[string](rdb, "test")
+	require.NoError(t, err)
+
+	const secTTL = time.Second
+	cache, err := smartcache.New[string](
+		backend,
+		smartcache.WithTTL(secTTL/2, secTTL),
+		smartcache.WithErrorTTLFunc(func(err error) time.Duration { return time.Hour }),
+	)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	key := "some-key"
+	fetchErr := errors.New("fetch failed")
+
+	var calls atomic.Int32
+	fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		calls.Add(1)
+		return nil, fetchErr
+	}
+
+	_, err = cache.Get(ctx, key, fetchFunc)
+	assert.Equal(t, fetchErr, err)
+
+	// The redis key should outlive the secondary TTL, so the cached error is still returned.
+	s.FastForward(2 * secTTL)
+	result, err := cache.Get(ctx, key, fetchFunc)
+	assert.EqualError(t, err, fetchErr.Error())
+	assert.Equal(t, smartcache.HotHit, result.Type)
+	assert.EqualValues(t, 1, calls.Load())
+}
+
+func TestCache_ErrorTTLFuncKeyed(t *testing.T) {
+	t.Parallel()
+
+	backend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+
+	errNotFound := errors.New("not found")
+	cache, err := smartcache.New[string](
+		backend,
+		smartcache.WithErrorTTLFuncKeyed(func(key string, err error) time.Duration {
+			if strings.HasPrefix(key, "a:") && errors.Is(err, errNotFound) {
+				return time.Minute
+			}
+			return 0
+		}),
+	)
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	var calls atomic.Int32
+	fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		calls.Add(1)
+		return nil, errNotFound
+	}
+
+	ctx := context.Background()
+	for _, key := range []string{"a:1", "b:1"} {
+		for i := 0; i < 2; i++ {
+			_, err := cache.Get(ctx, key, fetchFunc)
+			assert.ErrorIs(t, err, errNotFound, key)
+		}
+	}
+
+	// The error is cached for "a:1" only.
+	assert.EqualValues(t, 3, calls.Load())
+}
+
+func TestCache_HotHitAllocations(t *testing.T) {
+	if raceEnabled {
+		t.Skip("allocations are not stable with the race detector")
+	}
+
+	backend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+
+	cache, err := smartcache.New[string](backend)
+	require.NoError(t, err)
+
+	data := "some data"
+	fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		return &smartcache.FetchResult[string]{
+			Data: &data,
+		}, nil
+	}
+
+	ctx := context.Background()
+	key := "some-key"
+
+	_, err = cache.Get(ctx, key, fetchFunc)
+	require.NoError(t, err)
+
+	allocs := testing.AllocsPerRun(1000, func() {
+		result, _ := cache.Get(ctx, key, fetchFunc)
+		if result.Type != smartcache.HotHit {
+			t.Fatal("unexpected result type")
+		}
+	})
+	assert.Zero(t, allocs)
+}
+
+func TestCache_KeyFreshnessFunc(t *testing.T) {
+	t.Parallel()
+
+	backend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+
+	const primTTL = 100 * time.Millisecond
+	cache, err := smartcache.New[string](
+		backend,
+		smartcache.WithTTL(primTTL, time.Hour),
+		smartcache.WithKeyFreshnessFunc(func(key string) bool {
+			return key != "strict"
+		}),
+	)
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	var calls atomic.Int32
+	fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		v := fmt.Sprintf("%s %d", key, calls.Add(1))
+		return &smartcache.FetchResult[string]{
+			Data: &v,
+		}, nil
+	}
+
+	ctx := context.Background()
+	for _, key := range []string{"strict", "relaxed"} {
+		result, err := cache.Get(ctx, key, fetchFunc)
+		require.NoError(t, err)
+		assert.Equal(t, smartcache.Miss, result.Type)
+	}
+
+	time.Sleep(primTTL + 10*time.Millisecond)
+
+	// Strict key is refetched in the foreground.
+	result, err := cache.Get(ctx, "strict", fetchFunc)
+	require.NoError(t, err)
+	assert.Equal(t, smartcache.Miss, result.Type)
+	assert.Equal(t, "strict 3", *result.Data)
+
+	// Relaxed key is served warm.
+	result, err = cache.Get(ctx, "relaxed", fetchFunc)
+	require.NoError(t, err)
+	assert.Equal(t, smartcache.WarmHit, result.Type)
+	assert.Equal(t, "relaxed 2", *result.Data)
+}
+
+func TestCache_ServeExpiredOnError(t *testing.T) {
+	t.Parallel()
+
+	data := "some data"
+	fetchErr := errors.New("fetch failed")
+
+	var shouldFail atomic.Bool
+	fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		if shouldFail.Load() {
+			return nil, fetchErr
+		}
+
+		return &smartcache.FetchResult[string]{
+			Data: &data,
+		}, nil
+	}
+
+	// The lru backend retains logically expired entries.
+	backend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+
+	const secTTL = 100 * time.Millisecond
+	cache, err := smartcache.New[string](
+		backend,
+		smartcache.WithTTL(secTTL/2, secTTL),
+		smartcache.WithServeExpiredOnError(),
+	)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	_, err = cache.Get(ctx, "key", fetchFunc)
+	require.NoError(t, err)
+
+	time.Sleep(secTTL + 10*time.Millisecond)
+	shouldFail.Store(true)
+
+	// Expired data is served along with the error.
+	result, err := cache.Get(ctx, "key", fetchFunc)
+	assert.ErrorIs(t, err, fetchErr)
+	assert.Equal(t, smartcache.StaleHit, result.Type)
+	assert.Equal(t, data, *result.Data)
+	assert.Greater(t, result.Age, secTTL)
+
+	// No data in the backend.
+	result, err = cache.Get(ctx, "missing", fetchFunc)
+	assert.ErrorIs(t, err, fetchErr)
+	assert.Equal(t, smartcache.Miss, result.Type)
+	assert.Nil(t, result.Data)
+}
+
+func TestCache_MaxInFlightKeys(t *testing.T) {
+	t.Parallel()
+
+	backend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+
+	const maxKeys = 2
+	cache, err := smartcache.New[string](
+		backend,
+		smartcache.WithMaxInFlightKeys(maxKeys),
+	)
+	require.NoError(t, err)
+
+	data := "some data"
+	var blocking atomic.Bool
+	started := make(chan struct{}, maxKeys)
+	ready := make(chan struct{})
+	fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		if blocking.Load() {
+			started <- struct{}{}
+			<-ready
+		}
+
+		return &smartcache.FetchResult[string]{
+			Data: &data,
+		}, nil
+	}
+
+	ctx := context.Background()
+
+	_, err = cache.Get(ctx, "hot", fetchFunc)
+	require.NoError(t, err)
+
+	// Occupy all fetch slots.
+	blocking.Store(true)
+	var wg sync.WaitGroup
+	for i := 0; i < maxKeys; i++ {
+		wg.Add(1)
+		go func(key string) {
+			defer wg.Done()
+			_, err := cache.Get(ctx, key, fetchFunc)
+			assert.NoError(t, err)
+		}(fmt.Sprintf("cold%d", i))
+	}
+	for i := 0; i < maxKeys; i++ {
+		<-started
+	}
+
+	// Excess cold keys are shed.
+	for i := 0; i < 3; i++ {
+		_, err = cache.Get(ctx, fmt.Sprintf("excess%d", i), fetchFunc)
+		assert.ErrorIs(t, err, smartcache.ErrTooManyInFlight)
+	}
+
+	// Hot reads are not affected.
+	result, err := cache.Get(ctx, "hot", fetchFunc)
+	assert.NoError(t, err)
+	assert.Equal(t, smartcache.HotHit, result.Type)
+
+	close(ready)
+	wg.Wait()
+
+	// After the fetches completed, new keys can be fetched again.
+	_, err = cache.Get(ctx, "excess0", fetchFunc)
+	assert.NoError(t, err)
+}
+
+func TestCache_MaxInFlightKeysDetached(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	newCache := func(t *testing.T, options ...smartcache.Option) *smartcache.Cache[string] {
+		backend, err := lru.NewBackend[string](100)
+		require.NoError(t, err)
+		cache, err := smartcache.New[string](backend, append([]smartcache.Option{smartcache.WithMaxInFlightKeys(1)}, options...)...)
+		require.NoError(t, err)
+		t.Cleanup(cache.Close)
+
+		return cache
+	}
+	blockingFetch := func(ready <-chan struct{}) smartcache.FetchFunc[string] {
+		return func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+			<-ready
+			data := "data"
+			return &smartcache.FetchResult[string]{Data: &data}, nil
+		}
+	}
+
+	t.Run("non-blocking miss", func(t *testing.T) {
+		cache := newCache(t, smartcache.WithNonBlockingMiss())
+		ready := make(chan struct{})
+
+		_, err := cache.Get(ctx, "cold0", blockingFetch(ready))
+		assert.ErrorIs(t, err, smartcache.ErrFetchInProgress)
+
+		// The background fetch still holds the only slot.
+		for i := 1; i < 4; i++ {
+			_, err = cache.Get(ctx, fmt.Sprintf("cold%d", i), blockingFetch(ready))
+			assert.ErrorIs(t, err, smartcache.ErrTooManyInFlight)
+		}
+
+		close(ready)
+		require.Eventually(t, func() bool {
+			_, err := cache.Get(ctx, "cold1", blockingFetch(ready))
+			return errors.Is(err, smartcache.ErrFetchInProgress)
+		}, time.Second, time.Millisecond)
+	})
+
+	t.Run("fetch budget exceeded", func(t *testing.T) {
+		cache := newCache(t)
+		ready := make(chan struct{})
+
+		_, err := cache.GetWithin(ctx, "cold0", blockingFetch(ready), time.Millisecond)
+		assert.ErrorIs(t, err, smartcache.ErrFetchBudgetExceeded)
+
+		_, err = cache.GetWithin(ctx, "cold1", blockingFetch(ready), time.Millisecond)
+		assert.ErrorIs(t, err, smartcache.ErrTooManyInFlight)
+
+		close(ready)
+		require.Eventually(t, func() bool {
+			_, err := cache.GetWithin(ctx, "cold1", blockingFetch(ready), time.Second)
+			return err == nil
+		}, time.Second, time.Millisecond)
+	})
+}
+
+func TestCache_KeyValidator(t *testing.T) {
+	t.Parallel()
+
+	errInvalidKey := errors.New("invalid key")
+
+	backend := newCountingBackend(t)
+	cache, err := smartcache.New[string](
+		backend,
+		smartcache.WithKeyValidator(func(key string) error {
+			if key == "" || len(key) > 10 {
+				return errInvalidKey
+			}
+			return nil
+		}),
+	)
+	require.NoError(t, err)
+
+	fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		t.Fatal("unexpected fetchFunc call")
+		return nil, nil
+	}
+
+	ctx := context.Background()
+	for _, key := range []string{"", "too-long-key"} {
+		_, err = cache.Get(ctx, key, fetchFunc)
+		assert.ErrorIs(t, err, errInvalidKey)
+
+		_, err = cache.RefreshAndWait(ctx, key, fetchFunc)
+		assert.ErrorIs(t, err, errInvalidKey)
+	}
+	assert.EqualValues(t, 0, backend.gets.Load())
+}
+
+func TestCache_CoalescingStats(t *testing.T) {
+	t.Parallel()
+
+	backend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+
+	cache, err := smartcache.New[string](backend)
+	require.NoError(t, err)
+
+	data := "some data"
+	ready := make(chan struct{})
+	fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		<-ready
+		return &smartcache.FetchResult[string]{
+			Data: &data,
+		}, nil
+	}
+
+	const numCalls = 10
+	var wg sync.WaitGroup
+	for i := 0; i < numCalls; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := cache.Get(context.Background(), "key", fetchFunc)
+			assert.NoError(t, err)
+		}()
+	}
+
+	// Let all calls join before the fetch completes.
+	time.Sleep(100 * time.Millisecond)
+	close(ready)
+	wg.Wait()
+
+	stats := cache.Stats()
+	assert.EqualValues(t, 1, stats.FetchLeader)
+	assert.EqualValues(t, numCalls-1, stats.CoalescedFollower)
+
+	// A later hit is not a follower.
+	_, err = cache.Get(context.Background(), "key", fetchFunc)
+	assert.NoError(t, err)
+	assert.EqualValues(t, numCalls-1, cache.Stats().CoalescedFollower)
+}
+
+func TestCache_ResilienceMirror(t *testing.T) {
+	t.Parallel()
+
+	s := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{
+		Addr: s.Addr(),
+	})
+
+	backend, err := redisbackend.NewBackend[string](rdb, "test")
+	require.NoError(t, err)
+
+	cache, err := smartcache.New[string](
+		backend,
+		smartcache.WithResilienceMirror(10),
+	)
+	require.NoError(t, err)
+
+	data := "some data"
+	fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		return &smartcache.FetchResult[string]{
+			Data: &data,
+		}, nil
+	}
+
+	ctx := context.Background()
+
+	_, err = cache.Get(ctx, "key", fetchFunc)
+	require.NoError(t, err)
+
+	s.SetError("redis is down")
+
+	// The last seen value is served from the mirror.
+	result, err := cache.Get(ctx, "key", fetchFunc)
+	assert.NoError(t, err)
+	assert.Equal(t, smartcache.StaleHit, result.Type)
+	assert.Equal(t, data, *result.Data)
+	assert.EqualValues(t, 1, cache.Stats().StaleHits)
+
+	// Keys never seen still fail.
+	_, err = cache.Get(ctx, "other", fetchFunc)
+	assert.Error(t, err)
+}
+
+func TestCache_FetchResultType(t *testing.T) {
+	t.Parallel()
+
+	backend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+
+	cache, err := smartcache.New[string](backend)
+	require.NoError(t, err)
+
+	data := "some data"
+	fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		return &smartcache.FetchResult[string]{
+			Data: &data,
+			Type: smartcache.WarmHit,
+		}, nil
+	}
+
+	ctx := context.Background()
+
+	// The fetch overrides the miss.
+	result, err := cache.Get(ctx, "key", fetchFunc)
+	require.NoError(t, err)
+	assert.Equal(t, smartcache.WarmHit, result.Type)
+
+	// The entry is stored as usual, and is fresh.
+	result, err = cache.Get(ctx, "key", fetchFunc)
+	require.NoError(t, err)
+	assert.Equal(t, smartcache.HotHit, result.Type)
+}
+
+func TestCache_MutationDetection(t *testing.T) {
+	t.Parallel()
+
+	type profile struct {
+		Name string
+		Tags []string
+	}
+
+	backend, err := lru.NewBackend[profile](100)
+	require.NoError(t, err)
+
+	cache, err := smartcache.New[profile](
+		backend,
+		smartcache.WithMutationDetection(),
+	)
+	require.NoError(t, err)
+
+	fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[profile], error) {
+		return &smartcache.FetchResult[profile]{
+			Data: &profile{Name: key, Tags: []string{"a"}},
+		}, nil
+	}
+
+	ctx := context.Background()
+
+	// Reads without modifications are fine.
+	for i := 0; i < 3; i++ {
+		_, err = cache.Get(ctx, "key", fetchFunc)
+		require.NoError(t, err)
+	}
+
+	result, err := cache.Get(ctx, "key", fetchFunc)
+	require.NoError(t, err)
+	result.Data.Tags[0] = "modified"
+
+	assert.Panics(t, func() {
+		_, _ = cache.Get(ctx, "key", fetchFunc)
+	})
+}
+
+func TestCache_FetchFuncConsistencyCheck(t *testing.T) {
+	t.Parallel()
+
+	backend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+
+	cache, err := smartcache.New[string](
+		backend,
+		smartcache.WithFetchFuncConsistencyCheck(),
+	)
+	require.NoError(t, err)
+
+	newFetchFunc := func(value string) smartcache.FetchFunc[string] {
+		return func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+			return &smartcache.FetchResult[string]{Data: &value}, nil
+		}
+	}
+	otherFetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		return nil, errors.New("unexpected fetch")
+	}
+
+	ctx := context.Background()
+
+	// Closures of the same function literal are the same fetch function.
+	for _, value := range []string{"a", "b"} {
+		_, err = cache.Get(ctx, "key", newFetchFunc(value))
+		require.NoError(t, err)
+	}
+
+	// Other keys are independent.
+	_, err = cache.Get(ctx, "other", otherFetchFunc)
+	require.Error(t, err)
+
+	assert.Panics(t, func() {
+		_, _ = cache.Get(ctx, "key", otherFetchFunc)
+	})
+}
+
+func TestCache_GetWithEntry(t *testing.T) {
+	t.Parallel()
+
+	backend := newCountingBackend(t)
+
+	const primTTL = time.Minute
+	const secTTL = time.Hour
+	cache, err := smartcache.New[string](
+		backend,
+		smartcache.WithTTL(primTTL, secTTL),
+	)
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	fresh := "fresh"
+	var calls atomic.Int32
+	fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		calls.Add(1)
+		return &smartcache.FetchResult[string]{
+			Data: &fresh,
+		}, nil
+	}
+
+	ctx := context.Background()
+	cached := "cached"
+	entryWithAge := func(age time.Duration) *smartcache.CacheEntry[string] {
+		return &smartcache.CacheEntry[string]{
+			Data:    &cached,
+			Created: time.Now().Add(-age),
+		}
+	}
+
+	result, err := cache.GetWithEntry(ctx, "hot", entryWithAge(time.Second), fetchFunc)
+	require.NoError(t, err)
+	assert.Equal(t, smartcache.HotHit, result.Type)
+	assert.Equal(t, cached, *result.Data)
+	assert.EqualValues(t, 0, calls.Load())
+
+	result, err = cache.GetWithEntry(ctx, "warm", entryWithAge(primTTL+time.Second), fetchFunc)
+	require.NoError(t, err)
+	assert.Equal(t, smartcache.WarmHit, result.Type)
+	assert.Equal(t, cached, *result.Data)
+	assert.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, 10*time.Millisecond)
+
+	result, err = cache.GetWithEntry(ctx, "expired", entryWithAge(secTTL+time.Second), fetchFunc)
+	require.NoError(t, err)
+	assert.Equal(t, smartcache.Miss, result.Type)
+	assert.Equal(t, fresh, *result.Data)
+
+	result, err = cache.GetWithEntry(ctx, "missing", nil, fetchFunc)
+	require.NoError(t, err)
+	assert.Equal(t, smartcache.Miss, result.Type)
+
+	// The backend was never read, but fetched data was stored.
+	assert.EqualValues(t, 0, backend.gets.Load())
+	entry, err := backend.Backend.Get(ctx, "expired")
+	require.NoError(t, err)
+	assert.Equal(t, fresh, *entry.Data)
+}
+
+// logRecorder collects log lines.
+type logRecorder struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *logRecorder) Printf(format string, v ...any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.lines = append(l.lines, fmt.Sprintf(format, v...))
+}
+
+func (l *logRecorder) Lines() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return append([]string(nil), l.lines...)
+}
+
+func TestCache_TraceKey(t *testing.T) {
+	t.Parallel()
+
+	backend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+
+	logger := &logRecorder{}
+	cache, err := smartcache.New[string](
+		backend,
+		smartcache.WithLogger(logger),
+	)
+	require.NoError(t, err)
+
+	data := "some data"
+	fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		return &smartcache.FetchResult[string]{
+			Data: &data,
+		}, nil
+	}
+
+	ctx := context.Background()
+	cache.TraceKey("traced", true)
+
+	for _, key := range []string{"traced", "other", "traced", "other"} {
+		_, err := cache.Get(ctx, key, fetchFunc)
+		require.NoError(t, err)
+	}
+
+	lines := logger.Lines()
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], "key 'traced': decision Miss, entry missing")
+	assert.Contains(t, lines[1], "key 'traced': decision HotHit, entry age")
+
+	// Disabled tracing.
+	cache.TraceKey("traced", false)
+	_, err = cache.Get(ctx, "traced", fetchFunc)
+	require.NoError(t, err)
+	assert.Len(t, logger.Lines(), 2)
+}
+
+func BenchmarkCache_ExpectedKeys(b *testing.B) {
+	const numKeys = 10000
+
+	keys := make([]string, numKeys)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key%d", i)
+	}
+
+	for _, hint := range []int{0, numKeys} {
+		b.Run(fmt.Sprintf("hint=%d", hint), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				backend, err := lru.NewBackend[string](numKeys)
+				require.NoError(b, err)
+
+				cache, err := smartcache.New[string](backend, smartcache.WithExpectedKeys(hint))
+				require.NoError(b, err)
+
+				// Burst of distinct keys, all in flight at the same time.
+				var started sync.WaitGroup
+				started.Add(numKeys)
+				ready := make(chan struct{})
+				fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+					started.Done()
+					<-ready
+					return &smartcache.FetchResult[string]{
+						Data: &key,
+					}, nil
+				}
+
+				var done sync.WaitGroup
+				done.Add(numKeys)
+				for _, key := range keys {
+					go func(key string) {
+						defer done.Done()
+						_, _ = cache.Get(context.Background(), key, fetchFunc)
+					}(key)
+				}
+				started.Wait()
+				close(ready)
+				done.Wait()
+				cache.Close()
+			}
+		})
+	}
+}
+
+func TestCache_RefreshErrors(t *testing.T) {
+	t.Parallel()
+
+	data := "some data"
+	fetchErr := errors.New("fetch failed")
+
+	var shouldFail atomic.Bool
+	refreshed := make(chan struct{}, 10)
+	fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		defer func() {
+			select {
+			case refreshed <- struct{}{}:
+			default:
+			}
+		}()
+		if shouldFail.Load() {
+			return nil, fetchErr
+		}
+
+		return &smartcache.FetchResult[string]{
+			Data: &data,
+		}, nil
+	}
+
+	backend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+
+	const primaryTTL = 50 * time.Millisecond
+	cache, err := smartcache.New[string](
+		backend,
+		smartcache.WithTTL(primaryTTL, time.Hour),
+		smartcache.WithRefreshErrors(),
+	)
+	require.NoError(t, err)
+	defer cache.Close()
+
+	ctx := context.Background()
+
+	_, err = cache.Get(ctx, "key", fetchFunc)
+	require.NoError(t, err)
+	<-refreshed
+
+	time.Sleep(primaryTTL + 10*time.Millisecond)
+	shouldFail.Store(true)
+
+	// The first warm hit triggers a refresh, which fails.
+	result, err := cache.Get(ctx, "key", fetchFunc)
+	require.NoError(t, err)
+	assert.Equal(t, smartcache.WarmHit, result.Type)
+	assert.NoError(t, result.RefreshErr)
+	<-refreshed
+
+	// The next warm hit still serves the data, but carries the refresh error.
+	assert.Eventually(t, func() bool {
+		result, err = cache.Get(ctx, "key", fetchFunc)
+		return err == nil && result.Type == smartcache.WarmHit && errors.Is(result.RefreshErr, fetchErr)
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, data, *result.Data)
+
+	// A successful refresh clears the error.
+	shouldFail.Store(false)
+	_, err = cache.RefreshAndWait(ctx, "key", fetchFunc)
+	require.NoError(t, err)
+
+	time.Sleep(primaryTTL + 10*time.Millisecond)
+	result, err = cache.Get(ctx, "key", fetchFunc)
+	require.NoError(t, err)
+	assert.Equal(t, smartcache.WarmHit, result.Type)
+	assert.NoError(t, result.RefreshErr)
+}
+
+func TestCache_TTLAwareBackend(t *testing.T) {
+	t.Parallel()
+
+	s := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{
+		Addr: s.Addr(),
+	})
+
+	backend, err := redisbackend.NewBackend[string](rdb, "test")
+	require.NoError(t, err)
+
+	cache, err := smartcache.New[string](backend, smartcache.WithTTL(time.Minute, time.Hour))
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	ctx := context.Background()
+	data := "some data"
+	fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		return &smartcache.FetchResult[string]{Data: &data}, nil
+	}
+
+	for _, key := range []string{"hot", "warm"} {
+		_, err = cache.Get(ctx, key, fetchFunc)
+		require.NoError(t, err)
+	}
+
+	// Both entries are logically fresh, but an external process shortened the TTL of one of them in redis.
+	s.SetTTL("testwarm", 30*time.Minute)
+
+	result, err := cache.Get(ctx, "hot", fetchFunc)
+	require.NoError(t, err)
+	assert.Equal(t, smartcache.HotHit, result.Type)
+
+	result, err = cache.Get(ctx, "warm", fetchFunc)
+	require.NoError(t, err)
+	assert.Equal(t, smartcache.WarmHit, result.Type)
+	assert.Less(t, result.Age, time.Second, "age is still computed from the entry")
+}
+
+func TestCache_TTLAwareBackendBatchedGet(t *testing.T) {
+	t.Parallel()
+
+	s := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{
+		Addr: s.Addr(),
+	})
+
+	backend, err := redisbackend.NewBackend[string](rdb, "test")
+	require.NoError(t, err)
+
+	cache, err := smartcache.New[string](
+		backend,
+		smartcache.WithTTL(time.Minute, time.Hour),
+		smartcache.WithBatchedGet(time.Millisecond),
+	)
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	ctx := context.Background()
+	data := "some data"
+	fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		return &smartcache.FetchResult[string]{Data: &data}, nil
+	}
+
+	for _, key := range []string{"hot", "warm"} {
+		_, err = cache.Get(ctx, key, fetchFunc)
+		require.NoError(t, err)
+	}
+
+	// The TTL shortened in redis is read by the batched reads as well.
+	s.SetTTL("testwarm", 30*time.Minute)
+
+	result, err := cache.Get(ctx, "hot", fetchFunc)
+	require.NoError(t, err)
+	assert.Equal(t, smartcache.HotHit, result.Type)
+
+	result, err = cache.Get(ctx, "warm", fetchFunc)
+	require.NoError(t, err)
+	assert.Equal(t, smartcache.WarmHit, result.Type)
+
+	// A TTL-aware backend unable to read TTLs in batches would silently lose them.
+	_, err = smartcache.New[string](ttlAwareMultiGetBackend{backend}, smartcache.WithBatchedGet(time.Millisecond))
+	assert.Error(t, err)
+}
+
+// ttlAwareMultiGetBackend hides `MultiGetWithTTLBackend` of the wrapped backend.
+type ttlAwareMultiGetBackend struct {
+	backend *redisbackend.Backend[string]
+}
+
+func (b ttlAwareMultiGetBackend) Get(ctx context.Context, key string) (*smartcache.CacheEntry[string], error) {
+	return b.backend.Get(ctx, key)
+}
+
+func (b ttlAwareMultiGetBackend) GetWithTTL(ctx context.Context, key string) (*smartcache.CacheEntry[string], time.Duration, error) {
+	return b.backend.GetWithTTL(ctx, key)
+}
+
+func (b ttlAwareMultiGetBackend) GetMulti(ctx context.Context, keys []string) (map[string]*smartcache.CacheEntry[string], error) {
+	return b.backend.GetMulti(ctx, keys)
+}
+
+func (b ttlAwareMultiGetBackend) Set(ctx context.Context, key string, ttl time.Duration, data *smartcache.CacheEntry[string]) error {
+	return b.backend.Set(ctx, key, ttl, data)
+}
+
+func (b ttlAwareMultiGetBackend) Delete(ctx context.Context, key string) error {
+	return b.backend.Delete(ctx, key)
+}
+
+func (b ttlAwareMultiGetBackend) Close() {}
+
+func TestCache_GetRaw(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	data := "some data"
+	fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		return &smartcache.FetchResult[string]{Data: &data}, nil
+	}
+
+	t.Run("redis", func(t *testing.T) {
+		s := miniredis.RunT(t)
+		rdb := redis.NewClient(&redis.Options{
+			Addr: s.Addr(),
+		})
+		backend, err := redisbackend.NewBackend[string](rdb, "test")
+		require.NoError(t, err)
+		cache, err := smartcache.New[string](backend)
+		require.NoError(t, err)
+		t.Cleanup(cache.Close)
+
+		_, err = cache.Get(ctx, "key", fetchFunc)
+		require.NoError(t, err)
+
+		stored, err := s.Get("testkey")
+		require.NoError(t, err)
+
+		raw, found, err := cache.GetRaw(ctx, "key")
+		require.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, stored, string(raw))
+	})
+
+	t.Run("not supported", func(t *testing.T) {
+		backend, err := lru.NewBackend[string](100)
+		require.NoError(t, err)
+		cache, err := smartcache.New[string](backend)
+		require.NoError(t, err)
+		t.Cleanup(cache.Close)
+
+		_, _, err = cache.GetRaw(ctx, "key")
+		assert.ErrorIs(t, err, smartcache.ErrNotSupported)
+	})
+}
+
+func TestCache_Expire(t *testing.T) {
+	t.Parallel()
+
+	backend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+
+	var handledErrs atomic.Int32
+	cache, err := smartcache.New[string](
+		backend,
+		smartcache.WithTTL(time.Minute, time.Hour),
+		smartcache.WithBackgroundFetchErrorHandler(func(err error) { handledErrs.Add(1) }),
+	)
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	ctx := context.Background()
+	fetchErr := errors.New("fetch failed")
+	var fetches atomic.Int32
+	var shouldFail atomic.Bool
+	fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		n := fetches.Add(1)
+		if shouldFail.Load() {
+			return nil, fetchErr
+		}
+		data := fmt.Sprintf("data-%d", n)
+		return &smartcache.FetchResult[string]{Data: &data}, nil
+	}
+
+	_, err = cache.Get(ctx, "key", fetchFunc)
+	require.NoError(t, err)
+
+	// Expired entry is served as warm and refreshed in the background.
+	require.NoError(t, cache.Expire(ctx, "key"))
+	result, err := cache.Get(ctx, "key", fetchFunc)
+	require.NoError(t, err)
+	assert.Equal(t, smartcache.WarmHit, result.Type)
+	assert.Equal(t, "data-1", *result.Data)
+	require.Eventually(t, func() bool {
+		result, err = cache.Get(ctx, "key", fetchFunc)
+		return err == nil && result.Type == smartcache.HotHit
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, "data-2", *result.Data)
+
+	// A failed refresh still leaves the old data available.
+	shouldFail.Store(true)
+	require.NoError(t, cache.Expire(ctx, "key"))
+	result, err = cache.Get(ctx, "key", fetchFunc)
+	require.NoError(t, err)
+	assert.Equal(t, smartcache.WarmHit, result.Type)
+	require.Eventually(t, func() bool { return handledErrs.Load() == 1 }, time.Second, 5*time.Millisecond)
+
+	result, err = cache.Get(ctx, "key", fetchFunc)
+	require.NoError(t, err)
+	assert.Equal(t, smartcache.WarmHit, result.Type)
+	assert.Equal(t, "data-2", *result.Data)
+
+	// Missing keys are ignored.
+	assert.NoError(t, cache.Expire(ctx, "missing"))
+}
+
+func TestCache_ExpireDegraded(t *testing.T) {
+	t.Parallel()
+
+	backend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+
+	// The primary TTL is extended to 10 minutes while the upstream is degraded.
+	cache, err := smartcache.New[string](
+		backend,
+		smartcache.WithTTL(time.Minute, time.Hour),
+		smartcache.WithHealthSource(func() smartcache.Health { return smartcache.Degraded }, 10),
+	)
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	ctx := context.Background()
+	data := "data"
+	require.NoError(t, cache.Set(ctx, "key", &data))
+	require.NoError(t, cache.Expire(ctx, "key"))
+
+	result, err := cache.Lookup(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, smartcache.WarmHit, result.Type)
+	assert.Greater(t, result.Age, 10*time.Minute)
+}
+
+func TestCache_ConfirmValidAndReportInvalid(t *testing.T) {
+	t.Parallel()
+
+	backend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+
+	clock := clocktest.New(time.Now())
+	cache, err := smartcache.New[string](
+		backend,
+		smartcache.WithTTL(time.Minute, time.Hour),
+		smartcache.WithClock(clock),
+	)
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	ctx := context.Background()
+	var fetches atomic.Int32
+	fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		data := fmt.Sprintf("data-%d", fetches.Add(1))
+		return &smartcache.FetchResult[string]{Data: &data}, nil
+	}
+
+	_, err = cache.Get(ctx, "key", fetchFunc)
+	require.NoError(t, err)
+
+	// Confirming the entry right before it gets warm keeps it hot.
+	clock.Add(time.Minute - time.Second)
+	require.NoError(t, cache.ConfirmValid(ctx, "key"))
+	clock.Add(30 * time.Second)
+	result, err := cache.Get(ctx, "key", fetchFunc)
+	require.NoError(t, err)
+	assert.Equal(t, smartcache.HotHit, result.Type)
+	assert.Equal(t, 30*time.Second, result.Age)
+	assert.Equal(t, "data-1", *result.Data)
+
+	// Reporting the entry invalid makes the next Get fetch it again.
+	require.NoError(t, cache.ReportInvalid(ctx, "key"))
+	result, err = cache.Get(ctx, "key", fetchFunc)
+	require.NoError(t, err)
+	assert.Equal(t, smartcache.Miss, result.Type)
+	assert.Equal(t, "data-2", *result.Data)
+	assert.EqualValues(t, 2, fetches.Load())
+
+	// Missing keys are ignored.
+	assert.NoError(t, cache.ConfirmValid(ctx, "missing"))
+	assert.NoError(t, cache.ReportInvalid(ctx, "missing"))
+	result, err = cache.Lookup(ctx, "missing")
+	assert.ErrorIs(t, err, smartcache.ErrCacheMiss)
+	assert.Equal(t, smartcache.Miss, result.Type)
+}
+
+func TestCache_ErrorTTLs(t *testing.T) {
+	t.Parallel()
+
+	backend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+
+	const errPrimTTL = 50 * time.Millisecond
+	cache, err := smartcache.New[string](
+		backend,
+		smartcache.WithTTL(time.Minute, time.Hour),
+		smartcache.WithErrorTTLs(errPrimTTL, time.Hour),
+		// Decides that the error is cached, but it expires with the error TTLs.
+		smartcache.WithErrorTTLFunc(func(err error) time.Duration { return time.Hour }),
+	)
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	ctx := context.Background()
+	fetchErr := errors.New("fetch failed")
+	var fetches atomic.Int32
+	retried := make(chan struct{}, 1)
+	fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		if fetches.Add(1) > 1 {
+			retried <- struct{}{}
+		}
+		return nil, fetchErr
+	}
+
+	result, err := cache.Get(ctx, "key", fetchFunc)
+	assert.ErrorIs(t, err, fetchErr)
+	assert.Equal(t, smartcache.Miss, result.Type)
+
+	result, err = cache.Get(ctx, "key", fetchFunc)
+	assert.ErrorIs(t, err, fetchErr)
+	assert.Equal(t, smartcache.HotHit, result.Type)
+	assert.EqualValues(t, 1, fetches.Load())
+
+	// Warm error is served while the fetch is retried in the background.
+	time.Sleep(errPrimTTL + 10*time.Millisecond)
+	result, err = cache.Get(ctx, "key", fetchFunc)
+	assert.ErrorIs(t, err, fetchErr)
+	assert.Equal(t, smartcache.WarmHit, result.Type)
+
+	select {
+	case <-retried:
+	case <-time.After(time.Second):
+		t.Fatal("background retry didn't run")
+	}
+}
+
+func TestCache_ErrorTTLsNotCached(t *testing.T) {
+	t.Parallel()
+
+	fetchErr := errors.New("fetch failed")
+	newCache := func(t *testing.T, options ...smartcache.Option) *smartcache.Cache[string] {
+		backend, err := lru.NewBackend[string](100)
+		require.NoError(t, err)
+		cache, err := smartcache.New[string](
+			backend,
+			append([]smartcache.Option{smartcache.WithErrorTTLs(time.Minute, time.Hour)}, options...)...,
+		)
+		require.NoError(t, err)
+		t.Cleanup(cache.Close)
+
+		return cache
+	}
+
+	t.Run("cancelled caller", func(t *testing.T) {
+		cache := newCache(t)
+
+		callerCtx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		_, err := cache.Get(callerCtx, "key", func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+			cancel()
+			return nil, ctx.Err()
+		})
+		assert.ErrorIs(t, err, context.Canceled)
+
+		// The cancellation isn't served to other callers.
+		var fetches atomic.Int32
+		result, err := cache.Get(context.Background(), "key", func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+			fetches.Add(1)
+			data := "data"
+			return &smartcache.FetchResult[string]{Data: &data}, nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, smartcache.Miss, result.Type)
+		assert.Equal(t, "data", *result.Data)
+		assert.EqualValues(t, 1, fetches.Load())
+	})
+
+	t.Run("error ttl func returning 0", func(t *testing.T) {
+		cache := newCache(t, smartcache.WithErrorTTLFunc(func(err error) time.Duration { return 0 }))
+
+		var fetches atomic.Int32
+		fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+			fetches.Add(1)
+			return nil, fetchErr
+		}
+		for i := 0; i < 2; i++ {
+			result, err := cache.Get(context.Background(), "key", fetchFunc)
+			assert.ErrorIs(t, err, fetchErr)
+			assert.Equal(t, smartcache.Miss, result.Type)
+		}
+		assert.EqualValues(t, 2, fetches.Load())
+	})
+}
+
+func TestCache_ErrorRefreshAfter(t *testing.T) {
+	t.Parallel()
+
+	backend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+
+	_, err = smartcache.New[string](backend, smartcache.WithErrorRefreshAfter(0))
+	require.Error(t, err)
+
+	const refreshAfter = time.Second
+	clock := clocktest.New(time.Now())
+	cache, err := smartcache.New[string](
+		backend,
+		smartcache.WithTTL(time.Minute, time.Hour),
+		smartcache.WithClock(clock),
+		smartcache.WithErrorTTLFunc(func(err error) time.Duration { return time.Minute }),
+		smartcache.WithErrorRefreshAfter(refreshAfter),
+	)
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	ctx := context.Background()
+	fetchErr := errors.New("fetch failed")
+	var healthy atomic.Bool
+	var fetches atomic.Int32
+	fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		fetches.Add(1)
+		if !healthy.Load() {
+			return nil, fetchErr
+		}
+		data := "data"
+		return &smartcache.FetchResult[string]{Data: &data}, nil
+	}
+
+	result, err := cache.Get(ctx, "key", fetchFunc)
+	assert.ErrorIs(t, err, fetchErr)
+	assert.Equal(t, smartcache.Miss, result.Type)
+
+	// The error is hot at first.
+	result, err = cache.Get(ctx, "key", fetchFunc)
+	assert.ErrorIs(t, err, fetchErr)
+	assert.Equal(t, smartcache.HotHit, result.Type)
+	assert.EqualValues(t, 1, fetches.Load())
+
+	// Then it's returned while the fetch is retried in the background.
+	healthy.Store(true)
+	clock.Add(refreshAfter + time.Millisecond)
+	result, err = cache.Get(ctx, "key", fetchFunc)
+	assert.ErrorIs(t, err, fetchErr)
+	assert.Equal(t, smartcache.WarmHit, result.Type)
+
+	require.Eventually(t, func() bool {
+		result, err = cache.Get(ctx, "key", fetchFunc)
+		return err == nil && result.Type == smartcache.HotHit
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, "data", *result.Data)
+	assert.EqualValues(t, 2, fetches.Load())
+}
+
+func TestCache_CachedError(t *testing.T) {
+	t.Parallel()
+
+	backend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+
+	cache, err := smartcache.New[string](
+		backend,
+		smartcache.WithTTL(time.Minute, time.Hour),
+		smartcache.WithErrorTTLs(time.Minute, time.Hour),
+	)
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	ctx := context.Background()
+	fetchErr := errors.New("fetch failed")
+	fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		return nil, fetchErr
+	}
+
+	// The error of a live fetch is returned as is.
+	var cachedErr *smartcache.CachedError
+	result, err := cache.Get(ctx, "key", fetchFunc)
+	assert.Equal(t, smartcache.Miss, result.Type)
+	assert.ErrorIs(t, err, fetchErr)
+	assert.False(t, errors.As(err, &cachedErr))
+
+	// The same error served from the cache is wrapped.
+	result, err = cache.Get(ctx, "key", fetchFunc)
+	assert.Equal(t, smartcache.HotHit, result.Type)
+	assert.ErrorIs(t, err, fetchErr)
+	require.True(t, errors.As(err, &cachedErr))
+	assert.Equal(t, fetchErr, cachedErr.Err)
+	assert.Equal(t, fetchErr.Error(), err.Error())
+
+	_, err = cache.Lookup(ctx, "key")
+	assert.ErrorIs(t, err, fetchErr)
+	assert.True(t, errors.As(err, &cachedErr))
+
+	_, errs := cache.GetMulti(ctx, []string{"key"}, func(ctx context.Context, keys []string) (map[string]*smartcache.FetchResult[string], error) {
+		return nil, nil
+	})
+	assert.ErrorIs(t, errs["key"], fetchErr)
+	assert.True(t, errors.As(errs["key"], &cachedErr))
+}
+
+func TestCache_FetchPanic(t *testing.T) {
+	t.Parallel()
+
+	backend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+
+	const primTTL = time.Minute
+	clock := clocktest.New(time.Now())
+	backgroundErrs := make(chan error, 1)
+	cache, err := smartcache.New[string](
+		backend,
+		smartcache.WithTTL(primTTL, time.Hour),
+		smartcache.WithClock(clock),
+		smartcache.WithBackgroundFetchErrorHandler(func(err error) { backgroundErrs <- err }),
+	)
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	ctx := context.Background()
+	var shouldPanic atomic.Bool
+	shouldPanic.Store(true)
+	fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		if shouldPanic.Load() {
+			panic("boom")
+		}
+		data := "data"
+		return &smartcache.FetchResult[string]{Data: &data}, nil
+	}
+
+	// A foreground panic is returned as an error.
+	var panicErr *smartcache.PanicError
+	_, err = cache.Get(ctx, "key", fetchFunc)
+	require.True(t, errors.As(err, &panicErr))
+	assert.Equal(t, "boom", panicErr.Value)
+	assert.Contains(t, string(panicErr.Stack), "TestCache_FetchPanic")
+
+	_, errs := cache.GetMulti(ctx, []string{"multi"}, func(ctx context.Context, keys []string) (map[string]*smartcache.FetchResult[string], error) {
+		panic("boom")
+	})
+	assert.True(t, errors.As(errs["multi"], &panicErr))
+
+	// The key is usable afterwards.
+	shouldPanic.Store(false)
+	result, err := cache.Get(ctx, "key", fetchFunc)
+	require.NoError(t, err)
+	assert.Equal(t, smartcache.Miss, result.Type)
+
+	// A background panic is passed to the error handler.
+	shouldPanic.Store(true)
+	clock.Add(primTTL + time.Second)
+	result, err = cache.Get(ctx, "key", fetchFunc)
+	require.NoError(t, err)
+	assert.Equal(t, smartcache.WarmHit, result.Type)
+	select {
+	case err := <-backgroundErrs:
+		assert.True(t, errors.As(err, &panicErr))
+	case <-time.After(time.Second):
+		t.Fatal("background panic wasn't reported")
+	}
+}
+
+func BenchmarkCache_LockPool(b *testing.B) {
+	const numHotKeys = 100
+
+	hotKeys := make([]string, numHotKeys)
+	for i := range hotKeys {
+		hotKeys[i] = fmt.Sprintf("key%d", i)
+	}
+
+	for _, size := range []int{0, numHotKeys} {
+		b.Run(fmt.Sprintf("size=%d", size), func(b *testing.B) {
+			backend, err := lru.NewBackend[string](numHotKeys * 2)
+			require.NoError(b, err)
+
+			cache, err := smartcache.New[string](backend, smartcache.WithLockPoolSize(size))
+			require.NoError(b, err)
+			b.Cleanup(cache.Close)
+
+			fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+				return &smartcache.FetchResult[string]{
+					Data: &key,
+				}, nil
+			}
+
+			ctx := context.Background()
+			var n atomic.Int64
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					// Mixed workload: hits of hot keys, and a churn of unique keys that keeps the GC busy,
+					// which regularly empties the pool of free locks.
+					i := n.Add(1)
+					if i%10 == 0 {
+						_, _ = cache.Get(ctx, strconv.FormatInt(i, 10), fetchFunc)
+						continue
+					}
+					_, _ = cache.Get(ctx, hotKeys[i%numHotKeys], fetchFunc)
+				}
+			})
+		})
+	}
+}
+
+func TestCache_BumpEpoch(t *testing.T) {
+	t.Parallel()
+
+	s := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{
+		Addr: s.Addr(),
+	})
+	backend, err := redisbackend.NewBackend[string](rdb, "test")
+	require.NoError(t, err)
+
+	cache, err := smartcache.New[string](backend)
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	ctx := context.Background()
+	var fetches atomic.Int32
+	fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		data := fmt.Sprintf("%s-%d", key, fetches.Add(1))
+		return &smartcache.FetchResult[string]{Data: &data}, nil
+	}
+
+	for _, key := range []string{"a", "b"} {
+		_, err := cache.Get(ctx, key, fetchFunc)
+		require.NoError(t, err)
+		result, err := cache.Get(ctx, key, fetchFunc)
+		require.NoError(t, err)
+		assert.Equal(t, smartcache.HotHit, result.Type)
+	}
+
+	cache.BumpEpoch()
+
+	// All prior entries are misses, new ones are valid.
+	for _, key := range []string{"a", "b"} {
+		result, err := cache.Get(ctx, key, fetchFunc)
+		require.NoError(t, err)
+		assert.Equal(t, smartcache.Miss, result.Type)
+
+		result, err = cache.Get(ctx, key, fetchFunc)
+		require.NoError(t, err)
+		assert.Equal(t, smartcache.HotHit, result.Type)
+	}
+	assert.EqualValues(t, 4, fetches.Load())
+}
+
+func TestCache_GetWithin(t *testing.T) {
+	t.Parallel()
+
+	const secTTL = time.Hour
+	newCache := func(t *testing.T) (*smartcache.Cache[string], *lru.Backend[string]) {
+		backend, err := lru.NewBackend[string](100)
+		require.NoError(t, err)
+		cache, err := smartcache.New[string](backend, smartcache.WithTTL(time.Minute, secTTL))
+		require.NoError(t, err)
+		t.Cleanup(cache.Close)
+
+		return cache, backend
+	}
+
+	ctx := context.Background()
+	var fetches atomic.Int32
+	slowFetch := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		fetches.Add(1)
+		time.Sleep(100 * time.Millisecond)
+		data := "fresh"
+		return &smartcache.FetchResult[string]{Data: &data}, nil
+	}
+
+	t.Run("within budget", func(t *testing.T) {
+		cache, _ := newCache(t)
+
+		result, err := cache.GetWithin(ctx, "key", slowFetch, time.Second)
+		require.NoError(t, err)
+		assert.Equal(t, smartcache.Miss, result.Type)
+		assert.Equal(t, "fresh", *result.Data)
+	})
+
+	t.Run("budget exceeded", func(t *testing.T) {
+		cache, _ := newCache(t)
+		before := fetches.Load()
+
+		start := time.Now()
+		result, err := cache.GetWithin(ctx, "key", slowFetch, 10*time.Millisecond)
+		assert.ErrorIs(t, err, smartcache.ErrFetchBudgetExceeded)
+		assert.Less(t, time.Since(start), 90*time.Millisecond)
+		assert.Equal(t, smartcache.Miss, result.Type)
+		assert.Nil(t, result.Data)
+
+		// The fetch continues, and populates the cache for later calls.
+		result, err = cache.Get(ctx, "key", slowFetch)
+		require.NoError(t, err)
+		assert.Equal(t, smartcache.HotHit, result.Type)
+		assert.Equal(t, "fresh", *result.Data)
+		assert.Equal(t, before+1, fetches.Load())
+	})
+
+	t.Run("budget exceeded with expired data", func(t *testing.T) {
+		cache, backend := newCache(t)
+
+		old := "old"
+		require.NoError(t, backend.Set(ctx, "key", secTTL, &smartcache.CacheEntry[string]{
+			Data:    &old,
+			Created: time.Now().Add(-2 * secTTL),
+		}))
+
+		result, err := cache.GetWithin(ctx, "key", slowFetch, 10*time.Millisecond)
+		assert.ErrorIs(t, err, smartcache.ErrFetchBudgetExceeded)
+		assert.Equal(t, smartcache.StaleHit, result.Type)
+		assert.Equal(t, old, *result.Data)
+
+		assert.Eventually(t, func() bool {
+			result, err := cache.GetWithin(ctx, "key", slowFetch, 10*time.Millisecond)
+			return err == nil && result.Type == smartcache.HotHit && *result.Data == "fresh"
+		}, time.Second, 10*time.Millisecond)
+	})
+}
+
+func TestCache_NonBlockingMiss(t *testing.T) {
+	t.Parallel()
+
+	backend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+	cache, err := smartcache.New[string](
+		backend,
+		smartcache.WithTTL(time.Minute, time.Hour),
+		smartcache.WithNonBlockingMiss(),
+	)
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	ctx := context.Background()
+	var fetches atomic.Int32
+	release := make(chan struct{})
+	slowFetch := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		fetches.Add(1)
+		<-release
+		data := "fresh"
+		return &smartcache.FetchResult[string]{Data: &data}, nil
+	}
+
+	// Cold reads return immediately, while the data is fetched once in the background.
+	for i := 0; i < 3; i++ {
+		start := time.Now()
+		result, err := cache.Get(ctx, "key", slowFetch)
+		assert.ErrorIs(t, err, smartcache.ErrFetchInProgress)
+		assert.Less(t, time.Since(start), 100*time.Millisecond)
+		assert.Equal(t, smartcache.Miss, result.Type)
+		assert.Nil(t, result.Data)
+	}
+	close(release)
+
+	assert.Eventually(t, func() bool {
+		result, err := cache.Get(ctx, "key", slowFetch)
+		return err == nil && result.Type == smartcache.HotHit && *result.Data == "fresh"
+	}, time.Second, 10*time.Millisecond)
+	assert.EqualValues(t, 1, fetches.Load())
+}
+
+func TestCache_SchemaVersion(t *testing.T) {
+	t.Parallel()
+
+	newRedisBackend := func(t *testing.T) smartcache.Backend[string] {
+		s := miniredis.RunT(t)
+		rdb := redis.NewClient(&redis.Options{
+			Addr: s.Addr(),
+		})
+		backend, err := redisbackend.NewBackend[string](rdb, "test")
+		require.NoError(t, err)
+
+		return backend
+	}
+	newLRUBackend := func(t *testing.T) smartcache.Backend[string] {
+		backend, err := lru.NewBackend[string](100)
+		require.NoError(t, err)
+
+		return backend
+	}
+
+	for name, newBackend := range map[string]func(t *testing.T) smartcache.Backend[string]{
+		"redis": newRedisBackend,
+		"lru":   newLRUBackend,
+	} {
+		newBackend := newBackend
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			backend := newBackend(t)
+			ctx := context.Background()
+			fetchFunc := func(version string) smartcache.FetchFunc[string] {
+				return func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+					return &smartcache.FetchResult[string]{Data: &version}, nil
+				}
+			}
+
+			// Old and new deployment share the backend.
+			v1, err := smartcache.New[string](backend, smartcache.WithSchemaVersion("v1"))
+			require.NoError(t, err)
+			t.Cleanup(v1.Close)
+			v2, err := smartcache.New[string](backend, smartcache.WithSchemaVersion("v2"))
+			require.NoError(t, err)
+			t.Cleanup(v2.Close)
+
+			_, err = v1.Get(ctx, "key", fetchFunc("v1"))
+			require.NoError(t, err)
+			result, err := v1.Get(ctx, "key", fetchFunc("v1"))
+			require.NoError(t, err)
+			assert.Equal(t, smartcache.HotHit, result.Type)
+
+			// Version mismatch is a miss.
+			result, err = v2.Get(ctx, "key", fetchFunc("v2"))
+			require.NoError(t, err)
+			assert.Equal(t, smartcache.Miss, result.Type)
+			assert.Equal(t, "v2", *result.Data)
+
+			result, err = v2.Get(ctx, "key", fetchFunc("v2"))
+			require.NoError(t, err)
+			assert.Equal(t, smartcache.HotHit, result.Type)
+
+			result, err = v1.Get(ctx, "key", fetchFunc("v1"))
+			require.NoError(t, err)
+			assert.Equal(t, smartcache.Miss, result.Type)
+		})
+	}
+}
+
+func TestResultType_String(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		resultType smartcache.ResultType
+		want       string
+	}{
+		{smartcache.Miss, "Miss"},
+		{smartcache.WarmHit, "WarmHit"},
+		{smartcache.HotHit, "HotHit"},
+		{smartcache.StaleHit, "StaleHit"},
+		{smartcache.ResultType(42), "Unknown(42)"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, tt.resultType.String())
+	}
+}
+
+func TestCache_ResultAge(t *testing.T) {
+	t.Parallel()
+
+	backend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+
+	const primTTL = 50 * time.Millisecond
+	cache, err := smartcache.New[string](backend, smartcache.WithTTL(primTTL, time.Hour))
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	refreshed := make(chan struct{}, 1)
+	fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		defer func() {
+			select {
+			case refreshed <- struct{}{}:
+			default:
+			}
+		}()
+		data := "data"
+		return &smartcache.FetchResult[string]{Data: &data}, nil
+	}
+
+	ctx := context.Background()
+	result, err := cache.Get(ctx, "key", fetchFunc)
+	require.NoError(t, err)
+	assert.Equal(t, smartcache.Miss, result.Type)
+	assert.Zero(t, result.Age)
+	<-refreshed
+
+	time.Sleep(10 * time.Millisecond)
+	result, err = cache.Get(ctx, "key", fetchFunc)
+	require.NoError(t, err)
+	assert.Equal(t, smartcache.HotHit, result.Type)
+	assert.GreaterOrEqual(t, result.Age, 10*time.Millisecond)
+	assert.Less(t, result.Age, primTTL)
+
+	time.Sleep(primTTL)
+	warm, err := cache.Get(ctx, "key", fetchFunc)
+	require.NoError(t, err)
+	assert.Equal(t, smartcache.WarmHit, warm.Type)
+	assert.Greater(t, warm.Age, primTTL)
+
+	// The background refresh doesn't change the returned result.
+	age := warm.Age
+	<-refreshed
+	assert.Equal(t, age, warm.Age)
+	result, err = cache.Get(ctx, "key", fetchFunc)
+	require.NoError(t, err)
+	assert.Equal(t, smartcache.HotHit, result.Type)
+	assert.Less(t, result.Age, age)
+}
+
+func TestCache_ResultCreatedAt(t *testing.T) {
+	t.Parallel()
+
+	backend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+
+	const primTTL = time.Hour
+	cache, err := smartcache.New[string](backend, smartcache.WithTTL(primTTL, 2*primTTL))
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	createdAt := time.Now().Add(-time.Minute).Round(0)
+	fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		data := "data"
+		return &smartcache.FetchResult[string]{Data: &data, CreatedAt: createdAt}, nil
+	}
+
+	ctx := context.Background()
+	result, err := cache.Get(ctx, "key", fetchFunc)
+	require.NoError(t, err)
+	assert.Equal(t, smartcache.Miss, result.Type)
+	assert.Equal(t, createdAt, result.CreatedAt)
+
+	entry, err := backend.Get(ctx, "key")
+	require.NoError(t, err)
+
+	result, err = cache.Get(ctx, "key", fetchFunc)
+	require.NoError(t, err)
+	assert.Equal(t, smartcache.HotHit, result.Type)
+	assert.Equal(t, entry.Created, result.CreatedAt)
+	assert.Equal(t, createdAt, result.CreatedAt)
+
+	// Warm hits serve the stored entry's time.
+	stored := time.Now().Add(-primTTL - time.Minute).Round(0)
+	require.NoError(t, backend.Set(ctx, "key", time.Hour, &smartcache.CacheEntry[string]{
+		Data:    entry.Data,
+		Created: stored,
+	}))
+	result, err = cache.Get(ctx, "key", fetchFunc)
+	require.NoError(t, err)
+	assert.Equal(t, smartcache.WarmHit, result.Type)
+	assert.Equal(t, stored, result.CreatedAt)
+}
+
+func TestCache_FetchResultCreatedAt(t *testing.T) {
+	t.Parallel()
+
+	backend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+
+	const primTTL = time.Minute
+	cache, err := smartcache.New[string](backend, smartcache.WithTTL(primTTL, time.Hour))
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	fetchFunc := func(age time.Duration) smartcache.FetchFunc[string] {
+		return func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+			data := "data"
+			return &smartcache.FetchResult[string]{Data: &data, CreatedAt: time.Now().Add(-age)}, nil
+		}
+	}
+
+	tests := []struct {
+		key      string
+		age      time.Duration
+		wantType smartcache.ResultType
+	}{
+		{key: "recent", age: 0, wantType: smartcache.HotHit},
+		{key: "old", age: primTTL / 2, wantType: smartcache.HotHit},
+		{key: "older", age: 2 * primTTL, wantType: smartcache.WarmHit},
+	}
+
+	ctx := context.Background()
+	for _, tt := range tests {
+		_, err := cache.Get(ctx, tt.key, fetchFunc(tt.age))
+		require.NoError(t, err)
+
+		result, err := cache.Get(ctx, tt.key, fetchFunc(0))
+		require.NoError(t, err)
+		assert.Equal(t, tt.wantType, result.Type, tt.key)
+		assert.GreaterOrEqual(t, result.Age, tt.age, tt.key)
+		assert.Less(t, result.Age, tt.age+time.Second, tt.key)
+	}
+}
+
+func TestCache_FetchResultTTL(t *testing.T) {
+	t.Parallel()
+
+	backend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+
+	const entryTTL = 10 * time.Minute
+	clock := clocktest.New(time.Now())
+	cache, err := smartcache.New[string](
+		backend,
+		smartcache.WithTTL(time.Minute, time.Hour),
+		smartcache.WithClock(clock),
+	)
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	var fetches atomic.Int32
+	fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		fetches.Add(1)
+		data := "data"
+		return &smartcache.FetchResult[string]{Data: &data, TTL: entryTTL}, nil
+	}
+
+	ctx := context.Background()
+	result, err := cache.Get(ctx, "key", fetchFunc)
+	require.NoError(t, err)
+	assert.Equal(t, smartcache.Miss, result.Type)
+
+	entry, err := backend.Get(ctx, "key")
+	require.NoError(t, err)
+	require.NotNil(t, entry.FixedExpiration)
+	assert.Equal(t, clock.Now().Add(entryTTL), *entry.FixedExpiration)
+
+	// The entry is hot until it expires, even past the primary TTL.
+	clock.Add(entryTTL - time.Second)
+	result, err = cache.Get(ctx, "key", fetchFunc)
+	require.NoError(t, err)
+	assert.Equal(t, smartcache.HotHit, result.Type)
+
+	// It expires long before the secondary TTL, and is fetched in the foreground again.
+	clock.Add(2 * time.Second)
+	result, err = cache.Get(ctx, "key", fetchFunc)
+	require.NoError(t, err)
+	assert.Equal(t, smartcache.Miss, result.Type)
+	assert.EqualValues(t, 2, fetches.Load())
+}
+
+func TestCache_FetchResultInvalidTTL(t *testing.T) {
+	t.Parallel()
+
+	const primTTL = time.Minute
+	for name, result := range map[string]smartcache.FetchResult[string]{
+		"negative": {TTL: -time.Minute},
+		"expired":  {TTL: time.Minute, CreatedAt: time.Now().Add(-2 * time.Minute)},
+	} {
+		result := result
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			backend, err := lru.NewBackend[string](100)
+			require.NoError(t, err)
+
+			var logs logRecorder
+			clock := clocktest.New(time.Now())
+			cache, err := smartcache.New[string](
+				backend,
+				smartcache.WithTTL(primTTL, time.Hour),
+				smartcache.WithClock(clock),
+				smartcache.WithLogger(&logs),
+			)
+			require.NoError(t, err)
+			t.Cleanup(cache.Close)
+
+			var fetches atomic.Int32
+			fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+				fetches.Add(1)
+				data := "data"
+				result.Data = &data
+				return &result, nil
+			}
+
+			ctx := context.Background()
+			_, err = cache.Get(ctx, "key", fetchFunc)
+			require.NoError(t, err)
+			require.Len(t, logs.Lines(), 1)
+			assert.Contains(t, logs.Lines()[0], "key 'key': fetch result TTL")
+
+			// The entry isn't a Miss right away, the cache TTLs apply.
+			entry, err := backend.Get(ctx, "key")
+			require.NoError(t, err)
+			assert.Nil(t, entry.FixedExpiration)
+
+			clock.Add(time.Second)
+			got, err := cache.Get(ctx, "key", fetchFunc)
+			require.NoError(t, err)
+			assert.NotEqual(t, smartcache.Miss, got.Type)
+			assert.EqualValues(t, 1, fetches.Load())
+		})
+	}
+}
+
+func TestCache_WithClock(t *testing.T) {
+	t.Parallel()
+
+	backend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+
+	_, err = smartcache.New[string](backend, smartcache.WithClock(nil))
+	require.Error(t, err)
+
+	const primTTL = time.Minute
+	const secTTL = time.Hour
+	clock := clocktest.New(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	cache, err := smartcache.New[string](
+		backend,
+		smartcache.WithTTL(primTTL, secTTL),
+		smartcache.WithClock(clock),
+	)
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	var fetches atomic.Int32
+	fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		fetches.Add(1)
+		data := "data"
+		return &smartcache.FetchResult[string]{Data: &data}, nil
+	}
+
+	ctx := context.Background()
+	result, err := cache.Lookup(ctx, "key")
+	assert.ErrorIs(t, err, smartcache.ErrCacheMiss)
+	assert.Equal(t, smartcache.Miss, result.Type)
+
+	_, err = cache.Get(ctx, "key", fetchFunc)
+	require.NoError(t, err)
+	result, err = cache.Lookup(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, smartcache.HotHit, result.Type)
+	assert.Equal(t, clock.Now(), result.CreatedAt)
+	assert.Zero(t, result.Age)
+
+	clock.Add(primTTL + time.Second)
+	result, err = cache.Lookup(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, smartcache.WarmHit, result.Type)
+	assert.Equal(t, primTTL+time.Second, result.Age)
+
+	clock.Add(secTTL)
+	result, err = cache.Lookup(ctx, "key")
+	assert.ErrorIs(t, err, smartcache.ErrCacheMiss)
+	assert.Equal(t, smartcache.Miss, result.Type)
+	assert.EqualValues(t, 1, fetches.Load())
+}
+
+func TestCache_FutureCreated(t *testing.T) {
+	t.Parallel()
+
+	backend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+
+	const primTTL = time.Minute
+	clock := clocktest.New(time.Now())
+	cache, err := smartcache.New[string](
+		backend,
+		smartcache.WithTTL(primTTL, time.Hour),
+		smartcache.WithClock(clock),
+	)
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	// The entry was written by an instance with the clock an hour ahead.
+	ctx := context.Background()
+	data := "data"
+	created := clock.Now().Add(time.Hour)
+	require.NoError(t, backend.Set(ctx, "key", time.Hour, &smartcache.CacheEntry[string]{Data: &data, Created: created}))
+
+	var fetches atomic.Int32
+	fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		fetches.Add(1)
+		return &smartcache.FetchResult[string]{Data: &data}, nil
+	}
+
+	// It's served as fresh, not with a negative age.
+	result, err := cache.Get(ctx, "key", fetchFunc)
+	require.NoError(t, err)
+	assert.Equal(t, smartcache.HotHit, result.Type)
+	assert.Zero(t, result.Age)
+	assert.Equal(t, clock.Now(), result.CreatedAt)
+
+	result, err = cache.Lookup(ctx, "key")
+	require.NoError(t, err)
+	assert.Zero(t, result.Age)
+
+	results, errs := cache.GetMulti(ctx, []string{"key"}, func(ctx context.Context, keys []string) (map[string]*smartcache.FetchResult[string], error) {
+		return nil, nil
+	})
+	assert.Empty(t, errs)
+	assert.Zero(t, results["key"].Age)
+
+	// Once the clock catches up, the entry ages as usual.
+	clock.Add(time.Hour + primTTL + time.Second)
+	result, err = cache.Get(ctx, "key", fetchFunc)
+	require.NoError(t, err)
+	assert.Equal(t, smartcache.WarmHit, result.Type)
+	assert.Equal(t, primTTL+time.Second, result.Age)
+	require.Eventually(t, func() bool { return fetches.Load() == 1 }, time.Second, time.Millisecond)
+}
+
+func TestCache_Lookup(t *testing.T) {
+	t.Parallel()
+
+	backend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+
+	const primTTL = time.Minute
+	const secTTL = time.Hour
+	cache, err := smartcache.New[string](backend, smartcache.WithTTL(primTTL, secTTL))
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	ctx := context.Background()
+	data := "data"
+	set := func(key string, age time.Duration) {
+		require.NoError(t, backend.Set(ctx, key, secTTL, &smartcache.CacheEntry[string]{
+			Data:    &data,
+			Created: time.Now().Add(-age),
+		}))
+	}
+	set("hot", 0)
+	set("warm", primTTL+time.Minute)
+	set("expired", secTTL+time.Minute)
+
+	tests := []struct {
+		key      string
+		wantType smartcache.ResultType
+		wantErr  error
+	}{
+		{key: "hot", wantType: smartcache.HotHit},
+		{key: "warm", wantType: smartcache.WarmHit},
+		{key: "expired", wantType: smartcache.Miss, wantErr: smartcache.ErrCacheMiss},
+		{key: "missing", wantType: smartcache.Miss, wantErr: smartcache.ErrCacheMiss},
+	}
+	for _, tt := range tests {
+		result, err := cache.Lookup(ctx, tt.key)
+		if tt.wantErr != nil {
+			assert.ErrorIs(t, err, tt.wantErr, tt.key)
+			assert.Nil(t, result.Data, tt.key)
+		} else {
+			require.NoError(t, err, tt.key)
+			assert.Equal(t, data, *result.Data, tt.key)
+		}
+		assert.Equal(t, tt.wantType, result.Type, tt.key)
+	}
+
+	// Warm hits are not refreshed.
+	time.Sleep(50 * time.Millisecond)
+	result, err := cache.Lookup(ctx, "warm")
+	require.NoError(t, err)
+	assert.Equal(t, smartcache.WarmHit, result.Type)
+	assert.Zero(t, cache.Stats().Refreshes)
+}
+
+func TestCache_GetNoStale(t *testing.T) {
+	t.Parallel()
+
+	backend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+
+	const primTTL = time.Minute
+	clock := clocktest.New(time.Now())
+	cache, err := smartcache.New[string](
+		backend,
+		smartcache.WithTTL(primTTL, time.Hour),
+		smartcache.WithClock(clock),
+	)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	var fetches atomic.Int32
+	refreshStarted := make(chan struct{})
+	releaseRefresh := make(chan struct{})
+	fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		n := fetches.Add(1)
+		if n == 2 {
+			// The background refresh of the warm entry.
+			close(refreshStarted)
+			<-releaseRefresh
+		}
+		data := fmt.Sprintf("data-%d", n)
+		return &smartcache.FetchResult[string]{Data: &data}, nil
+	}
+	t.Cleanup(func() {
+		close(releaseRefresh)
+		cache.Close()
+	})
+
+	_, err = cache.Get(ctx, "key", fetchFunc)
+	require.NoError(t, err)
+
+	// Hot data is used as usual.
+	result, err := cache.Get(ctx, "key", fetchFunc, smartcache.NoStale())
+	require.NoError(t, err)
+	assert.Equal(t, smartcache.HotHit, result.Type)
+	assert.EqualValues(t, 1, fetches.Load())
+
+	// A normal call gets the warm data, and starts a refresh in the background.
+	clock.Add(primTTL + time.Second)
+	result, err = cache.Get(ctx, "key", fetchFunc)
+	require.NoError(t, err)
+	assert.Equal(t, smartcache.WarmHit, result.Type)
+	assert.Equal(t, "data-1", *result.Data)
+	<-refreshStarted
+
+	// While the refresh is in progress, a call without stale data fetches the data synchronously.
+	result, err = cache.Get(ctx, "key", fetchFunc, smartcache.NoStale())
+	require.NoError(t, err)
+	assert.Equal(t, smartcache.Miss, result.Type)
+	assert.Equal(t, "data-3", *result.Data)
+
+	result, err = cache.Get(ctx, "key", fetchFunc)
+	require.NoError(t, err)
+	assert.Equal(t, smartcache.HotHit, result.Type)
+	assert.Equal(t, "data-3", *result.Data)
+}
+
+func TestCache_Purge(t *testing.T) {
+	t.Parallel()
+
+	backend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+
+	const primTTL = time.Minute
+	clock := clocktest.New(time.Now())
+	cache, err := smartcache.New[string](
+		backend,
+		smartcache.WithTTL(primTTL, time.Hour),
+		smartcache.WithClock(clock),
+	)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	var fetches atomic.Int32
+	refreshStarted := make(chan struct{})
+	releaseRefresh := make(chan struct{})
+	fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		n := fetches.Add(1)
+		if key == "warm" && n > 2 {
+			// The background refresh of the warm key.
+			close(refreshStarted)
+			<-releaseRefresh
+		}
+		data := fmt.Sprintf("data-%d", n)
+		return &smartcache.FetchResult[string]{Data: &data}, nil
+	}
+	t.Cleanup(cache.Close)
+
+	_, err = cache.Get(ctx, "hot", fetchFunc)
+	require.NoError(t, err)
+	_, err = cache.Get(ctx, "warm", fetchFunc)
+	require.NoError(t, err)
+
+	// Start a refresh, which finishes after the purge.
+	clock.Add(primTTL + time.Second)
+	result, err := cache.Get(ctx, "warm", fetchFunc)
+	require.NoError(t, err)
+	assert.Equal(t, smartcache.WarmHit, result.Type)
+	<-refreshStarted
+
+	require.NoError(t, cache.Purge(ctx))
+	close(releaseRefresh)
+	require.Eventually(t, func() bool { return cache.Stats().Refreshes == 1 }, time.Second, time.Millisecond)
+
+	for _, key := range []string{"hot", "warm"} {
+		_, err := cache.Lookup(ctx, key)
+		assert.ErrorIs(t, err, smartcache.ErrCacheMiss, key)
+	}
+	result, err = cache.Get(ctx, "hot", fetchFunc)
+	require.NoError(t, err)
+	assert.Equal(t, smartcache.Miss, result.Type)
+
+	t.Run("not supported", func(t *testing.T) {
+		inner, err := lru.NewBackend[string](100)
+		require.NoError(t, err)
+		// Embedding the interface hides the Purge method of the lru backend.
+		cache, err := smartcache.New[string](struct{ smartcache.Backend[string] }{inner})
+		require.NoError(t, err)
+		t.Cleanup(cache.Close)
+
+		assert.ErrorIs(t, cache.Purge(ctx), smartcache.ErrNotSupported)
+	})
+}
+
+func TestCache_Utilization(t *testing.T) {
+	t.Parallel()
+
+	backend, err := lru.NewBackend[string](10)
+	require.NoError(t, err)
+	cache, err := smartcache.New[string](backend)
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	ctx := context.Background()
+	utilization, err := cache.Utilization(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, utilization)
+
+	data := "data"
+	for i := 0; i < 4; i++ {
+		require.NoError(t, cache.Set(ctx, fmt.Sprintf("key%d", i), &data))
+	}
+	utilization, err = cache.Utilization(ctx)
+	require.NoError(t, err)
+	assert.InDelta(t, 0.4, utilization, 1e-9)
+
+	// Evicted entries don't count above the capacity.
+	for i := 0; i < 20; i++ {
+		require.NoError(t, cache.Set(ctx, fmt.Sprintf("other%d", i), &data))
+	}
+	utilization, err = cache.Utilization(ctx)
+	require.NoError(t, err)
+	assert.InDelta(t, 1.0, utilization, 1e-9)
+
+	t.Run("not supported", func(t *testing.T) {
+		weighted, err := lru.NewWeightedBackend[string](10, func(entry *smartcache.CacheEntry[string]) int { return 1 })
+		require.NoError(t, err)
+		cache, err := smartcache.New[string](weighted)
+		require.NoError(t, err)
+		t.Cleanup(cache.Close)
+
+		_, err = cache.Utilization(ctx)
+		assert.ErrorIs(t, err, smartcache.ErrNotSupported)
+	})
+}
+
+func TestCache_ResizeBackend(t *testing.T) {
+	t.Parallel()
+
+	backend, err := lru.NewBackend[string](4)
+	require.NoError(t, err)
+	cache, err := smartcache.New[string](backend)
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	ctx := context.Background()
+	data := "data"
+	for i := 0; i < 4; i++ {
+		require.NoError(t, cache.Set(ctx, fmt.Sprintf("key%d", i), &data))
+	}
+
+	_, err = cache.ResizeBackend(0)
+	assert.Error(t, err)
+
+	evicted, err := cache.ResizeBackend(8)
+	require.NoError(t, err)
+	assert.Zero(t, evicted)
+	utilization, err := cache.Utilization(ctx)
+	require.NoError(t, err)
+	assert.InDelta(t, 0.5, utilization, 1e-9)
+
+	evicted, err = cache.ResizeBackend(1)
+	require.NoError(t, err)
+	assert.Equal(t, 3, evicted)
+	result, err := cache.Lookup(ctx, "key3")
+	require.NoError(t, err)
+	assert.Equal(t, data, *result.Data)
+	_, err = cache.Lookup(ctx, "key0")
+	assert.ErrorIs(t, err, smartcache.ErrCacheMiss)
+
+	t.Run("not supported", func(t *testing.T) {
+		freshness, err := lru.NewFreshnessBackend[string](10, time.Minute)
+		require.NoError(t, err)
+		cache, err := smartcache.New[string](freshness)
+		require.NoError(t, err)
+		t.Cleanup(cache.Close)
+
+		_, err = cache.ResizeBackend(20)
+		assert.ErrorIs(t, err, smartcache.ErrNotSupported)
+	})
+}
+
+// closeBlockingBackend blocks in Close until released.
+type closeBlockingBackend struct {
+	*lru.Backend[string]
+	closes  atomic.Int32
+	release chan struct{}
+}
+
+func (b *closeBlockingBackend) Close() {
+	b.closes.Add(1)
+	<-b.release
+}
+
+func TestCache_Close(t *testing.T) {
+	t.Parallel()
+
+	newBackend := func(t *testing.T) *closeBlockingBackend {
+		inner, err := lru.NewBackend[string](100)
+		require.NoError(t, err)
+
+		return &closeBlockingBackend{Backend: inner, release: make(chan struct{})}
+	}
+
+	t.Run("closes the backend once", func(t *testing.T) {
+		t.Parallel()
+
+		backend := newBackend(t)
+		close(backend.release)
+		cache, err := smartcache.New[string](backend)
+		require.NoError(t, err)
+
+		cache.Close()
+		assert.EqualValues(t, 1, backend.closes.Load())
+		assert.NoError(t, cache.CloseWithTimeout(time.Second))
+		assert.EqualValues(t, 1, backend.closes.Load())
+	})
+
+	t.Run("timeout on a blocked backend", func(t *testing.T) {
+		t.Parallel()
+
+		backend := newBackend(t)
+		t.Cleanup(func() { close(backend.release) })
+		cache, err := smartcache.New[string](backend)
+		require.NoError(t, err)
+
+		const timeout = 50 * time.Millisecond
+		start := time.Now()
+		err = cache.CloseWithTimeout(timeout)
+		assert.ErrorIs(t, err, smartcache.ErrCloseTimeout)
+		assert.Less(t, time.Since(start), timeout+time.Second)
+		assert.EqualValues(t, 1, backend.closes.Load())
+
+		// The cache is closed anyway.
+		_, err = cache.Get(context.Background(), "key", func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+			return &smartcache.FetchResult[string]{}, nil
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("timeout on in-flight calls", func(t *testing.T) {
+		t.Parallel()
+
+		backend := newBackend(t)
+		close(backend.release)
+		cache, err := smartcache.New[string](backend)
+		require.NoError(t, err)
+
+		fetchStarted := make(chan struct{})
+		releaseFetch := make(chan struct{})
+		fetchDone := make(chan struct{})
+		go func() {
+			defer close(fetchDone)
+			_, _ = cache.Get(context.Background(), "key", func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+				close(fetchStarted)
+				<-releaseFetch
+				return &smartcache.FetchResult[string]{}, nil
+			})
+		}()
+		<-fetchStarted
+
+		assert.ErrorIs(t, cache.CloseWithTimeout(10*time.Millisecond), smartcache.ErrCloseTimeout)
+		assert.Zero(t, backend.closes.Load())
+
+		// The backend is closed once the call finishes.
+		close(releaseFetch)
+		<-fetchDone
+		assert.Eventually(t, func() bool { return backend.closes.Load() == 1 }, time.Second, time.Millisecond)
+	})
+}
+
+func TestCache_Peek(t *testing.T) {
+	t.Parallel()
+
+	backend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+
+	const primTTL = time.Minute
+	const secTTL = time.Hour
+	cache, err := smartcache.New[string](backend, smartcache.WithTTL(primTTL, secTTL))
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	ctx := context.Background()
+	data := "data"
+	set := func(key string, age time.Duration) {
+		require.NoError(t, backend.Set(ctx, key, secTTL, &smartcache.CacheEntry[string]{
+			Data:    &data,
+			Created: time.Now().Add(-age),
+		}))
+	}
+	set("hot", 0)
+	set("warm", primTTL+time.Minute)
+	set("expired", secTTL+time.Minute)
+	fetchErr := errors.New("fetch failed")
+	expiration := time.Now().Add(time.Minute)
+	require.NoError(t, backend.Set(ctx, "error", secTTL, &smartcache.CacheEntry[string]{
+		Err:             fetchErr,
+		FixedExpiration: &expiration,
+	}))
+
+	tests := []struct {
+		key       string
+		wantFound bool
+		wantType  smartcache.ResultType
+	}{
+		{key: "hot", wantFound: true, wantType: smartcache.HotHit},
+		{key: "warm", wantFound: true, wantType: smartcache.WarmHit},
+		{key: "expired", wantType: smartcache.Miss},
+		{key: "missing", wantType: smartcache.Miss},
+	}
+	for _, tt := range tests {
+		result, found, err := cache.Peek(ctx, tt.key)
+		require.NoError(t, err, tt.key)
+		assert.Equal(t, tt.wantFound, found, tt.key)
+		assert.Equal(t, tt.wantType, result.Type, tt.key)
+		if tt.wantFound {
+			assert.Equal(t, data, *result.Data, tt.key)
+		} else {
+			assert.Nil(t, result.Data, tt.key)
+		}
+	}
+
+	result, found, err := cache.Peek(ctx, "error")
+	assert.ErrorIs(t, err, fetchErr)
+	assert.True(t, found)
+	assert.Equal(t, smartcache.HotHit, result.Type)
+
+	// Warm hits are not refreshed.
+	time.Sleep(50 * time.Millisecond)
+	assert.Zero(t, cache.Stats().Refreshes)
+}
+
+func TestCache_HealthSource(t *testing.T) {
+	t.Parallel()
+
+	backend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+
+	_, err = smartcache.New[string](backend, smartcache.WithHealthSource(nil, 2))
+	require.Error(t, err)
+	_, err = smartcache.New[string](backend, smartcache.WithHealthSource(func() smartcache.Health { return smartcache.Healthy }, 0.5))
+	require.Error(t, err)
+
+	var degraded atomic.Bool
+	health := func() smartcache.Health {
+		if degraded.Load() {
+			return smartcache.Degraded
+		}
+		return smartcache.Healthy
+	}
+
+	const primTTL = time.Minute
+	cache, err := smartcache.New[string](
+		backend,
+		smartcache.WithTTL(primTTL, 2*primTTL),
+		smartcache.WithHealthSource(health, 4),
+	)
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	ctx := context.Background()
+	data := "data"
+	set := func(key string, age time.Duration) {
+		require.NoError(t, backend.Set(ctx, key, time.Hour, &smartcache.CacheEntry[string]{
+			Data:    &data,
+			Created: time.Now().Add(-age),
+		}))
+	}
+	fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		return nil, errors.New("upstream unavailable")
+	}
+
+	tests := []struct {
+		name     string
+		age      time.Duration
+		degraded bool
+		want     smartcache.ResultType
+	}{
+		{name: "healthy, past primary TTL", age: 90 * time.Second, want: smartcache.WarmHit},
+		{name: "degraded, past primary TTL", age: 90 * time.Second, degraded: true, want: smartcache.HotHit},
+		{name: "degraded, past secondary TTL", age: 5 * time.Minute, degraded: true, want: smartcache.WarmHit},
+		{name: "degraded, past extended secondary TTL", age: 9 * time.Minute, degraded: true, want: smartcache.Miss},
+	}
+	for i, tt := range tests {
+		key := fmt.Sprintf("key%d", i)
+		set(key, tt.age)
+		degraded.Store(tt.degraded)
+
+		result, _ := cache.Get(ctx, key, fetchFunc)
+		assert.Equal(t, tt.want, result.Type, tt.name)
+	}
+
+	// Healthy again, entries past the secondary TTL are misses.
+	degraded.Store(false)
+	set("key", 3*time.Minute)
+	result, err := cache.Get(ctx, "key", fetchFunc)
+	assert.Error(t, err)
+	assert.Equal(t, smartcache.Miss, result.Type)
+}
+
+func TestCache_BloomFilter(t *testing.T) {
+	t.Parallel()
+
+	backend := newCountingBackend(t)
+	for _, opt := range []smartcache.Option{
+		smartcache.WithBloomFilter(0, 0.01),
+		smartcache.WithBloomFilter(100, 0),
+		smartcache.WithBloomFilter(100, 1),
+	} {
+		_, err := smartcache.New[string](backend, opt)
+		require.Error(t, err)
+	}
+
+	cache, err := smartcache.New[string](backend, smartcache.WithBloomFilter(1000, 0.01))
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	var fetches atomic.Int32
+	fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		fetches.Add(1)
+		data := "data " + key
+		return &smartcache.FetchResult[string]{Data: &data}, nil
+	}
+
+	// Keys never stored are fetched without reading the backend.
+	ctx := context.Background()
+	result, err := cache.Get(ctx, "fetched", fetchFunc)
+	require.NoError(t, err)
+	assert.Equal(t, smartcache.Miss, result.Type)
+	_, err = cache.Lookup(ctx, "missing")
+	assert.ErrorIs(t, err, smartcache.ErrCacheMiss)
+	assert.Zero(t, backend.gets.Load())
+
+	// Stored keys are always read from the backend.
+	data := "set"
+	require.NoError(t, cache.Set(ctx, "set", &data))
+	for _, key := range []string{"fetched", "set"} {
+		result, err := cache.Get(ctx, key, fetchFunc)
+		require.NoError(t, err)
+		assert.Equal(t, smartcache.HotHit, result.Type, key)
+	}
+	assert.EqualValues(t, 2, backend.gets.Load())
+	assert.EqualValues(t, 1, fetches.Load())
+
+	// Multi gets read only keys that may be stored.
+	results, errs := cache.GetMulti(ctx, []string{"set", "other"}, func(ctx context.Context, keys []string) (map[string]*smartcache.FetchResult[string], error) {
+		assert.Equal(t, []string{"other"}, keys)
+		data := "other"
+		return map[string]*smartcache.FetchResult[string]{"other": {Data: &data}}, nil
+	})
+	assert.Empty(t, errs)
+	assert.Equal(t, smartcache.HotHit, results["set"].Type)
+	assert.Equal(t, smartcache.Miss, results["other"].Type)
+}
+
+// ttlRecordingBackend records TTLs of stored entries.
+type ttlRecordingBackend struct {
+	*lru.Backend[string]
+	mu   sync.Mutex
+	ttls map[string]time.Duration
+}
+
+func (b *ttlRecordingBackend) Set(ctx context.Context, key string, ttl time.Duration, entry *smartcache.CacheEntry[string]) error {
+	b.mu.Lock()
+	b.ttls[key] = ttl
+	b.mu.Unlock()
+
+	return b.Backend.Set(ctx, key, ttl, entry)
+}
+
+func TestCache_TTLJitter(t *testing.T) {
+	t.Parallel()
+
+	inner, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+	backend := &ttlRecordingBackend{Backend: inner, ttls: make(map[string]time.Duration)}
+
+	for _, fraction := range []float64{-0.1, 1} {
+		_, err = smartcache.New[string](backend, smartcache.WithTTLJitter(fraction))
+		require.Error(t, err, fraction)
+	}
+
+	const primTTL = time.Minute
+	const secTTL = time.Hour
+	const fraction = 0.2
+	cache, err := smartcache.New[string](
+		backend,
+		smartcache.WithTTL(primTTL, secTTL),
+		smartcache.WithTTLJitter(fraction),
+	)
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	createdAt := time.Now()
+	fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		data := "data"
+		return &smartcache.FetchResult[string]{Data: &data, CreatedAt: createdAt}, nil
+	}
+
+	ctx := context.Background()
+	const keys = 20
+	for i := 0; i < keys; i++ {
+		_, err := cache.Get(ctx, fmt.Sprintf("key%d", i), fetchFunc)
+		require.NoError(t, err)
+	}
+
+	// Entries created together expire at different times.
+	distinct := make(map[time.Duration]struct{})
+	for _, ttl := range backend.ttls {
+		assert.GreaterOrEqual(t, ttl, time.Duration(float64(secTTL)*(1-fraction)))
+		assert.LessOrEqual(t, ttl, time.Duration(float64(secTTL)*(1+fraction)))
+		distinct[ttl] = struct{}{}
+	}
+	assert.Len(t, backend.ttls, keys)
+	assert.Greater(t, len(distinct), keys/2)
+
+	// The jitter is derived from the key and the creation time, so it's the same for every reader.
+	first := make(map[string]time.Duration, len(backend.ttls))
+	for key, ttl := range backend.ttls {
+		first[key] = ttl
+		require.NoError(t, cache.Delete(ctx, key))
+		_, err := cache.Get(ctx, key, fetchFunc)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, first, backend.ttls)
+}
+
+// deleteCountingBackend counts Delete calls.
+type deleteCountingBackend struct {
+	*lru.Backend[string]
+	deletes atomic.Int32
+}
+
+func (b *deleteCountingBackend) Delete(ctx context.Context, key string) error {
+	defer b.deletes.Add(1)
+	return b.Backend.Delete(ctx, key)
+}
+
+func TestCache_Delete(t *testing.T) {
+	t.Parallel()
+
+	lruBackend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+	backend := &deleteCountingBackend{Backend: lruBackend}
+
+	const primTTL = 50 * time.Millisecond
+	cache, err := smartcache.New[string](
+		backend,
+		smartcache.WithTTL(primTTL, time.Hour),
+		smartcache.WithResilienceMirror(10),
+	)
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	var refreshing atomic.Bool
+	refreshStarted := make(chan struct{})
+	releaseRefresh := make(chan struct{})
+	var fetches atomic.Int32
+	fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		if refreshing.CompareAndSwap(true, false) {
+			close(refreshStarted)
+			<-releaseRefresh
+		}
+		data := fmt.Sprintf("data-%d", fetches.Add(1))
+		return &smartcache.FetchResult[string]{Data: &data}, nil
+	}
+
+	ctx := context.Background()
+
+	_, err = cache.Get(ctx, "key", fetchFunc)
+	require.NoError(t, err)
+
+	require.NoError(t, cache.Delete(ctx, "key"))
+	entry, err := backend.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.Nil(t, entry)
+
+	result, err := cache.Get(ctx, "key", fetchFunc)
+	require.NoError(t, err)
+	assert.Equal(t, smartcache.Miss, result.Type)
+
+	// A refresh in progress doesn't resurrect the deleted entry.
+	time.Sleep(primTTL + 10*time.Millisecond)
+	refreshing.Store(true)
+	result, err = cache.Get(ctx, "key", fetchFunc)
+	require.NoError(t, err)
+	require.Equal(t, smartcache.WarmHit, result.Type)
+	<-refreshStarted
+
+	require.NoError(t, cache.Delete(ctx, "key"))
+	close(releaseRefresh)
+
+	// The refresh doesn't store the refreshed data at all.
+	require.Eventually(t, func() bool { return cache.Stats().Refreshes == 1 }, time.Second, 5*time.Millisecond)
+	entry, err = backend.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.Nil(t, entry)
+	assert.EqualValues(t, 2, backend.deletes.Load())
+
+	result, err = cache.Get(ctx, "key", fetchFunc)
+	require.NoError(t, err)
+	assert.Equal(t, smartcache.Miss, result.Type)
+}
+
+func TestCache_Fallback(t *testing.T) {
+	t.Parallel()
+
+	backend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+
+	fallback := func(key string) *string {
+		data := "default " + key
+		return &data
+	}
+
+	_, err = smartcache.New[string](backend, smartcache.WithFallback[string](nil))
+	require.Error(t, err)
+	_, err = smartcache.New[string](backend, smartcache.WithFallback(func(key string) *int { return nil }))
+	require.Error(t, err)
+
+	newCache := func(t *testing.T, options ...smartcache.Option) (*smartcache.Cache[string], *lru.Backend[string]) {
+		backend, err := lru.NewBackend[string](100)
+		require.NoError(t, err)
+		cache, err := smartcache.New[string](backend, options...)
+		require.NoError(t, err)
+		t.Cleanup(cache.Close)
+
+		return cache, backend
+	}
+	ctx := context.Background()
+	fetchErr := errors.New("fetch failed")
+	var healthy atomic.Bool
+	fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		if !healthy.Load() {
+			return nil, fetchErr
+		}
+		data := "data"
+		return &smartcache.FetchResult[string]{Data: &data}, nil
+	}
+
+	t.Run("cold cache", func(t *testing.T) {
+		cache, _ := newCache(t, smartcache.WithFallback(fallback))
+
+		result, err := cache.Get(ctx, "key", fetchFunc)
+		assert.ErrorIs(t, err, fetchErr)
+		assert.Equal(t, smartcache.FallbackHit, result.Type)
+		require.NotNil(t, result.Data)
+		assert.Equal(t, "default key", *result.Data)
+		assert.EqualValues(t, 1, cache.Stats().FallbackHits)
+
+		// The fallback is not cached.
+		_, err = cache.Lookup(ctx, "key")
+		assert.ErrorIs(t, err, smartcache.ErrCacheMiss)
+	})
+
+	t.Run("cached error", func(t *testing.T) {
+		cache, _ := newCache(
+			t,
+			smartcache.WithFallback(fallback),
+			smartcache.WithErrorTTLFunc(func(err error) time.Duration { return time.Minute }),
+		)
+
+		result, err := cache.Get(ctx, "key", fetchFunc)
+		assert.ErrorIs(t, err, fetchErr)
+		assert.Equal(t, smartcache.Miss, result.Type)
+		assert.Nil(t, result.Data)
+	})
+
+	t.Run("stale data", func(t *testing.T) {
+		clock := clocktest.New(time.Now())
+		cache, _ := newCache(
+			t,
+			smartcache.WithFallback(fallback),
+			smartcache.WithTTL(time.Minute, time.Hour),
+			smartcache.WithClock(clock),
+			smartcache.WithServeExpiredOnError(),
+		)
+
+		data := "data"
+		require.NoError(t, cache.Set(ctx, "key", &data))
+		clock.Add(2 * time.Hour)
+
+		result, err := cache.Get(ctx, "key", fetchFunc)
+		assert.ErrorIs(t, err, fetchErr)
+		assert.Equal(t, smartcache.StaleHit, result.Type)
+		require.NotNil(t, result.Data)
+		assert.Equal(t, "data", *result.Data)
+	})
+
+	t.Run("successful fetch", func(t *testing.T) {
+		cache, _ := newCache(t, smartcache.WithFallback(fallback))
+		healthy.Store(true)
+		defer healthy.Store(false)
+
+		result, err := cache.Get(ctx, "other", fetchFunc)
+		require.NoError(t, err)
+		assert.Equal(t, smartcache.Miss, result.Type)
+		assert.Equal(t, "data", *result.Data)
+	})
+}
+
+func TestCache_CoordinationKey(t *testing.T) {
+	t.Parallel()
+
+	newCache := func(t *testing.T, options ...smartcache.Option) *smartcache.Cache[string] {
+		backend, err := lru.NewBackend[string](100)
+		require.NoError(t, err)
+		cache, err := smartcache.New[string](backend, options...)
+		require.NoError(t, err)
+		t.Cleanup(cache.Close)
+
+		return cache
+	}
+	ctx := context.Background()
+
+	t.Run("coarser than storage", func(t *testing.T) {
+		cache := newCache(t)
+
+		var fetches sync.Map
+		started := make(chan string, 2)
+		release := make(chan struct{})
+		fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+			fetches.Store(key, true)
+			started <- key
+			<-release
+			data := "data " + key
+			return &smartcache.FetchResult[string]{Data: &data}, nil
+		}
+
+		results := make(chan smartcache.Result[string], 2)
+		get := func(key string) {
+			result, err := cache.Get(ctx, key, fetchFunc, smartcache.CoordinationKey("tenant"))
+			assert.NoError(t, err)
+			results <- result
+		}
+
+		go get("tenant:a")
+		assert.Equal(t, "tenant:a", <-started)
+
+		// The other key of the tenant waits for the running fetch.
+		go get("tenant:b")
+		assert.Never(t, func() bool {
+			_, found := fetches.Load("tenant:b")
+			return found
+		}, 50*time.Millisecond, time.Millisecond)
+
+		close(release)
+		assert.Equal(t, "tenant:b", <-started)
+		for i := 0; i < 2; i++ {
+			result := <-results
+			assert.Equal(t, smartcache.Miss, result.Type)
+		}
+
+		// Data is stored under the storage keys.
+		for _, key := range []string{"tenant:a", "tenant:b"} {
+			result, err := cache.Lookup(ctx, key)
+			require.NoError(t, err)
+			assert.Equal(t, "data "+key, *result.Data)
+		}
+		_, err := cache.Lookup(ctx, "tenant")
+		assert.ErrorIs(t, err, smartcache.ErrCacheMiss)
+	})
+
+	t.Run("finer than storage", func(t *testing.T) {
+		cache := newCache(t)
+
+		var fetches atomic.Int32
+		started := make(chan struct{}, 2)
+		release := make(chan struct{})
+		fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+			fetches.Add(1)
+			started <- struct{}{}
+			<-release
+			data := "data"
+			return &smartcache.FetchResult[string]{Data: &data}, nil
+		}
+
+		var wg sync.WaitGroup
+		for _, variant := range []string{"key#1", "key#2"} {
+			variant := variant
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				result, err := cache.Get(ctx, "key", fetchFunc, smartcache.CoordinationKey(variant))
+				assert.NoError(t, err)
+				assert.Equal(t, smartcache.Miss, result.Type)
+			}()
+		}
+
+		// Both variants fetch the key concurrently.
+		<-started
+		<-started
+		close(release)
+		wg.Wait()
+		assert.EqualValues(t, 2, fetches.Load())
+
+		result, err := cache.Lookup(ctx, "key")
+		require.NoError(t, err)
+		assert.Equal(t, "data", *result.Data)
+	})
+
+	t.Run("warm hit", func(t *testing.T) {
+		const primTTL = time.Minute
+		clock := clocktest.New(time.Now())
+		cache := newCache(t, smartcache.WithTTL(primTTL, time.Hour), smartcache.WithClock(clock))
+
+		old := "old"
+		require.NoError(t, cache.Set(ctx, "key", &old))
+		clock.Add(primTTL + time.Second)
+
+		fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+			data := "new"
+			return &smartcache.FetchResult[string]{Data: &data}, nil
+		}
+		result, err := cache.Get(ctx, "key", fetchFunc, smartcache.CoordinationKey("group"))
+		require.NoError(t, err)
+		assert.Equal(t, smartcache.WarmHit, result.Type)
+		assert.Equal(t, "old", *result.Data)
+
+		// The refresh stores the data under the storage key.
+		require.Eventually(t, func() bool {
+			result, err := cache.Lookup(ctx, "key")
+			return err == nil && result.Type == smartcache.HotHit && *result.Data == "new"
+		}, time.Second, time.Millisecond)
+	})
+}
+
+func TestCache_Update(t *testing.T) {
+	t.Parallel()
+
+	const primTTL = time.Minute
+	ctx := context.Background()
+	newCache := func(t *testing.T, options ...smartcache.Option) (*smartcache.Cache[string], *clocktest.Clock) {
+		clock := clocktest.New(time.Now())
+		backend, err := lru.NewBackend[string](100)
+		require.NoError(t, err)
+		cache, err := smartcache.New[string](
+			backend,
+			append([]smartcache.Option{smartcache.WithTTL(primTTL, time.Hour), smartcache.WithClock(clock)}, options...)...,
+		)
+		require.NoError(t, err)
+
+		return cache, clock
+	}
+
+	t.Run("stores fresh data", func(t *testing.T) {
+		cache, clock := newCache(t)
+		t.Cleanup(cache.Close)
+
+		data := "data"
+		result, err := cache.Update(ctx, "key", &data)
+		require.NoError(t, err)
+		assert.Equal(t, smartcache.HotHit, result.Type)
+		assert.Equal(t, "data", *result.Data)
+		assert.Equal(t, clock.Now(), result.CreatedAt)
+
+		result, err = cache.Lookup(ctx, "key")
+		require.NoError(t, err)
+		assert.Equal(t, smartcache.HotHit, result.Type)
+		assert.Equal(t, "data", *result.Data)
+	})
+
+	t.Run("wins over a running refresh", func(t *testing.T) {
+		cache, clock := newCache(t)
+		refreshStarted := make(chan struct{})
+		releaseRefresh := make(chan struct{})
+		t.Cleanup(cache.Close)
+
+		old := "old"
+		require.NoError(t, cache.Set(ctx, "key", &old))
+		clock.Add(primTTL + time.Second)
+
+		fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+			close(refreshStarted)
+			<-releaseRefresh
+			data := "refreshed"
+			return &smartcache.FetchResult[string]{Data: &data}, nil
+		}
+		result, err := cache.Get(ctx, "key", fetchFunc)
+		require.NoError(t, err)
+		assert.Equal(t, smartcache.WarmHit, result.Type)
+		<-refreshStarted
+
+		updated := "updated"
+		_, err = cache.Update(ctx, "key", &updated)
+		require.NoError(t, err)
+
+		close(releaseRefresh)
+		require.Eventually(t, func() bool {
+			return cache.Stats().Refreshes == 1
+		}, time.Second, time.Millisecond)
+
+		result, err = cache.Lookup(ctx, "key")
+		require.NoError(t, err)
+		assert.Equal(t, smartcache.HotHit, result.Type)
+		assert.Equal(t, "updated", *result.Data)
+	})
+
+	t.Run("cancels a queued refresh", func(t *testing.T) {
+		cache, clock := newCache(t, smartcache.WithRefreshWorkers(1))
+		releaseRefresh := make(chan struct{})
+		t.Cleanup(cache.Close)
+
+		old := "old"
+		require.NoError(t, cache.Set(ctx, "busy", &old))
+		require.NoError(t, cache.Set(ctx, "key", &old))
+		clock.Add(primTTL + time.Second)
+
+		// The only worker is busy refreshing another key, so the refresh of the key waits in the queue.
+		busyStarted := make(chan struct{})
+		_, err := cache.Get(ctx, "busy", func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+			close(busyStarted)
+			<-releaseRefresh
+			return &smartcache.FetchResult[string]{Data: &old}, nil
+		})
+		require.NoError(t, err)
+		<-busyStarted
+
+		var fetches atomic.Int32
+		_, err = cache.Get(ctx, "key", func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+			fetches.Add(1)
+			data := "refreshed"
+			return &smartcache.FetchResult[string]{Data: &data}, nil
+		})
+		require.NoError(t, err)
+
+		updated := "updated"
+		_, err = cache.Update(ctx, "key", &updated)
+		require.NoError(t, err)
+
+		close(releaseRefresh)
+		require.Eventually(t, func() bool {
+			return cache.Stats().Refreshes == 1
+		}, time.Second, time.Millisecond)
+		assert.Never(t, func() bool {
+			return fetches.Load() > 0
+		}, 50*time.Millisecond, time.Millisecond)
+		result, err := cache.Lookup(ctx, "key")
+		require.NoError(t, err)
+		assert.Equal(t, "updated", *result.Data)
+	})
+}
@@ -0,0 +1,20 @@
+package smartcache
+
+import "time"
+
+// Clock provides the current time for the TTL logic of the cache. See `WithClock`.
+type Clock interface {
+	Now() time.Time
+}
+
+// realClock is the system clock, used by default.
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// now returns the current time of the cache's clock.
+func (sc *Cache[T]) now() time.Time {
+	return sc.config.clock.Now()
+}
@@ -0,0 +1,45 @@
+// Package clocktest provides a manual clock for testing code using smartcache without sleeping.
+package clocktest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/m-zajac/smartcache"
+)
+
+// Clock is a `smartcache.Clock`, which only moves when told to. It's safe for concurrent use.
+type Clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+var _ smartcache.Clock = &Clock{}
+
+// New creates a clock stopped at the given time.
+func New(now time.Time) *Clock {
+	return &Clock{now: now}
+}
+
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// Add moves the clock by the duration.
+func (c *Clock) Add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+// Set moves the clock to the given time.
+func (c *Clock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = now
+}
@@ -0,0 +1,23 @@
+package clocktest_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/m-zajac/smartcache/clocktest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClock(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := clocktest.New(start)
+	assert.Equal(t, start, clock.Now())
+
+	clock.Add(time.Minute)
+	assert.Equal(t, start.Add(time.Minute), clock.Now())
+
+	clock.Set(start)
+	assert.Equal(t, start, clock.Now())
+}
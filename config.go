@@ -2,6 +2,8 @@ package smartcache
 
 import (
 	"errors"
+	"fmt"
+	"io"
 	"time"
 )
 
@@ -10,9 +12,77 @@ type config struct {
 	secondaryTTL           time.Duration
 	backgroundFetchTimeout time.Duration
 	backgroundErrorHandler BackgroundErrorHandler
-	errorTTLFunc           ErrorTTLFunc
+	errorTTLFunc           KeyedErrorTTLFunc
+	freshnessTarget        time.Duration
+	batchWindow            time.Duration
+	accessTrace            *accessTrace
+	keyFreshnessFunc       KeyFreshnessFunc
+	serveExpiredOnError    bool
+	maxInFlightKeys        int
+	keyValidator           func(key string) error
+	resilienceMirrorSize   int
+	mutationDetection      bool
+	refreshWorkers         int
+	logger                 Logger
+	expectedKeys           int
+	shardCount             int
+	shardHash              func(key string) uint64
+	refreshErrors          bool
+	refreshProbe           any // RefreshProbeFunc[T] of the cache type.
+	startupRefreshDelay    time.Duration
+	fetchLimiter           *Limiter
+	errorPrimaryTTL        time.Duration
+	errorSecondaryTTL      time.Duration
+	lockPoolSize           int
+	refreshQueueSize       int
+	schemaVersion          string
+	fetchFuncCheck         bool
+	observer               Observer
+	tracer                 Tracer
+	healthSource           func() Health
+	degradedTTLFactor      float64
+	ttlJitter              float64
+	nonBlockingMiss        bool
+	clock                  Clock
+	bloomExpectedKeys      uint
+	bloomFPRate            float64
+	maxBackgroundRefreshes int
+	breakerThreshold       int
+	breakerCooldown        time.Duration
+	memoryThreshold        uint64
+	memoryPressureHandler  MemoryPressureHandler
+	heapUsage              func() uint64
+	heapSampleInterval     time.Duration
+	errorRefreshAfter      time.Duration
+	fallback               any // func(key string) *T of the cache type.
+	invalidationBus        InvalidationBus
 }
 
+// Logger is used for diagnostic logs. It's satisfied by `*log.Logger`.
+type Logger interface {
+	Printf(format string, v ...any)
+}
+
+// Observer receives metrics of cache operations, e.g. to export them to a monitoring system.
+// Its methods are called synchronously, so they should be fast and safe for concurrent use.
+type Observer interface {
+	// ObserveGet is called with the result type and the duration of every Get call that reached the backend.
+	ObserveGet(resultType ResultType, d time.Duration)
+	// ObserveFetch is called after every fetch function call. Background is set for fetches of background refreshes,
+	// and not set for fetches on misses, which the callers wait for.
+	ObserveFetch(d time.Duration, err error, background bool)
+	// ObserveBackgroundRefresh is called after every finished background refresh.
+	ObserveBackgroundRefresh(err error)
+}
+
+// Health is the health of the upstream data source, see `WithHealthSource`.
+type Health int
+
+const (
+	Healthy Health = iota
+	Degraded
+)
+
 // Options allows to configure cache settings.
 type Option func(*config) error
 
@@ -34,8 +104,20 @@ func WithTTL(primaryTTL, secondaryTTL time.Duration) Option {
 }
 
 // WithErrorTTLFunc allows caching errors. Cache expiry time is determined by the provided function.
-// If function returns 0 for an error, it won't be cached.
+// If function returns 0 for an error, it won't be cached. Errors of fetches cancelled by the caller are never cached.
 func WithErrorTTLFunc(f ErrorTTLFunc) Option {
+	return func(c *config) error {
+		if f != nil {
+			c.errorTTLFunc = func(key string, err error) time.Duration { return f(err) }
+		}
+
+		return nil
+	}
+}
+
+// WithErrorTTLFuncKeyed works like WithErrorTTLFunc, but the function gets the key as well,
+// so errors can be cached differently for keys backed by different upstreams.
+func WithErrorTTLFuncKeyed(f KeyedErrorTTLFunc) Option {
 	return func(c *config) error {
 		if f != nil {
 			c.errorTTLFunc = f
@@ -68,3 +150,605 @@ func WithBackgroundFetchErrorHandler(backgroundErrorHandler BackgroundErrorHandl
 		return nil
 	}
 }
+
+// WithFreshnessTarget sets the operational freshness goal for cached data.
+// Every hit, including stale hits, serving data older than the target increments the `StaleServedBeyondTarget`
+// stats counter.
+// It doesn't change the caching behavior, it's meant for monitoring only.
+func WithFreshnessTarget(d time.Duration) Option {
+	return func(c *config) error {
+		if d <= 0 {
+			return errors.New("freshness target has to be > 0")
+		}
+
+		c.freshnessTarget = d
+
+		return nil
+	}
+}
+
+// WithBatchedGet enables transparent batching of backend reads.
+// Reads issued by concurrent Get calls within the window are performed as a single backend call.
+// The backend has to implement the `MultiGetBackend` interface. A `TTLAwareBackend` has to implement
+// `MultiGetWithTTLBackend` as well, so batched reads still take the remaining TTLs into account.
+func WithBatchedGet(window time.Duration) Option {
+	return func(c *config) error {
+		if window <= 0 {
+			return errors.New("batch window has to be > 0")
+		}
+
+		c.batchWindow = window
+
+		return nil
+	}
+}
+
+// WithAccessTrace enables recording a trace of all Get calls to the writer.
+// Each access is written as a single line containing the timestamp, key and result type.
+// The trace can be replayed against a differently configured cache with `Replay`.
+func WithAccessTrace(w io.Writer) Option {
+	return func(c *config) error {
+		if w == nil {
+			return errors.New("trace writer is nil")
+		}
+
+		c.accessTrace = &accessTrace{w: w}
+
+		return nil
+	}
+}
+
+// WithKeyFreshnessFunc allows disabling the warm window for selected keys.
+// For keys with warm serving disabled, data older than the primary TTL is fetched immediately, as on a miss.
+func WithKeyFreshnessFunc(f KeyFreshnessFunc) Option {
+	return func(c *config) error {
+		c.keyFreshnessFunc = f
+
+		return nil
+	}
+}
+
+// WithServeExpiredOnError allows serving expired data when the fetch on a miss fails.
+// If the backend still holds an entry past the secondary TTL, its data is returned along with the fetch error,
+// and the result type is StaleHit.
+func WithServeExpiredOnError() Option {
+	return func(c *config) error {
+		c.serveExpiredOnError = true
+
+		return nil
+	}
+}
+
+// WithMaxInFlightKeys limits the number of distinct keys fetched concurrently on a miss.
+// When the limit is reached, misses for other keys fail with `ErrTooManyInFlight` instead of fetching.
+// Every key fetched by a `GetMulti` batch counts towards the limit, and the keys over it fail the same way.
+// Fetches continuing in the background, after `WithNonBlockingMiss` or `GetWithin`, count until they finish.
+// Hits and requests waiting for keys already being fetched are not affected.
+func WithMaxInFlightKeys(n int) Option {
+	return func(c *config) error {
+		if n <= 0 {
+			return errors.New("max in-flight keys has to be > 0")
+		}
+
+		c.maxInFlightKeys = n
+
+		return nil
+	}
+}
+
+// WithKeyValidator sets a function validating keys before they reach the backend.
+// If it returns an error for a key, the call fails with that error without touching the backend.
+func WithKeyValidator(f func(key string) error) Option {
+	return func(c *config) error {
+		c.keyValidator = f
+
+		return nil
+	}
+}
+
+// WithResilienceMirror enables a bounded in-memory mirror of the last data seen per key.
+// The mirror is populated by successful reads and writes, and used only when the backend read fails.
+// In that case the mirrored data is returned with no error, and the result type is StaleHit.
+// It's useful with remote backends, like redis.
+func WithResilienceMirror(size int) Option {
+	return func(c *config) error {
+		if size <= 0 {
+			return errors.New("mirror size has to be > 0")
+		}
+
+		c.resilienceMirrorSize = size
+
+		return nil
+	}
+}
+
+// WithMutationDetection enables detecting modifications of values returned by Get.
+// Backends like lru return the same pointer on every read, so a caller modifying the value corrupts the cache.
+// With this option, Get panics when it serves a previously returned pointer whose value has changed.
+// Values are compared using their `%#v` representation, so changes behind nested pointers are not detected.
+//
+// It's a debugging aid with a significant cost, not meant for production use.
+func WithMutationDetection() Option {
+	return func(c *config) error {
+		c.mutationDetection = true
+
+		return nil
+	}
+}
+
+// WithRefreshWorkers limits background refreshes to a pool of n workers.
+// Refreshes waiting for a worker are queued by urgency: entries closer to the secondary TTL expiration are refreshed first.
+func WithRefreshWorkers(n int) Option {
+	return func(c *config) error {
+		if n <= 0 {
+			return errors.New("number of refresh workers has to be > 0")
+		}
+
+		c.refreshWorkers = n
+
+		return nil
+	}
+}
+
+// WithLogger sets a logger for diagnostic logs, e.g. the key tracing enabled with `Cache.TraceKey`.
+func WithLogger(l Logger) Option {
+	return func(c *config) error {
+		c.logger = l
+
+		return nil
+	}
+}
+
+// WithExpectedKeys sets a hint for the number of keys accessed concurrently.
+// Internal coordination structures are preallocated accordingly, which reduces rehashing under the initial load.
+func WithExpectedKeys(n int) Option {
+	return func(c *config) error {
+		if n < 0 {
+			return errors.New("expected keys has to be >= 0")
+		}
+
+		c.expectedKeys = n
+
+		return nil
+	}
+}
+
+// WithShardCount splits the internal per-key coordination state into n shards, reducing lock contention
+// when many distinct keys are accessed concurrently.
+func WithShardCount(n int) Option {
+	return func(c *config) error {
+		if n <= 0 {
+			return errors.New("shard count has to be > 0")
+		}
+
+		c.shardCount = n
+
+		return nil
+	}
+}
+
+// WithShardHash sets the hash function used to assign keys to shards. It defaults to FNV-1a.
+// A custom function can improve the distribution for specific key patterns.
+func WithShardHash(f func(key string) uint64) Option {
+	return func(c *config) error {
+		if f == nil {
+			return errors.New("shard hash function is nil")
+		}
+
+		c.shardHash = f
+
+		return nil
+	}
+}
+
+// WithRefreshErrors makes WarmHit results carry the error of the last failed background refresh of the key
+// in `Result.RefreshErr`. The error is cleared when the key is successfully fetched again.
+func WithRefreshErrors() Option {
+	return func(c *config) error {
+		c.refreshErrors = true
+
+		return nil
+	}
+}
+
+// WithRefreshProbe sets a probe consulted by background refreshes before calling the FetchFunc.
+// If the probe reports that the data didn't change, the current entry is stored again as freshly created,
+// without the full fetch. If the probe fails, the error is passed to the background error handler
+// and the full fetch is performed.
+// The probe type has to match the cache type, otherwise `New` fails.
+func WithRefreshProbe[T any](probe RefreshProbeFunc[T]) Option {
+	return func(c *config) error {
+		if probe == nil {
+			return errors.New("refresh probe is nil")
+		}
+
+		c.refreshProbe = probe
+
+		return nil
+	}
+}
+
+// WithStartupRefreshDelay disables background refreshes for the given duration after the cache is created.
+// During that time warm hits are served without refreshing, which smooths the load when many instances
+// start at once with a shared, already warmed backend. Misses are fetched as usual.
+func WithStartupRefreshDelay(d time.Duration) Option {
+	return func(c *config) error {
+		if d < 0 {
+			return errors.New("startup refresh delay has to be >= 0")
+		}
+
+		c.startupRefreshDelay = d
+
+		return nil
+	}
+}
+
+// WithFetchLimiter makes every fetch, including background refreshes, wait for a slot in the limiter.
+// The limiter can be shared by multiple caches to bound their combined fetch concurrency.
+func WithFetchLimiter(l *Limiter) Option {
+	return func(c *config) error {
+		if l == nil {
+			return errors.New("fetch limiter is nil")
+		}
+
+		c.fetchLimiter = l
+
+		return nil
+	}
+}
+
+// WithErrorTTLs enables caching errors with their own primary and secondary TTLs. Cached errors follow the same
+// model as data: a hot error is returned as is, a warm error is returned while the fetch is retried in the background,
+// and an expired error is fetched again in the foreground.
+// With `WithErrorTTLFunc` set as well, the function still decides which errors are cached, but cached ones
+// use these TTLs instead of the returned one.
+func WithErrorTTLs(primaryTTL, secondaryTTL time.Duration) Option {
+	return func(c *config) error {
+		if primaryTTL <= 0 {
+			return errors.New("error primaryTTL has to be > 0")
+		}
+		if secondaryTTL <= primaryTTL {
+			return errors.New("error secondaryTTL has to be > error primaryTTL")
+		}
+
+		c.errorPrimaryTTL = primaryTTL
+		c.errorSecondaryTTL = secondaryTTL
+
+		return nil
+	}
+}
+
+// WithLockPoolSize keeps up to n per-key locks of recently used keys after their last caller is done, in each shard.
+// Bursts of the same keys then reuse the locks instead of recreating them, at the cost of a bit of memory.
+func WithLockPoolSize(n int) Option {
+	return func(c *config) error {
+		if n < 0 {
+			return errors.New("lock pool size has to be >= 0")
+		}
+
+		c.lockPoolSize = n
+
+		return nil
+	}
+}
+
+// WithRefreshQueue bounds the number of background refreshes waiting for a refresh worker.
+// Refreshes that can't run immediately are queued and run as workers free up, the most urgent first.
+// When the queue is full, the least urgent refresh is dropped; the entry is refreshed on a later warm hit.
+// It requires `WithRefreshWorkers`.
+func WithRefreshQueue(size int) Option {
+	return func(c *config) error {
+		if size <= 0 {
+			return errors.New("refresh queue size has to be > 0")
+		}
+
+		c.refreshQueueSize = size
+
+		return nil
+	}
+}
+
+// WithSchemaVersion sets the version of the cached data schema. Every entry stores the version it was written with,
+// and entries with a different version are treated as misses. Bumping the version on incompatible changes of the
+// cached type allows safe rollouts with persistent backends, as long as they store `CacheEntry.SchemaVersion`.
+func WithSchemaVersion(version string) Option {
+	return func(c *config) error {
+		c.schemaVersion = version
+
+		return nil
+	}
+}
+
+// WithFetchFuncConsistencyCheck enables detecting keys requested with different fetch functions.
+// Whichever function fetches first populates the entry, so using several of them for one key is a subtle source of bugs.
+// With this option, Get panics when a key is requested with a function other than the first one used for it.
+// Functions are compared by their code, so closures created by the same function literal are considered equal.
+// `KeyCache` and `GetPair` calls are checked with the caller's function, not the adapter wrapping it.
+//
+// It's a debugging aid remembering every requested key, not meant for production use.
+func WithFetchFuncConsistencyCheck() Option {
+	return func(c *config) error {
+		c.fetchFuncCheck = true
+
+		return nil
+	}
+}
+
+// WithMetricsObserver sets an observer of cache metrics, like hit types and fetch latencies.
+func WithMetricsObserver(obs Observer) Option {
+	return func(c *config) error {
+		if obs == nil {
+			return errors.New("metrics observer is nil")
+		}
+
+		c.observer = obs
+
+		return nil
+	}
+}
+
+// WithTracer enables tracing: every Get call gets a span tagged with the key and the result type,
+// and every fetch function call gets a child span. Background refreshes are traced in new root spans,
+// linked to the span of the Get call that initiated them.
+func WithTracer(t Tracer) Option {
+	return func(c *config) error {
+		if t == nil {
+			return errors.New("tracer is nil")
+		}
+
+		c.tracer = t
+
+		return nil
+	}
+}
+
+// WithHealthSource ties the TTLs to the health of the upstream data source.
+// While the source reports `Degraded` health, the primary and secondary TTLs are multiplied by the factor,
+// so staler data is served and refreshed less often, shedding the upstream load.
+// Entries are stored in the backend for the extended secondary TTL, so they're still available when the health degrades.
+// The source is called on every Get, so it should be fast.
+func WithHealthSource(source func() Health, degradedTTLFactor float64) Option {
+	return func(c *config) error {
+		if source == nil {
+			return errors.New("health source is nil")
+		}
+		if degradedTTLFactor < 1 {
+			return errors.New("degraded TTL factor has to be >= 1")
+		}
+
+		c.healthSource = source
+		c.degradedTTLFactor = degradedTTLFactor
+
+		return nil
+	}
+}
+
+// WithTTLJitter randomizes the secondary TTL of every stored entry by up to ±fraction of its value,
+// so entries populated at once don't expire at once. The fraction has to be in [0, 1).
+// The secondary TTL never drops below the primary TTL.
+func WithTTLJitter(fraction float64) Option {
+	return func(c *config) error {
+		if fraction < 0 || fraction >= 1 {
+			return errors.New("ttl jitter fraction has to be in [0, 1)")
+		}
+
+		c.ttlJitter = fraction
+
+		return nil
+	}
+}
+
+// WithNonBlockingMiss makes Get return immediately on a cold miss, with a Miss result and `ErrFetchInProgress`.
+// The data is fetched and stored in the background, so later calls get it once it's ready.
+// Calls for a key that is being fetched return `ErrFetchInProgress` as well, instead of waiting for the fetch.
+// `GetWithin` and `GetMulti` are not affected.
+func WithNonBlockingMiss() Option {
+	return func(c *config) error {
+		c.nonBlockingMiss = true
+
+		return nil
+	}
+}
+
+// WithClock sets the clock used for the TTL logic: classifying entries, their creation times and ages.
+// It's meant for tests, which can control the time with a manual clock, like `clocktest.Clock`,
+// instead of sleeping. Durations reported in metrics are always measured with the system clock.
+func WithClock(c Clock) Option {
+	return func(cfg *config) error {
+		if c == nil {
+			return errors.New("clock is nil")
+		}
+
+		cfg.clock = c
+
+		return nil
+	}
+}
+
+// WithBloomFilter keeps an in-memory bloom filter of the keys stored by the cache, to skip backend reads
+// for keys that are definitely not there. It pays off for remote backends with a large keyspace and many misses.
+// Get fetches such keys right away, and `Lookup` returns `ErrCacheMiss`.
+//
+// The filter only knows keys stored by this cache instance, so it's not suitable for backends shared by several
+// instances, and it starts empty: all the keys are fetched again after a restart.
+// Deleted and evicted keys can't be removed from a bloom filter, so it's rebuilt periodically:
+// keys are tracked in generations of expectedKeys each, and only the last two generations are kept.
+// Keys that are read are kept in the current generation. The false positive rate applies to each generation.
+func WithBloomFilter(expectedKeys uint, fpRate float64) Option {
+	return func(c *config) error {
+		if expectedKeys == 0 {
+			return errors.New("bloom filter expected keys has to be > 0")
+		}
+		if fpRate <= 0 || fpRate >= 1 {
+			return errors.New("bloom filter false positive rate has to be in (0, 1)")
+		}
+
+		c.bloomExpectedKeys = expectedKeys
+		c.bloomFPRate = fpRate
+
+		return nil
+	}
+}
+
+// WithMaxBackgroundRefreshes limits the number of background refreshes running at once to n.
+// Warm hits over the limit return the data without starting a refresh, the entry is refreshed on a later warm hit.
+// Skipped refreshes are counted in `Stats.RefreshesSkipped`.
+// It can't be used with `WithRefreshWorkers`, which limits the refreshes already.
+func WithMaxBackgroundRefreshes(n int) Option {
+	return func(c *config) error {
+		if n <= 0 {
+			return errors.New("max background refreshes has to be > 0")
+		}
+
+		c.maxBackgroundRefreshes = n
+
+		return nil
+	}
+}
+
+// WithCircuitBreaker short-circuits fetches on misses after threshold consecutive fetch failures, so callers
+// don't wait for an upstream that is down. For the cooldown, misses return `ErrCircuitOpen` without fetching,
+// along with expired data as a StaleHit if the backend still has it. After the cooldown, a single probe fetch
+// is let through: if it succeeds, the breaker closes, otherwise it opens for another cooldown.
+// Background refreshes are not affected, and fetches cancelled by the caller's ctx don't count as failures.
+// The breaker state is reported in `Stats`.
+func WithCircuitBreaker(threshold int, cooldown time.Duration) Option {
+	return func(c *config) error {
+		if threshold <= 0 {
+			return errors.New("circuit breaker threshold has to be > 0")
+		}
+		if cooldown <= 0 {
+			return errors.New("circuit breaker cooldown has to be > 0")
+		}
+
+		c.breakerThreshold = threshold
+		c.breakerCooldown = cooldown
+
+		return nil
+	}
+}
+
+// WithMemoryPressureHandler starts a background sampler of the heap usage, which calls the handler whenever
+// the usage exceeds the threshold in bytes. It's meant for in-memory backends, to free memory under pressure.
+// The default handler, used if onPressure is nil, purges expired entries with `Cache.PurgeExpired`.
+// By default the heap usage is sampled every 5 seconds with `runtime.ReadMemStats`, see `WithHeapUsageSource`.
+// The sampler stops when the cache is closed.
+func WithMemoryPressureHandler(threshold uint64, onPressure MemoryPressureHandler) Option {
+	return func(c *config) error {
+		if threshold == 0 {
+			return errors.New("memory threshold has to be > 0")
+		}
+		if onPressure == nil {
+			onPressure = purgeOnPressure
+		}
+
+		c.memoryThreshold = threshold
+		c.memoryPressureHandler = onPressure
+
+		return nil
+	}
+}
+
+// WithHeapUsageSource replaces the heap usage source of `WithMemoryPressureHandler`, e.g. with a gauge of
+// the process memory, and sets the sampling interval.
+func WithHeapUsageSource(source func() uint64, interval time.Duration) Option {
+	return func(c *config) error {
+		if source == nil {
+			return errors.New("heap usage source is nil")
+		}
+		if interval <= 0 {
+			return errors.New("heap sample interval has to be > 0")
+		}
+
+		c.heapUsage = source
+		c.heapSampleInterval = interval
+
+		return nil
+	}
+}
+
+// WithErrorRefreshAfter makes errors cached with `WithErrorTTLFunc` warm after the given time: until they expire,
+// they're still returned, but the fetch is retried in the background, so the cache recovers as soon as
+// the upstream does. Errors with a shorter TTL expire before they get warm, and are fetched again in the foreground.
+// It has no effect with `WithErrorTTLs`, which has its own primary TTL for errors.
+func WithErrorRefreshAfter(d time.Duration) Option {
+	return func(c *config) error {
+		if d <= 0 {
+			return errors.New("error refresh time has to be > 0")
+		}
+
+		c.errorRefreshAfter = d
+
+		return nil
+	}
+}
+
+// WithFallback sets a function returning the default value for the key, served when the fetch on a miss fails
+// and there's no stale data to serve. The value is returned as a FallbackHit along with the fetch error,
+// so callers can log the error and still use the value. It's not cached, and not used for cached errors.
+// The function type has to match the cache type, otherwise `New` fails.
+func WithFallback[T any](f func(key string) *T) Option {
+	return func(c *config) error {
+		if f == nil {
+			return errors.New("fallback function is nil")
+		}
+
+		c.fallback = f
+
+		return nil
+	}
+}
+
+// WithInvalidationBus keeps local copies of entries coherent between cache instances sharing a backend.
+// Keys changed with Set, Update, Delete, ReportInvalid, Expire, ForceRefresh or RefreshAndWait are published
+// to the bus, and keys published by other instances are evicted from the local tier of a backend implementing
+// `LocalEvictingBackend`, and from the resilience mirror. Background refreshes of the key requested before
+// the eviction don't store their data. These methods return an error if publishing fails, after the change
+// was applied locally.
+//
+// The bus carries single keys, so Purge and BumpEpoch are not published: other instances have to be purged
+// or bumped on their own. Neither are fetches on misses and background refreshes, which follow the TTLs.
+//
+// See the redis backend's `WithInvalidationChannel` for a bus using a redis pub/sub channel.
+func WithInvalidationBus(bus InvalidationBus) Option {
+	return func(c *config) error {
+		if bus == nil {
+			return errors.New("invalidation bus is nil")
+		}
+
+		c.invalidationBus = bus
+
+		return nil
+	}
+}
+
+// FailingOption returns an option failing with the error. It's meant for options built outside of this package,
+// which can fail before being applied, so the error is reported by `New` like errors of other options.
+func FailingOption(err error) Option {
+	return func(c *config) error {
+		return err
+	}
+}
+
+// Compose bundles several options into one, applied in order.
+func Compose(options ...Option) Option {
+	return func(c *config) error {
+		for _, o := range options {
+			if err := o(c); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+// Validate applies the options to a throwaway config and returns the first error.
+func Validate(options ...Option) error {
+	var c config
+	if err := Compose(options...)(&c); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,48 @@
+package smartcache_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/m-zajac/smartcache"
+	"github.com/m-zajac/smartcache/backend/lru"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateAndCompose(t *testing.T) {
+	t.Parallel()
+
+	valid := smartcache.Compose(
+		smartcache.WithTTL(time.Second, time.Minute),
+		smartcache.WithBackgroundFetchTimeout(time.Second),
+	)
+	invalid := smartcache.Compose(
+		smartcache.WithBackgroundFetchTimeout(time.Second),
+		smartcache.WithTTL(time.Minute, time.Second),
+	)
+
+	assert.NoError(t, smartcache.Validate(valid))
+	assert.Error(t, smartcache.Validate(invalid))
+	assert.Error(t, smartcache.Validate(valid, invalid))
+
+	// Composed options can be reused for caches of different types.
+	stringBackend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+	_, err = smartcache.New[string](stringBackend, valid)
+	assert.NoError(t, err)
+
+	intBackend, err := lru.NewBackend[int](100)
+	require.NoError(t, err)
+	_, err = smartcache.New[int](intBackend, valid)
+	assert.NoError(t, err)
+	_, err = smartcache.New[int](intBackend, invalid)
+	assert.Error(t, err)
+
+	// Options failing before being applied report their error the same way.
+	buildErr := errors.New("build failed")
+	assert.ErrorIs(t, smartcache.Validate(valid, smartcache.FailingOption(buildErr)), buildErr)
+	_, err = smartcache.New[int](intBackend, smartcache.FailingOption(buildErr))
+	assert.ErrorIs(t, err, buildErr)
+}
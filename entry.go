@@ -7,25 +7,32 @@ type CacheEntry[T any] struct {
 	Err             error
 	Created         time.Time
 	FixedExpiration *time.Time
+	// Epoch is the cache epoch the entry was fetched in. See `Cache.BumpEpoch`.
+	Epoch uint64
+	// SchemaVersion is the schema version of the cache that stored the entry. See `WithSchemaVersion`.
+	SchemaVersion string
 }
 
 func newOKCacheEntry[T any](data *T, created time.Time) *CacheEntry[T] {
 	return &CacheEntry[T]{Data: data, Created: created}
 }
 
-func newErrCacheEntry[T any](err error, ttl time.Duration) *CacheEntry[T] {
-	exp := time.Now().Add(ttl)
-	return &CacheEntry[T]{Err: err, FixedExpiration: &exp}
+func newErrCacheEntry[T any](err error, ttl time.Duration, now time.Time) *CacheEntry[T] {
+	exp := now.Add(ttl)
+	return &CacheEntry[T]{Err: err, Created: now, FixedExpiration: &exp}
 }
 
-func newEmptyExpiredCacheEntry[T any]() *CacheEntry[T] {
-	exp := time.Now()
+func newEmptyExpiredCacheEntry[T any](now time.Time) *CacheEntry[T] {
+	exp := now
 	return &CacheEntry[T]{FixedExpiration: &exp}
 }
 
 func (it *CacheEntry[T]) IsExpired(ttl time.Duration) bool {
-	now := time.Now()
+	return it.IsExpiredAt(ttl, time.Now())
+}
 
+// IsExpiredAt works like IsExpired, but checks the expiration at the given time.
+func (it *CacheEntry[T]) IsExpiredAt(ttl time.Duration, now time.Time) bool {
 	if it.FixedExpiration != nil {
 		return it.FixedExpiration.Before(now)
 	}
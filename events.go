@@ -0,0 +1,76 @@
+package smartcache
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// eventsBuffer is the number of events buffered for each subscriber.
+const eventsBuffer = 256
+
+// Event describes a served Get call. See `Subscribe`.
+type Event struct {
+	Key  string
+	Type ResultType
+	Age  time.Duration
+	Time time.Time
+}
+
+// eventHub distributes events to subscribers.
+type eventHub struct {
+	mu   sync.RWMutex
+	subs map[chan Event]struct{}
+	// count allows skipping the publishing when there are no subscribers.
+	count atomic.Int32
+}
+
+func (h *eventHub) subscribe() (chan Event, func()) {
+	ch := make(chan Event, eventsBuffer)
+
+	h.mu.Lock()
+	if h.subs == nil {
+		h.subs = make(map[chan Event]struct{})
+	}
+	h.subs[ch] = struct{}{}
+	h.count.Add(1)
+	h.mu.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			h.mu.Lock()
+			delete(h.subs, ch)
+			h.count.Add(-1)
+			h.mu.Unlock()
+
+			close(ch)
+		})
+	}
+
+	return ch, unsubscribe
+}
+
+// publish sends the event to all subscribers without blocking. It returns the number of subscribers
+// that didn't receive the event because their buffer was full.
+func (h *eventHub) publish(e Event) (dropped int) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for ch := range h.subs {
+		select {
+		case ch <- e:
+		default:
+			dropped++
+		}
+	}
+
+	return dropped
+}
+
+// Subscribe returns a stream of events for all served Get calls, and a function cancelling the subscription
+// and closing the channel. The channel is buffered, if the subscriber doesn't keep up the events are dropped,
+// so Get is never blocked. Dropped events are counted in `Stats.EventsDropped`.
+func (sc *Cache[T]) Subscribe() (<-chan Event, func()) {
+	return sc.events.subscribe()
+}
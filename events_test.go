@@ -0,0 +1,93 @@
+package smartcache_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/m-zajac/smartcache"
+	"github.com/m-zajac/smartcache/backend/lru"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache_Subscribe(t *testing.T) {
+	t.Parallel()
+
+	backend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+	cache, err := smartcache.New[string](backend)
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	ctx := context.Background()
+	data := "some data"
+	fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		return &smartcache.FetchResult[string]{Data: &data}, nil
+	}
+
+	events, unsubscribe := cache.Subscribe()
+
+	_, err = cache.Get(ctx, "key", fetchFunc)
+	require.NoError(t, err)
+	_, err = cache.Get(ctx, "key", fetchFunc)
+	require.NoError(t, err)
+
+	e := <-events
+	assert.Equal(t, "key", e.Key)
+	assert.Equal(t, smartcache.Miss, e.Type)
+	assert.WithinDuration(t, time.Now(), e.Time, time.Second)
+
+	e = <-events
+	assert.Equal(t, "key", e.Key)
+	assert.Equal(t, smartcache.HotHit, e.Type)
+
+	unsubscribe()
+	unsubscribe() // Safe to call multiple times.
+
+	_, err = cache.Get(ctx, "key", fetchFunc)
+	require.NoError(t, err)
+	_, ok := <-events
+	assert.False(t, ok, "channel is closed after unsubscribing")
+	assert.Zero(t, cache.Stats().EventsDropped)
+}
+
+func TestCache_SubscribeSlowConsumer(t *testing.T) {
+	t.Parallel()
+
+	backend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+	cache, err := smartcache.New[string](backend)
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	ctx := context.Background()
+	data := "some data"
+	fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		return &smartcache.FetchResult[string]{Data: &data}, nil
+	}
+
+	// The subscriber never reads.
+	_, unsubscribe := cache.Subscribe()
+	t.Cleanup(unsubscribe)
+
+	const gets = 1000
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < gets; i++ {
+			_, err := cache.Get(ctx, "key", fetchFunc)
+			assert.NoError(t, err)
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("gets were blocked by the subscriber")
+	}
+
+	dropped := cache.Stats().EventsDropped
+	assert.Positive(t, dropped)
+	assert.Less(t, dropped, uint64(gets))
+}
@@ -0,0 +1,45 @@
+package smartcache
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"sync"
+)
+
+// fetchFuncChecker detects keys requested with different fetch functions.
+// It remembers the code pointer of the first fetch function used for each key, and panics
+// if the key is requested with a function having a different one.
+type fetchFuncChecker struct {
+	mu    sync.Mutex
+	funcs map[string]uintptr
+}
+
+func newFetchFuncChecker() *fetchFuncChecker {
+	return &fetchFuncChecker{
+		funcs: make(map[string]uintptr),
+	}
+}
+
+func (c *fetchFuncChecker) check(key string, fetchFunc any) {
+	pc := reflect.ValueOf(fetchFunc).Pointer()
+
+	c.mu.Lock()
+	prev, ok := c.funcs[key]
+	if !ok {
+		c.funcs[key] = pc
+	}
+	c.mu.Unlock()
+
+	if ok && prev != pc {
+		panic(fmt.Sprintf("smartcache: key '%s' requested with fetch function %s, previously with %s", key, funcName(pc), funcName(prev)))
+	}
+}
+
+func funcName(pc uintptr) string {
+	if f := runtime.FuncForPC(pc); f != nil {
+		return f.Name()
+	}
+
+	return fmt.Sprintf("%#x", pc)
+}
@@ -0,0 +1,60 @@
+package smartcache
+
+import (
+	"context"
+	"fmt"
+)
+
+// InvalidationBus broadcasts keys changed by one cache instance to the other instances, see `WithInvalidationBus`.
+type InvalidationBus interface {
+	// Publish broadcasts the key changed by this instance.
+	Publish(ctx context.Context, key string) error
+	// Subscribe starts receiving keys published by other instances. It returns once the subscription is active.
+	// The returned channel is closed when ctx is done.
+	Subscribe(ctx context.Context) (<-chan string, error)
+}
+
+// LocalEvictingBackend is an optional interface of backends keeping entries locally, e.g. a tiered backend with
+// an in-memory L1. EvictLocal removes the local copy of the entry only, leaving the shared one untouched.
+type LocalEvictingBackend[T any] interface {
+	EvictLocal(ctx context.Context, key string) error
+}
+
+// publishInvalidation broadcasts the changed key, if the invalidation bus is enabled.
+func (sc *Cache[T]) publishInvalidation(ctx context.Context, key string) error {
+	if sc.config.invalidationBus == nil {
+		return nil
+	}
+	if err := sc.config.invalidationBus.Publish(ctx, key); err != nil {
+		return fmt.Errorf("publishing invalidation of key '%s': %w", key, err)
+	}
+
+	return nil
+}
+
+// runInvalidations evicts local entries of keys received from the invalidation bus until the cache is closed.
+func (sc *Cache[T]) runInvalidations(keys <-chan string) {
+	defer sc.wg.Done()
+
+	for key := range keys {
+		sc.invalidateLocal(key)
+	}
+}
+
+// invalidateLocal evicts the local copies of the entry changed by another instance.
+func (sc *Cache[T]) invalidateLocal(key string) {
+	req, _ := sc.acquire(key)
+	defer sc.release(key, req)
+
+	// Refreshes requested before the change would store data older than the other instance's.
+	req.writes.Add(1)
+	if lb, ok := sc.backend.(LocalEvictingBackend[T]); ok {
+		if err := lb.EvictLocal(sc.ctx, key); err != nil {
+			sc.config.backgroundErrorHandler(fmt.Errorf("evicting invalidated key '%s': %w", key, err))
+		}
+	}
+	if sc.mirror != nil {
+		sc.mirror.remove(key)
+	}
+	sc.resetStaleServes(key)
+}
@@ -0,0 +1,206 @@
+package smartcache
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// KeyFunc derives the storage key, used by the cache and its backend, from a key of another type.
+type KeyFunc[K comparable] func(key K) string
+
+// KeyFetchFunc works like FetchFunc, for keys of type K.
+type KeyFetchFunc[K comparable, T any] func(ctx context.Context, key K) (*FetchResult[T], error)
+
+// KeyBatchFetchFunc works like BatchFetchFunc, for keys of type K.
+// To report failures of some of the keys only, return a `KeyErrors` error with the storage keys.
+type KeyBatchFetchFunc[K comparable, T any] func(ctx context.Context, keys []K) (map[K]*FetchResult[T], error)
+
+// KeyCache gives access to the cache with keys of any comparable type, e.g. structs of the key parts,
+// instead of building and parsing string keys manually. Every key is converted to a storage key with the key function,
+// so keys converted to the same string share the cached data.
+//
+// The cache itself keeps string keys: every backend stores entries under strings anyway, and a key type parameter
+// on `Cache` and `Backend` would break all existing code and backends. KeyCache wraps all methods taking keys;
+// methods of the whole cache, like Stats, Purge or Close, are available on the wrapped cache, see `KeyCache.Cache`.
+type KeyCache[K comparable, T any] struct {
+	cache   *Cache[T]
+	keyFunc KeyFunc[K]
+}
+
+// NewKeyCache wraps the cache for keys of type K. If keyFunc is nil, keys are converted with `fmt.Sprint`.
+func NewKeyCache[K comparable, T any](cache *Cache[T], keyFunc KeyFunc[K]) *KeyCache[K, T] {
+	if keyFunc == nil {
+		keyFunc = func(key K) string { return fmt.Sprint(key) }
+	}
+
+	return &KeyCache[K, T]{
+		cache:   cache,
+		keyFunc: keyFunc,
+	}
+}
+
+// Cache returns the wrapped cache, e.g. for reading stats or closing it.
+func (kc *KeyCache[K, T]) Cache() *Cache[T] {
+	return kc.cache
+}
+
+// StorageKey returns the string key the data for the key is stored under, e.g. for `CoordinationKey`.
+func (kc *KeyCache[K, T]) StorageKey(key K) string {
+	return kc.keyFunc(key)
+}
+
+// Get works like `Cache.Get`.
+func (kc *KeyCache[K, T]) Get(ctx context.Context, key K, fetchFunc KeyFetchFunc[K, T], opts ...GetOpt) (Result[T], error) {
+	return kc.get(ctx, key, fetchFunc, newGetOptions[T](opts))
+}
+
+// GetPreferData works like `Cache.GetPreferData`.
+func (kc *KeyCache[K, T]) GetPreferData(ctx context.Context, key K, fetchFunc KeyFetchFunc[K, T]) (Result[T], error) {
+	return preferData(kc.get(ctx, key, fetchFunc, getOptions[T]{}))
+}
+
+// GetWithEntry works like `Cache.GetWithEntry`.
+func (kc *KeyCache[K, T]) GetWithEntry(ctx context.Context, key K, entry *CacheEntry[T], fetchFunc KeyFetchFunc[K, T]) (Result[T], error) {
+	return kc.get(ctx, key, fetchFunc, getOptions[T]{
+		entry:    kc.cache.clampCreated(entry),
+		hasEntry: true,
+	})
+}
+
+// GetWithin works like `Cache.GetWithin`.
+func (kc *KeyCache[K, T]) GetWithin(ctx context.Context, key K, fetchFunc KeyFetchFunc[K, T], budget time.Duration) (Result[T], error) {
+	if budget <= 0 {
+		return Result[T]{}, ErrFetchBudgetExceeded
+	}
+
+	return kc.get(ctx, key, fetchFunc, getOptions[T]{
+		budget: budget,
+	})
+}
+
+// RefreshAndWait works like `Cache.RefreshAndWait`.
+func (kc *KeyCache[K, T]) RefreshAndWait(ctx context.Context, key K, fetchFunc KeyFetchFunc[K, T]) (Result[T], error) {
+	return kc.cache.RefreshAndWait(ctx, kc.keyFunc(key), kc.fetchFunc(key, fetchFunc))
+}
+
+// ForceRefresh works like `Cache.ForceRefresh`.
+func (kc *KeyCache[K, T]) ForceRefresh(ctx context.Context, key K, fetchFunc KeyFetchFunc[K, T]) (Result[T], error) {
+	return kc.cache.ForceRefresh(ctx, kc.keyFunc(key), kc.fetchFunc(key, fetchFunc))
+}
+
+// Lookup works like `Cache.Lookup`.
+func (kc *KeyCache[K, T]) Lookup(ctx context.Context, key K) (Result[T], error) {
+	return kc.cache.Lookup(ctx, kc.keyFunc(key))
+}
+
+// Peek works like `Cache.Peek`.
+func (kc *KeyCache[K, T]) Peek(ctx context.Context, key K) (Result[T], bool, error) {
+	return kc.cache.Peek(ctx, kc.keyFunc(key))
+}
+
+// GetRaw works like `Cache.GetRaw`.
+func (kc *KeyCache[K, T]) GetRaw(ctx context.Context, key K) ([]byte, bool, error) {
+	return kc.cache.GetRaw(ctx, kc.keyFunc(key))
+}
+
+// Set works like `Cache.Set`.
+func (kc *KeyCache[K, T]) Set(ctx context.Context, key K, data *T) error {
+	return kc.cache.Set(ctx, kc.keyFunc(key), data)
+}
+
+// Update works like `Cache.Update`.
+func (kc *KeyCache[K, T]) Update(ctx context.Context, key K, data *T) (Result[T], error) {
+	return kc.cache.Update(ctx, kc.keyFunc(key), data)
+}
+
+// Delete works like `Cache.Delete`.
+func (kc *KeyCache[K, T]) Delete(ctx context.Context, key K) error {
+	return kc.cache.Delete(ctx, kc.keyFunc(key))
+}
+
+// Expire works like `Cache.Expire`.
+func (kc *KeyCache[K, T]) Expire(ctx context.Context, key K) error {
+	return kc.cache.Expire(ctx, kc.keyFunc(key))
+}
+
+// ConfirmValid works like `Cache.ConfirmValid`.
+func (kc *KeyCache[K, T]) ConfirmValid(ctx context.Context, key K) error {
+	return kc.cache.ConfirmValid(ctx, kc.keyFunc(key))
+}
+
+// ReportInvalid works like `Cache.ReportInvalid`.
+func (kc *KeyCache[K, T]) ReportInvalid(ctx context.Context, key K) error {
+	return kc.cache.ReportInvalid(ctx, kc.keyFunc(key))
+}
+
+// StaleServeCount works like `Cache.StaleServeCount`.
+func (kc *KeyCache[K, T]) StaleServeCount(key K) int {
+	return kc.cache.StaleServeCount(kc.keyFunc(key))
+}
+
+// TraceKey works like `Cache.TraceKey`.
+func (kc *KeyCache[K, T]) TraceKey(key K, on bool) {
+	kc.cache.TraceKey(kc.keyFunc(key), on)
+}
+
+// WarmupStream works like `Cache.WarmupStream`. Collected errors refer to the storage keys.
+func (kc *KeyCache[K, T]) WarmupStream(ctx context.Context, keys <-chan K, fetchFunc KeyFetchFunc[K, T], concurrency int) error {
+	return warmupStream(ctx, keys, concurrency, kc.keyFunc, func(ctx context.Context, key K) error {
+		_, err := kc.RefreshAndWait(ctx, key, fetchFunc)
+		return err
+	})
+}
+
+// GetMulti works like `Cache.GetMulti`.
+func (kc *KeyCache[K, T]) GetMulti(ctx context.Context, keys []K, fetchFunc KeyBatchFetchFunc[K, T]) (map[K]Result[T], map[K]error) {
+	storageKeys := make([]string, len(keys))
+	byStorageKey := make(map[string]K, len(keys))
+	for i, key := range keys {
+		storageKeys[i] = kc.keyFunc(key)
+		byStorageKey[storageKeys[i]] = key
+	}
+
+	results, errs := kc.cache.GetMulti(ctx, storageKeys, func(ctx context.Context, storageKeys []string) (map[string]*FetchResult[T], error) {
+		fetchKeys := make([]K, len(storageKeys))
+		for i, storageKey := range storageKeys {
+			fetchKeys[i] = byStorageKey[storageKey]
+		}
+
+		fetched, err := fetchFunc(ctx, fetchKeys)
+		data := make(map[string]*FetchResult[T], len(fetched))
+		for key, result := range fetched {
+			data[kc.keyFunc(key)] = result
+		}
+
+		return data, err
+	})
+
+	keyResults := make(map[K]Result[T], len(results))
+	keyErrs := make(map[K]error, len(errs))
+	for i, key := range keys {
+		if result, ok := results[storageKeys[i]]; ok {
+			keyResults[key] = result
+		}
+		if err, ok := errs[storageKeys[i]]; ok {
+			keyErrs[key] = err
+		}
+	}
+
+	return keyResults, keyErrs
+}
+
+// get calls get of the cache with the adapted fetch function. The consistency check compares the caller's function,
+// see `WithFetchFuncConsistencyCheck`.
+func (kc *KeyCache[K, T]) get(ctx context.Context, key K, fetchFunc KeyFetchFunc[K, T], opts getOptions[T]) (Result[T], error) {
+	opts.checkedFetchFunc = fetchFunc
+
+	return kc.cache.get(ctx, kc.keyFunc(key), kc.fetchFunc(key, fetchFunc), opts)
+}
+
+// fetchFunc adapts the fetch function of the key to `FetchFunc`.
+func (kc *KeyCache[K, T]) fetchFunc(key K, fetchFunc KeyFetchFunc[K, T]) FetchFunc[T] {
+	return func(ctx context.Context, _ string) (*FetchResult[T], error) {
+		return fetchFunc(ctx, key)
+	}
+}
@@ -0,0 +1,212 @@
+package smartcache_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/m-zajac/smartcache"
+	"github.com/m-zajac/smartcache/backend/lru"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type userKey struct {
+	Tenant string
+	ID     int
+}
+
+func TestKeyCache(t *testing.T) {
+	t.Parallel()
+
+	backend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+	cache, err := smartcache.New[string](backend)
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	kc := smartcache.NewKeyCache[userKey, string](cache, func(key userKey) string {
+		return fmt.Sprintf("%s/%d", key.Tenant, key.ID)
+	})
+	assert.Same(t, cache, kc.Cache())
+
+	ctx := context.Background()
+	var fetched []userKey
+	fetchFunc := func(ctx context.Context, key userKey) (*smartcache.FetchResult[string], error) {
+		fetched = append(fetched, key)
+		data := fmt.Sprintf("user %d", key.ID)
+		return &smartcache.FetchResult[string]{Data: &data}, nil
+	}
+
+	key := userKey{Tenant: "acme", ID: 1}
+	result, err := kc.Get(ctx, key, fetchFunc)
+	require.NoError(t, err)
+	assert.Equal(t, smartcache.Miss, result.Type)
+	assert.Equal(t, "user 1", *result.Data)
+	assert.Equal(t, []userKey{key}, fetched)
+
+	result, err = kc.Get(ctx, key, fetchFunc)
+	require.NoError(t, err)
+	assert.Equal(t, smartcache.HotHit, result.Type)
+	assert.Len(t, fetched, 1)
+
+	// The data is stored under the derived key.
+	result, err = cache.Lookup(ctx, "acme/1")
+	require.NoError(t, err)
+	assert.Equal(t, "user 1", *result.Data)
+
+	data := "user 2 set"
+	other := userKey{Tenant: "acme", ID: 2}
+	require.NoError(t, kc.Set(ctx, other, &data))
+	result, err = kc.Lookup(ctx, other)
+	require.NoError(t, err)
+	assert.Equal(t, data, *result.Data)
+
+	require.NoError(t, kc.Delete(ctx, other))
+	_, err = kc.Lookup(ctx, other)
+	assert.ErrorIs(t, err, smartcache.ErrCacheMiss)
+
+	missing := userKey{Tenant: "acme", ID: 3}
+	failing := userKey{Tenant: "acme", ID: 4}
+	fetchErr := fmt.Errorf("fetch failed")
+	results, errs := kc.GetMulti(ctx, []userKey{key, missing, failing}, func(ctx context.Context, keys []userKey) (map[userKey]*smartcache.FetchResult[string], error) {
+		assert.ElementsMatch(t, []userKey{missing, failing}, keys)
+		data := "user 3"
+		return map[userKey]*smartcache.FetchResult[string]{missing: {Data: &data}}, smartcache.KeyErrors{"acme/4": fetchErr}
+	})
+	require.Len(t, results, 2)
+	assert.Equal(t, smartcache.HotHit, results[key].Type)
+	assert.Equal(t, smartcache.Miss, results[missing].Type)
+	assert.Equal(t, "user 3", *results[missing].Data)
+	require.Len(t, errs, 1)
+	assert.ErrorIs(t, errs[failing], fetchErr)
+}
+
+func TestKeyCache_DefaultKeyFunc(t *testing.T) {
+	t.Parallel()
+
+	backend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+	cache, err := smartcache.New[string](backend)
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	kc := smartcache.NewKeyCache[int, string](cache, nil)
+
+	ctx := context.Background()
+	data := "data"
+	require.NoError(t, kc.Set(ctx, 42, &data))
+
+	result, err := cache.Lookup(ctx, "42")
+	require.NoError(t, err)
+	assert.Equal(t, data, *result.Data)
+}
+
+func TestKeyCache_KeyMethods(t *testing.T) {
+	t.Parallel()
+
+	backend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+	cache, err := smartcache.New[string](backend)
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	kc := smartcache.NewKeyCache[userKey, string](cache, func(key userKey) string {
+		return fmt.Sprintf("%s/%d", key.Tenant, key.ID)
+	})
+	key := userKey{Tenant: "acme", ID: 1}
+	assert.Equal(t, "acme/1", kc.StorageKey(key))
+
+	ctx := context.Background()
+	fetchFunc := func(ctx context.Context, key userKey) (*smartcache.FetchResult[string], error) {
+		data := fmt.Sprintf("fetched %d", key.ID)
+		return &smartcache.FetchResult[string]{Data: &data}, nil
+	}
+
+	_, found, err := kc.Peek(ctx, key)
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	data := "updated"
+	result, err := kc.Update(ctx, key, &data)
+	require.NoError(t, err)
+	assert.Equal(t, smartcache.HotHit, result.Type)
+	result, found, err = kc.Peek(ctx, key)
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, "updated", *result.Data)
+
+	require.NoError(t, kc.Expire(ctx, key))
+	result, err = kc.Lookup(ctx, key)
+	require.NoError(t, err)
+	assert.Equal(t, smartcache.WarmHit, result.Type)
+
+	result, err = kc.ForceRefresh(ctx, key, fetchFunc)
+	require.NoError(t, err)
+	assert.Equal(t, "fetched 1", *result.Data)
+
+	require.NoError(t, kc.ReportInvalid(ctx, key))
+	_, err = cache.Lookup(ctx, "acme/1")
+	assert.ErrorIs(t, err, smartcache.ErrCacheMiss)
+
+	// Get options taking string keys get the storage key.
+	result, err = kc.Get(ctx, key, fetchFunc, smartcache.CoordinationKey(kc.StorageKey(userKey{Tenant: "acme"})))
+	require.NoError(t, err)
+	assert.Equal(t, smartcache.Miss, result.Type)
+	assert.Equal(t, "fetched 1", *result.Data)
+
+	keys := make(chan userKey, 2)
+	keys <- userKey{Tenant: "acme", ID: 2}
+	keys <- userKey{Tenant: "acme", ID: 3}
+	close(keys)
+	require.NoError(t, kc.WarmupStream(ctx, keys, fetchFunc, 2))
+	for _, storageKey := range []string{"acme/2", "acme/3"} {
+		result, err := cache.Lookup(ctx, storageKey)
+		require.NoError(t, err)
+		assert.Equal(t, smartcache.HotHit, result.Type)
+	}
+
+	keys = make(chan userKey, 1)
+	keys <- userKey{Tenant: "acme", ID: 4}
+	close(keys)
+	err = kc.WarmupStream(ctx, keys, func(ctx context.Context, key userKey) (*smartcache.FetchResult[string], error) {
+		return nil, fmt.Errorf("fetch failed")
+	}, 1)
+	var warmErr *smartcache.WarmupError
+	require.ErrorAs(t, err, &warmErr)
+	assert.Equal(t, 1, warmErr.Failed)
+	assert.Contains(t, err.Error(), "key 'acme/4'")
+}
+
+func TestKeyCache_FetchFuncConsistencyCheck(t *testing.T) {
+	t.Parallel()
+
+	backend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+	cache, err := smartcache.New[string](backend, smartcache.WithFetchFuncConsistencyCheck())
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	kc := smartcache.NewKeyCache[int, string](cache, nil)
+	fetchFunc := func(ctx context.Context, key int) (*smartcache.FetchResult[string], error) {
+		data := "data"
+		return &smartcache.FetchResult[string]{Data: &data}, nil
+	}
+	otherFetchFunc := func(ctx context.Context, key int) (*smartcache.FetchResult[string], error) {
+		return nil, fmt.Errorf("unexpected fetch")
+	}
+
+	ctx := context.Background()
+	_, err = kc.Get(ctx, 1, fetchFunc)
+	require.NoError(t, err)
+	_, err = kc.GetPreferData(ctx, 1, fetchFunc)
+	require.NoError(t, err)
+
+	assert.Panics(t, func() {
+		_, _ = kc.Get(ctx, 1, otherFetchFunc)
+	})
+	assert.Panics(t, func() {
+		_, _ = kc.GetWithin(ctx, 1, otherFetchFunc, time.Second)
+	})
+}
@@ -0,0 +1,36 @@
+package smartcache
+
+import "context"
+
+// Limiter bounds the number of concurrent fetches. A single limiter can be shared by multiple caches,
+// e.g. of different types fetching from the same upstream, to bound their combined concurrency.
+// See `WithFetchLimiter`.
+type Limiter struct {
+	slots chan struct{}
+}
+
+// NewLimiter creates a limiter allowing up to n concurrent fetches. n lower than 1 is treated as 1.
+func NewLimiter(n int) *Limiter {
+	if n <= 0 {
+		n = 1
+	}
+
+	return &Limiter{
+		slots: make(chan struct{}, n),
+	}
+}
+
+// Acquire blocks until a slot is available or ctx is done.
+func (l *Limiter) Acquire(ctx context.Context) error {
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Release frees a slot obtained with `Acquire`.
+func (l *Limiter) Release() {
+	<-l.slots
+}
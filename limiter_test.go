@@ -0,0 +1,99 @@
+package smartcache_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/m-zajac/smartcache"
+	"github.com/m-zajac/smartcache/backend/lru"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache_FetchLimiter(t *testing.T) {
+	t.Parallel()
+
+	const limit = 3
+	limiter := smartcache.NewLimiter(limit)
+
+	var running, maxRunning atomic.Int32
+	track := func() func() {
+		n := running.Add(1)
+		for {
+			m := maxRunning.Load()
+			if n <= m || maxRunning.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+
+		return func() { running.Add(-1) }
+	}
+
+	strBackend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+	strCache, err := smartcache.New[string](strBackend, smartcache.WithFetchLimiter(limiter))
+	require.NoError(t, err)
+	t.Cleanup(strCache.Close)
+
+	intBackend, err := lru.NewBackend[int](100)
+	require.NoError(t, err)
+	intCache, err := smartcache.New[int](intBackend, smartcache.WithFetchLimiter(limiter))
+	require.NoError(t, err)
+	t.Cleanup(intCache.Close)
+
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, err := strCache.Get(ctx, key, func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+				defer track()()
+				return &smartcache.FetchResult[string]{Data: &key}, nil
+			})
+			assert.NoError(t, err)
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := intCache.Get(ctx, key, func(ctx context.Context, key string) (*smartcache.FetchResult[int], error) {
+				defer track()()
+				v := len(key)
+				return &smartcache.FetchResult[int]{Data: &v}, nil
+			})
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	assert.LessOrEqual(t, maxRunning.Load(), int32(limit))
+	assert.Positive(t, maxRunning.Load())
+}
+
+func TestCache_FetchLimiterContext(t *testing.T) {
+	t.Parallel()
+
+	limiter := smartcache.NewLimiter(1)
+	require.NoError(t, limiter.Acquire(context.Background()))
+	defer limiter.Release()
+
+	backend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+	cache, err := smartcache.New[string](backend, smartcache.WithFetchLimiter(limiter))
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err = cache.Get(ctx, "key", func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		t.Error("fetch shouldn't be called without a limiter slot")
+		return nil, nil
+	})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -0,0 +1,55 @@
+package smartcache
+
+// requestShard holds requests for a subset of keys. It's owned by whoever received it from the shard channel.
+type requestShard struct {
+	requests map[string]*request
+
+	// Requests without callers are kept in the map for reuse, if the lock pool is enabled (see `WithLockPoolSize`).
+	// They form a list ordered from the least recently used.
+	idleOldest, idleNewest *request
+	idleCount              int
+}
+
+func newRequestShard(capacity int) *requestShard {
+	return &requestShard{
+		requests: make(map[string]*request, capacity),
+	}
+}
+
+// pushIdle appends the request to the idle list as the most recently used.
+func (rs *requestShard) pushIdle(req *request) {
+	req.idlePrev = rs.idleNewest
+	req.idleNext = nil
+	if rs.idleNewest != nil {
+		rs.idleNewest.idleNext = req
+	} else {
+		rs.idleOldest = req
+	}
+	rs.idleNewest = req
+	rs.idleCount++
+}
+
+// removeIdle removes the request from the idle list.
+func (rs *requestShard) removeIdle(req *request) {
+	if req.idlePrev != nil {
+		req.idlePrev.idleNext = req.idleNext
+	} else {
+		rs.idleOldest = req.idleNext
+	}
+	if req.idleNext != nil {
+		req.idleNext.idlePrev = req.idlePrev
+	} else {
+		rs.idleNewest = req.idlePrev
+	}
+	req.idlePrev, req.idleNext = nil, nil
+	rs.idleCount--
+}
+
+// evictIdle removes the least recently used idle request from the shard and returns it.
+func (rs *requestShard) evictIdle() *request {
+	req := rs.idleOldest
+	rs.removeIdle(req)
+	delete(rs.requests, req.key)
+
+	return req
+}
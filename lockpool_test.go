@@ -0,0 +1,47 @@
+package smartcache
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache_LockPool(t *testing.T) {
+	sc, err := New[int](&memoizeBackend[int]{}, WithLockPoolSize(2))
+	require.NoError(t, err)
+	defer sc.Close()
+
+	fetchFunc := func(ctx context.Context, key string) (*FetchResult[int], error) {
+		v := len(key)
+		return &FetchResult[int]{Data: &v}, nil
+	}
+
+	idleKeys := func() []string {
+		rs := <-sc.shards[0]
+		defer func() { sc.shards[0] <- rs }()
+
+		var keys []string
+		for req := rs.idleOldest; req != nil; req = req.idleNext {
+			keys = append(keys, req.key)
+		}
+		assert.Len(t, rs.requests, len(keys))
+
+		return keys
+	}
+
+	ctx := context.Background()
+	for _, key := range []string{"a", "b", "a", "c"} {
+		_, err := sc.Get(ctx, key, fetchFunc)
+		require.NoError(t, err)
+	}
+
+	// "b" was the least recently used idle lock.
+	assert.Equal(t, []string{"a", "c"}, idleKeys())
+
+	// Reused lock is moved to the end.
+	_, err = sc.Get(ctx, "a", fetchFunc)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c", "a"}, idleKeys())
+}
@@ -0,0 +1,135 @@
+package smartcache
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// memoizeKey is the only key used by memoized functions.
+const memoizeKey = "memoize"
+
+// Memoize returns a function caching the result of compute with the same hot/warm/miss semantics as the Cache.
+// Concurrent calls are coalesced into a single compute call.
+//
+// If the TTLs are invalid, the returned function always returns the configuration error.
+func Memoize[T any](primaryTTL, secondaryTTL time.Duration, compute func(ctx context.Context) (*T, error)) func(ctx context.Context) (*T, error) {
+	cache, err := New[T](&memoizeBackend[T]{}, WithTTL(primaryTTL, secondaryTTL))
+
+	fetchFunc := func(ctx context.Context, key string) (*FetchResult[T], error) {
+		data, err := compute(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		return &FetchResult[T]{
+			Data: data,
+		}, nil
+	}
+
+	return func(ctx context.Context) (*T, error) {
+		if err != nil {
+			return nil, err
+		}
+
+		result, err := cache.Get(ctx, memoizeKey, fetchFunc)
+
+		return result.Data, err
+	}
+}
+
+// Func1 returns a function caching results of f in the cache, keyed by the argument.
+// The key is derived from the type and the Go-syntax representation of the argument, so arguments of the same type
+// with equal representations share the cached result. Keys don't include any namespace, so the cache shouldn't be shared with other functions.
+func Func1[A comparable, R any](cache *Cache[R], f func(ctx context.Context, a A) (*R, error)) func(ctx context.Context, a A) (*R, error) {
+	return func(ctx context.Context, a A) (*R, error) {
+		return callCached(ctx, cache, argsKey(a), func(ctx context.Context) (*R, error) {
+			return f(ctx, a)
+		})
+	}
+}
+
+// Func2 is like `Func1`, for functions with two arguments.
+func Func2[A, B comparable, R any](cache *Cache[R], f func(ctx context.Context, a A, b B) (*R, error)) func(ctx context.Context, a A, b B) (*R, error) {
+	return func(ctx context.Context, a A, b B) (*R, error) {
+		return callCached(ctx, cache, argsKey(a, b), func(ctx context.Context) (*R, error) {
+			return f(ctx, a, b)
+		})
+	}
+}
+
+// Func3 is like `Func1`, for functions with three arguments.
+func Func3[A, B, C comparable, R any](cache *Cache[R], f func(ctx context.Context, a A, b B, c C) (*R, error)) func(ctx context.Context, a A, b B, c C) (*R, error) {
+	return func(ctx context.Context, a A, b B, c C) (*R, error) {
+		return callCached(ctx, cache, argsKey(a, b, c), func(ctx context.Context) (*R, error) {
+			return f(ctx, a, b, c)
+		})
+	}
+}
+
+// callCached gets the key from the cache, computing it with f if needed.
+func callCached[R any](ctx context.Context, cache *Cache[R], key string, f func(ctx context.Context) (*R, error)) (*R, error) {
+	result, err := cache.Get(ctx, key, func(ctx context.Context, key string) (*FetchResult[R], error) {
+		data, err := f(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		return &FetchResult[R]{
+			Data: data,
+		}, nil
+	})
+
+	return result.Data, err
+}
+
+// argsKey builds a cache key from function arguments. Every argument is written with its dynamic type,
+// so values of different types with the same representation, e.g. `1` and `int64(1)` passed as interfaces,
+// don't collide. Parts are prefixed with their length, so separators inside them can't cause collisions either.
+func argsKey(args ...any) string {
+	var sb strings.Builder
+	for _, arg := range args {
+		part := fmt.Sprintf("%T:%#v", arg, arg)
+		sb.WriteString(strconv.Itoa(len(part)))
+		sb.WriteByte(':')
+		sb.WriteString(part)
+	}
+
+	return sb.String()
+}
+
+// memoizeBackend is an in-memory backend holding a single entry.
+type memoizeBackend[T any] struct {
+	mu    sync.Mutex
+	entry *CacheEntry[T]
+}
+
+func (b *memoizeBackend[T]) Get(ctx context.Context, key string) (*CacheEntry[T], error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.entry, nil
+}
+
+func (b *memoizeBackend[T]) Set(ctx context.Context, key string, ttl time.Duration, data *CacheEntry[T]) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.entry = data
+
+	return nil
+}
+
+func (b *memoizeBackend[T]) Delete(ctx context.Context, key string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.entry = nil
+
+	return nil
+}
+
+func (b *memoizeBackend[T]) Close() {}
@@ -0,0 +1,25 @@
+package smartcache
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArgsKey(t *testing.T) {
+	t.Parallel()
+
+	// Arguments passed as interfaces keep their dynamic types.
+	assert.NotEqual(t, argsKey(1), argsKey(int64(1)))
+	assert.NotEqual(t, argsKey(1, "a"), argsKey(uint8(1), "a"))
+	assert.Equal(t, argsKey(1, "a"), argsKey(1, "a"))
+
+	// Separators inside the parts don't collide.
+	assert.NotEqual(t, argsKey(rawString("a|b"), rawString("c")), argsKey(rawString("a"), rawString("b|c")))
+	assert.NotEqual(t, argsKey(rawString("1:a"), rawString("b")), argsKey(rawString("1"), rawString("a:b")))
+}
+
+// rawString is represented as its raw value by %#v.
+type rawString string
+
+func (s rawString) GoString() string { return string(s) }
@@ -0,0 +1,185 @@
+package smartcache_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/m-zajac/smartcache"
+	"github.com/m-zajac/smartcache/backend/lru"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoize(t *testing.T) {
+	t.Parallel()
+
+	// Concurrent calls should be coalesced into a single compute call.
+	t.Run("dedup", func(t *testing.T) {
+		ready := make(chan struct{})
+		var calls atomic.Int32
+		memoized := smartcache.Memoize(time.Minute, time.Hour, func(ctx context.Context) (*int, error) {
+			<-ready
+			v := int(calls.Add(1))
+			return &v, nil
+		})
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				v, err := memoized(context.Background())
+				assert.NoError(t, err)
+				assert.Equal(t, 1, *v)
+			}()
+		}
+		time.Sleep(100 * time.Millisecond)
+		close(ready)
+		wg.Wait()
+
+		assert.EqualValues(t, 1, calls.Load())
+	})
+
+	// After primary TTL the stale value should be returned and refreshed in the background.
+	t.Run("ttl", func(t *testing.T) {
+		const primTTL = 100 * time.Millisecond
+		const secTTL = 300 * time.Millisecond
+
+		var calls atomic.Int32
+		memoized := smartcache.Memoize(primTTL, secTTL, func(ctx context.Context) (*int, error) {
+			v := int(calls.Add(1))
+			return &v, nil
+		})
+		ctx := context.Background()
+
+		v, err := memoized(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, *v)
+
+		time.Sleep(primTTL + 10*time.Millisecond)
+		v, err = memoized(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, *v)
+
+		// Wait for the background refresh.
+		assert.Eventually(t, func() bool { return calls.Load() == 2 }, time.Second, 10*time.Millisecond)
+		v, err = memoized(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 2, *v)
+	})
+
+	t.Run("invalid ttl", func(t *testing.T) {
+		memoized := smartcache.Memoize(time.Hour, time.Minute, func(ctx context.Context) (*int, error) {
+			t.Fatal("unexpected compute call")
+			return nil, nil
+		})
+
+		_, err := memoized(context.Background())
+		assert.Error(t, err)
+	})
+}
+
+func TestFunc(t *testing.T) {
+	t.Parallel()
+
+	newCache := func(t *testing.T) *smartcache.Cache[string] {
+		backend, err := lru.NewBackend[string](100)
+		require.NoError(t, err)
+		cache, err := smartcache.New[string](backend)
+		require.NoError(t, err)
+		t.Cleanup(cache.Close)
+
+		return cache
+	}
+	ctx := context.Background()
+
+	t.Run("Func1", func(t *testing.T) {
+		var calls atomic.Int32
+		f := smartcache.Func1(newCache(t), func(ctx context.Context, a int) (*string, error) {
+			calls.Add(1)
+			v := fmt.Sprint(a)
+			return &v, nil
+		})
+
+		for i := 0; i < 3; i++ {
+			v, err := f(ctx, 1)
+			require.NoError(t, err)
+			assert.Equal(t, "1", *v)
+		}
+		assert.EqualValues(t, 1, calls.Load())
+
+		v, err := f(ctx, 2)
+		require.NoError(t, err)
+		assert.Equal(t, "2", *v)
+		assert.EqualValues(t, 2, calls.Load())
+	})
+
+	t.Run("Func2", func(t *testing.T) {
+		var calls atomic.Int32
+		f := smartcache.Func2(newCache(t), func(ctx context.Context, a, b string) (*string, error) {
+			calls.Add(1)
+			v := a + "+" + b
+			return &v, nil
+		})
+
+		for i := 0; i < 3; i++ {
+			v, err := f(ctx, "a|b", "c")
+			require.NoError(t, err)
+			assert.Equal(t, "a|b+c", *v)
+		}
+		assert.EqualValues(t, 1, calls.Load())
+
+		// Separator in the arguments doesn't collide with the first call.
+		v, err := f(ctx, "a", "b|c")
+		require.NoError(t, err)
+		assert.Equal(t, "a+b|c", *v)
+		assert.EqualValues(t, 2, calls.Load())
+	})
+
+	t.Run("Func3", func(t *testing.T) {
+		var calls atomic.Int32
+		f := smartcache.Func3(newCache(t), func(ctx context.Context, a string, b int, c bool) (*string, error) {
+			calls.Add(1)
+			v := fmt.Sprint(a, b, c)
+			return &v, nil
+		})
+
+		for i := 0; i < 3; i++ {
+			v, err := f(ctx, "x", 1, true)
+			require.NoError(t, err)
+			assert.Equal(t, "x1 true", *v)
+		}
+		assert.EqualValues(t, 1, calls.Load())
+
+		v, err := f(ctx, "x", 1, false)
+		require.NoError(t, err)
+		assert.Equal(t, "x1 false", *v)
+		assert.EqualValues(t, 2, calls.Load())
+	})
+
+	t.Run("separators", func(t *testing.T) {
+		var calls atomic.Int32
+		f := smartcache.Func2(newCache(t), func(ctx context.Context, a, b goString) (*string, error) {
+			calls.Add(1)
+			v := string(a) + "+" + string(b)
+			return &v, nil
+		})
+
+		// The arguments' representations aren't quoted, so they contain the separators.
+		for _, args := range [][2]goString{{"a|b", "c"}, {"a", "b|c"}, {"a:1", "c"}, {"a", "1:c"}} {
+			v, err := f(ctx, args[0], args[1])
+			require.NoError(t, err)
+			assert.Equal(t, string(args[0])+"+"+string(args[1]), *v)
+		}
+		assert.EqualValues(t, 4, calls.Load())
+	})
+}
+
+// goString is represented as its raw value by %#v.
+type goString string
+
+func (s goString) GoString() string { return string(s) }
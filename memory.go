@@ -0,0 +1,70 @@
+package smartcache
+
+import (
+	"context"
+	"runtime"
+	"time"
+)
+
+// PurgingBackend is an optional interface of in-memory backends, which can remove expired entries on demand
+// to free memory. See `Cache.PurgeExpired`.
+type PurgingBackend[T any] interface {
+	// PurgeExpired removes entries for which the expired function returns true, and returns their number.
+	PurgeExpired(ctx context.Context, expired func(key string, entry *CacheEntry[T]) bool) (int, error)
+}
+
+// Purger is the part of the cache available to memory pressure handlers.
+type Purger interface {
+	PurgeExpired(ctx context.Context) (int, error)
+}
+
+// MemoryPressureHandler is called when the heap usage exceeds the threshold, see `WithMemoryPressureHandler`.
+type MemoryPressureHandler func(ctx context.Context, cache Purger, heapUsage uint64)
+
+// defaultHeapSampleInterval is the interval of checking the heap usage with the default source.
+const defaultHeapSampleInterval = 5 * time.Second
+
+// heapAlloc returns the bytes of allocated heap objects.
+func heapAlloc() uint64 {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+
+	return ms.HeapAlloc
+}
+
+// purgeOnPressure is the default memory pressure handler, which purges expired entries.
+func purgeOnPressure(ctx context.Context, cache Purger, heapUsage uint64) {
+	_, _ = cache.PurgeExpired(ctx)
+}
+
+// PurgeExpired removes entries, which would be misses for Get, from the backend and returns their number.
+// If the backend doesn't implement `PurgingBackend`, `ErrNotSupported` is returned.
+func (sc *Cache[T]) PurgeExpired(ctx context.Context) (int, error) {
+	pb, ok := sc.backend.(PurgingBackend[T])
+	if !ok {
+		return 0, ErrNotSupported
+	}
+
+	return pb.PurgeExpired(ctx, func(key string, entry *CacheEntry[T]) bool {
+		return sc.classify(key, entry) == Miss
+	})
+}
+
+// runHeapSampler checks the heap usage periodically and calls the memory pressure handler when it's too high.
+func (sc *Cache[T]) runHeapSampler() {
+	defer sc.wg.Done()
+
+	ticker := time.NewTicker(sc.config.heapSampleInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-sc.ctx.Done():
+			return
+		case <-ticker.C:
+			if usage := sc.config.heapUsage(); usage > sc.config.memoryThreshold {
+				sc.config.memoryPressureHandler(sc.ctx, sc, usage)
+			}
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package smartcache_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/m-zajac/smartcache"
+	"github.com/m-zajac/smartcache/backend/lru"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache_MemoryPressureHandler(t *testing.T) {
+	t.Parallel()
+
+	backend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+
+	_, err = smartcache.New[string](backend, smartcache.WithMemoryPressureHandler(0, nil))
+	require.Error(t, err)
+	_, err = smartcache.New[string](backend, smartcache.WithHeapUsageSource(nil, time.Second))
+	require.Error(t, err)
+	_, err = smartcache.New[string](backend, smartcache.WithHeapUsageSource(func() uint64 { return 0 }, 0))
+	require.Error(t, err)
+
+	const threshold = 1000
+	var usage atomic.Uint64
+	usage.Store(threshold)
+	var calls atomic.Int32
+	var lastUsage atomic.Uint64
+	cache, err := smartcache.New[string](
+		backend,
+		smartcache.WithMemoryPressureHandler(threshold, func(ctx context.Context, cache smartcache.Purger, heapUsage uint64) {
+			calls.Add(1)
+			lastUsage.Store(heapUsage)
+		}),
+		smartcache.WithHeapUsageSource(usage.Load, time.Millisecond),
+	)
+	require.NoError(t, err)
+
+	// At the threshold, the handler isn't called.
+	time.Sleep(20 * time.Millisecond)
+	assert.Zero(t, calls.Load())
+
+	usage.Store(threshold + 1)
+	assert.Eventually(t, func() bool { return calls.Load() > 0 }, time.Second, time.Millisecond)
+	assert.EqualValues(t, threshold+1, lastUsage.Load())
+
+	// Closing the cache stops the sampler.
+	cache.Close()
+	stopped := calls.Load()
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, stopped, calls.Load())
+}
+
+func TestCache_MemoryPressurePurgesExpired(t *testing.T) {
+	t.Parallel()
+
+	backend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+
+	const primTTL = time.Minute
+	const secTTL = time.Hour
+	cache, err := smartcache.New[string](
+		backend,
+		smartcache.WithTTL(primTTL, secTTL),
+		smartcache.WithMemoryPressureHandler(1, nil),
+		smartcache.WithHeapUsageSource(func() uint64 { return 2 }, time.Millisecond),
+	)
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	ctx := context.Background()
+	data := "data"
+	ages := map[string]time.Duration{
+		"hot":     0,
+		"warm":    primTTL + time.Second,
+		"expired": secTTL + time.Second,
+	}
+	for key, age := range ages {
+		require.NoError(t, backend.Set(ctx, key, secTTL, &smartcache.CacheEntry[string]{
+			Data:    &data,
+			Created: time.Now().Add(-age),
+		}))
+	}
+
+	assert.Eventually(t, func() bool {
+		entry, err := backend.Get(ctx, "expired")
+		return err == nil && entry == nil
+	}, time.Second, time.Millisecond)
+	for _, key := range []string{"hot", "warm"} {
+		entry, err := backend.Get(ctx, key)
+		require.NoError(t, err)
+		assert.NotNil(t, entry, key)
+	}
+}
+
+func TestCache_PurgeExpiredNotSupported(t *testing.T) {
+	t.Parallel()
+
+	inner, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+	// Embedding the interface hides the PurgeExpired method of the lru backend.
+	backend := struct{ smartcache.Backend[string] }{inner}
+	cache, err := smartcache.New[string](backend)
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	_, err = cache.PurgeExpired(context.Background())
+	assert.ErrorIs(t, err, smartcache.ErrNotSupported)
+}
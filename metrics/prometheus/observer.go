@@ -0,0 +1,89 @@
+// Package prometheus provides a smartcache.Observer exporting cache metrics to Prometheus.
+package prometheus
+
+import (
+	"time"
+
+	"github.com/m-zajac/smartcache"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Observer collects cache metrics. It implements `smartcache.Observer` and `prometheus.Collector`,
+// so it has to be registered in a Prometheus registry, e.g. with `prometheus.MustRegister`.
+type Observer struct {
+	gets      *prometheus.HistogramVec
+	fetches   *prometheus.HistogramVec
+	refreshes *prometheus.CounterVec
+}
+
+var (
+	_ smartcache.Observer  = &Observer{}
+	_ prometheus.Collector = &Observer{}
+)
+
+// NewObserver creates an observer with metrics named in the given namespace, e.g. "myapp_users_cache".
+// Constant labels, like the cache name, can be added to all the metrics.
+func NewObserver(namespace string, constLabels prometheus.Labels) *Observer {
+	return &Observer{
+		gets: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   namespace,
+			Name:        "get_duration_seconds",
+			Help:        "Duration of cache Get calls by the result type.",
+			ConstLabels: constLabels,
+			Buckets:     prometheus.ExponentialBuckets(0.00001, 4, 10),
+		}, []string{"result"}),
+		fetches: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   namespace,
+			Name:        "fetch_duration_seconds",
+			Help:        "Duration of fetch function calls by the origin (foreground or background) and the status.",
+			ConstLabels: constLabels,
+			Buckets:     prometheus.DefBuckets,
+		}, []string{"origin", "status"}),
+		refreshes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Name:        "background_refreshes_total",
+			Help:        "Number of finished background refreshes by the status.",
+			ConstLabels: constLabels,
+		}, []string{"status"}),
+	}
+}
+
+func (o *Observer) ObserveGet(resultType smartcache.ResultType, d time.Duration) {
+	o.gets.WithLabelValues(resultType.String()).Observe(d.Seconds())
+}
+
+func (o *Observer) ObserveFetch(d time.Duration, err error, background bool) {
+	o.fetches.WithLabelValues(origin(background), status(err)).Observe(d.Seconds())
+}
+
+func (o *Observer) ObserveBackgroundRefresh(err error) {
+	o.refreshes.WithLabelValues(status(err)).Inc()
+}
+
+func (o *Observer) Describe(ch chan<- *prometheus.Desc) {
+	o.gets.Describe(ch)
+	o.fetches.Describe(ch)
+	o.refreshes.Describe(ch)
+}
+
+func (o *Observer) Collect(ch chan<- prometheus.Metric) {
+	o.gets.Collect(ch)
+	o.fetches.Collect(ch)
+	o.refreshes.Collect(ch)
+}
+
+func status(err error) string {
+	if err != nil {
+		return "error"
+	}
+
+	return "ok"
+}
+
+func origin(background bool) string {
+	if background {
+		return "background"
+	}
+
+	return "foreground"
+}
@@ -0,0 +1,86 @@
+package prometheus_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/m-zajac/smartcache"
+	"github.com/m-zajac/smartcache/backend/lru"
+	smartprometheus "github.com/m-zajac/smartcache/metrics/prometheus"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObserver(t *testing.T) {
+	t.Parallel()
+
+	obs := smartprometheus.NewObserver("test_cache", prometheus.Labels{"cache": "users"})
+	reg := prometheus.NewPedanticRegistry()
+	require.NoError(t, reg.Register(obs))
+
+	backend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+	cache, err := smartcache.New[string](backend, smartcache.WithMetricsObserver(obs))
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		if key == "bad" {
+			return nil, errors.New("fetch failed")
+		}
+		data := "data"
+		return &smartcache.FetchResult[string]{Data: &data}, nil
+	}
+
+	ctx := context.Background()
+	for _, key := range []string{"key", "key", "key", "bad"} {
+		_, _ = cache.Get(ctx, key, fetchFunc)
+	}
+
+	count, err := testutil.GatherAndCount(reg, "test_cache_get_duration_seconds", "test_cache_fetch_duration_seconds")
+	require.NoError(t, err)
+	assert.Equal(t, 4, count, "series: get Miss and HotHit, fetch ok and error")
+
+	err = testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP test_cache_background_refreshes_total Number of finished background refreshes by the status.
+# TYPE test_cache_background_refreshes_total counter
+`), "test_cache_background_refreshes_total")
+	assert.NoError(t, err)
+
+	obs.ObserveBackgroundRefresh(nil)
+	obs.ObserveBackgroundRefresh(errors.New("refresh failed"))
+	err = testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP test_cache_background_refreshes_total Number of finished background refreshes by the status.
+# TYPE test_cache_background_refreshes_total counter
+test_cache_background_refreshes_total{cache="users",status="error"} 1
+test_cache_background_refreshes_total{cache="users",status="ok"} 1
+`), "test_cache_background_refreshes_total")
+	assert.NoError(t, err)
+
+	obs.ObserveFetch(time.Second, nil, true)
+	count, err = testutil.GatherAndCount(reg, "test_cache_fetch_duration_seconds")
+	require.NoError(t, err)
+	assert.Equal(t, 3, count, "series: foreground ok and error, background ok")
+
+	var hits uint64
+	metrics, err := reg.Gather()
+	require.NoError(t, err)
+	for _, mf := range metrics {
+		if mf.GetName() != "test_cache_get_duration_seconds" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "result" && l.GetValue() == "HotHit" {
+					hits = m.GetHistogram().GetSampleCount()
+				}
+			}
+		}
+	}
+	assert.EqualValues(t, 2, hits)
+}
@@ -0,0 +1,76 @@
+package smartcache
+
+import (
+	"container/list"
+	"sync"
+)
+
+// mirror is a bounded in-memory LRU of the last entries seen per key.
+type mirror[T any] struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List
+	items map[string]*list.Element
+}
+
+type mirrorItem[T any] struct {
+	key   string
+	entry *CacheEntry[T]
+}
+
+func newMirror[T any](size int) *mirror[T] {
+	return &mirror[T]{
+		size:  size,
+		order: list.New(),
+		items: make(map[string]*list.Element, size),
+	}
+}
+
+func (m *mirror[T]) add(key string, entry *CacheEntry[T]) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if el, ok := m.items[key]; ok {
+		el.Value.(*mirrorItem[T]).entry = entry
+		m.order.MoveToFront(el)
+		return
+	}
+
+	m.items[key] = m.order.PushFront(&mirrorItem[T]{key: key, entry: entry})
+	if m.order.Len() > m.size {
+		oldest := m.order.Back()
+		m.order.Remove(oldest)
+		delete(m.items, oldest.Value.(*mirrorItem[T]).key)
+	}
+}
+
+func (m *mirror[T]) remove(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if el, ok := m.items[key]; ok {
+		m.order.Remove(el)
+		delete(m.items, key)
+	}
+}
+
+func (m *mirror[T]) get(key string) *CacheEntry[T] {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	el, ok := m.items[key]
+	if !ok {
+		return nil
+	}
+	m.order.MoveToFront(el)
+
+	return el.Value.(*mirrorItem[T]).entry
+}
+
+func (m *mirror[T]) clear() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.order.Init()
+	m.items = make(map[string]*list.Element, m.size)
+}
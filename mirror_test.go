@@ -0,0 +1,24 @@
+package smartcache
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMirror(t *testing.T) {
+	m := newMirror[int](2)
+
+	e1 := &CacheEntry[int]{}
+	e2 := &CacheEntry[int]{}
+	e3 := &CacheEntry[int]{}
+
+	m.add("1", e1)
+	m.add("2", e2)
+	assert.Same(t, e1, m.get("1")) // "1" is now the most recently used.
+
+	m.add("3", e3) // Evicts "2".
+	assert.Same(t, e1, m.get("1"))
+	assert.Nil(t, m.get("2"))
+	assert.Same(t, e3, m.get("3"))
+}
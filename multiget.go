@@ -0,0 +1,345 @@
+package smartcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// BatchFetchFunc fetches data for many keys at once.
+// Keys absent from the returned map are treated as not found: they're not cached and their results have no data.
+// To report failures of some of the keys only, return the data of the other keys along with a `KeyErrors` error.
+// Any other error fails all the keys.
+type BatchFetchFunc[T any] func(ctx context.Context, keys []string) (map[string]*FetchResult[T], error)
+
+// KeyErrors maps keys to errors of fetching them. Returned by a `BatchFetchFunc`, it fails only the listed keys.
+type KeyErrors map[string]error
+
+func (e KeyErrors) Error() string {
+	keys := make([]string, 0, len(e))
+	for key := range e {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	msgs := make([]string, len(keys))
+	for i, key := range keys {
+		msgs[i] = fmt.Sprintf("key '%s': %v", key, e[key])
+	}
+
+	return fmt.Sprintf("fetching %d keys failed: %s", len(keys), strings.Join(msgs, "; "))
+}
+
+// GetMulti returns data for many keys, following the same semantics as Get for each of them.
+// Entries are read from the backend with a single call if it implements `MultiGetBackend`,
+// and all missing or expired keys are fetched with a single fetchFunc call. Warm keys are refreshed in the background,
+// one key per fetchFunc call.
+//
+// Every requested key ends up either in the results or in the errors map, including keys with cached errors
+// (see `WithErrorTTLFunc`). Failing keys don't affect the results of the others, unless the failure is shared,
+// like a cancelled context or a failed backend read.
+//
+// The context is passed to fetchFunc, which should return promptly when it's cancelled. Data it returns along
+// with the cancellation error is still used for the results, the other keys fail with the cancellation error.
+func (sc *Cache[T]) GetMulti(ctx context.Context, keys []string, fetchFunc BatchFetchFunc[T]) (map[string]Result[T], map[string]error) {
+	results := make(map[string]Result[T], len(keys))
+	errs := make(map[string]error)
+
+	keys = uniqueKeys(keys)
+	if err := ctx.Err(); err != nil {
+		failKeys(errs, keys, err)
+		return results, errs
+	}
+	valid := keys[:0:0]
+	for _, key := range keys {
+		if err := sc.validateKey(key); err != nil {
+			errs[key] = err
+			continue
+		}
+		valid = append(valid, key)
+	}
+
+	entries, err := sc.getEntries(ctx, valid)
+	if err != nil {
+		failKeys(errs, valid, err)
+		return results, errs
+	}
+
+	// Hot and warm keys follow the Get path, including background refreshes.
+	var missing []string
+	singleFetch := singleKeyFetch(fetchFunc)
+	for _, key := range valid {
+		entry := entries[key]
+		if sc.classify(key, entry) == Miss {
+			missing = append(missing, key)
+			continue
+		}
+
+		result, err := sc.get(ctx, key, singleFetch, getOptions[T]{entry: entry, hasEntry: true})
+		if err != nil {
+			errs[key] = err
+			continue
+		}
+		results[key] = result
+	}
+	if len(missing) > 0 {
+		sc.fetchMulti(ctx, missing, fetchFunc, results, errs)
+	}
+
+	return results, errs
+}
+
+// fetchMulti fetches missing keys with a single fetchFunc call, stores them and puts their results into results,
+// or their errors into errs.
+func (sc *Cache[T]) fetchMulti(ctx context.Context, keys []string, fetchFunc BatchFetchFunc[T], results map[string]Result[T], errs map[string]error) {
+	start := time.Now()
+	if err := sc.enter(); err != nil {
+		failKeys(errs, keys, err)
+		return
+	}
+	defer sc.wg.Done()
+
+	// Locks are always taken in the same order, so concurrent calls can't deadlock.
+	sort.Strings(keys)
+	reqs := make([]*request, len(keys))
+	for i, key := range keys {
+		reqs[i], _ = sc.acquire(key)
+	}
+	defer func() {
+		for i, key := range keys {
+			sc.release(key, reqs[i])
+		}
+	}()
+	if err := ctx.Err(); err != nil {
+		failKeys(errs, keys, err)
+		return
+	}
+
+	// Other calls could have fetched some of the keys while this one was waiting for the locks.
+	entries, err := sc.getEntries(ctx, keys)
+	if err != nil {
+		failKeys(errs, keys, err)
+		return
+	}
+	var toFetch []string
+	var toFetchReqs []*request
+	for i, key := range keys {
+		entry := entries[key]
+		if resultType := sc.classify(key, entry); resultType != Miss {
+			sc.stats.coalescedFollower.Add(1)
+			result := Result[T]{Data: entry.Data, Type: resultType, Age: sc.now().Sub(entry.Created), CreatedAt: entry.Created}
+			sc.observeGet(key, &result, start)
+			if entry.Err != nil {
+				errs[key] = cachedErr(entry.Err)
+				continue
+			}
+			results[key] = result
+			continue
+		}
+		toFetch = append(toFetch, key)
+		toFetchReqs = append(toFetchReqs, reqs[i])
+	}
+	if limit := sc.config.maxInFlightKeys; limit > 0 {
+		// Keys over the limit are shed, like in Get.
+		reserved := toFetch[:0]
+		reservedReqs := toFetchReqs[:0]
+		for i, key := range toFetch {
+			if sc.inFlight.Add(1) > int64(limit) {
+				sc.inFlight.Add(-1)
+				errs[key] = ErrTooManyInFlight
+				continue
+			}
+			reserved = append(reserved, key)
+			reservedReqs = append(reservedReqs, toFetchReqs[i])
+		}
+		toFetch, toFetchReqs = reserved, reservedReqs
+		defer sc.inFlight.Add(-int64(len(toFetch)))
+	}
+	if len(toFetch) == 0 {
+		return
+	}
+
+	failed := true // Unless the fetch succeeds, at least for some of the keys.
+	if b := sc.breaker; b != nil {
+		if !b.allow(sc.now()) {
+			failKeys(errs, toFetch, ErrCircuitOpen)
+			return
+		}
+		defer func() {
+			if ctx.Err() != nil {
+				// Cancellations and deadlines of the caller are not upstream failures.
+				b.abandon()
+				return
+			}
+			b.done(sc.now(), failed)
+		}()
+	}
+
+	if l := sc.config.fetchLimiter; l != nil {
+		if err := l.Acquire(ctx); err != nil {
+			failKeys(errs, toFetch, fmt.Errorf("waiting for fetch limiter: %w", err))
+			return
+		}
+		defer l.Release()
+	}
+
+	fetchCtx, cancel := sc.newForegroundContext(ctx)
+	defer cancel()
+
+	epoch := sc.epoch.Load()
+	fetchCtx, endSpan := sc.startFetchSpan(fetchCtx, SpanFetch, "")
+	fetchStart := time.Now()
+	fetched, err := callBatchFetch(fetchCtx, toFetch, fetchFunc)
+	sc.observeFetch(fetchStart, err, false)
+	endSpan(err)
+	// If the call was cancelled, keys fetched so far are still returned, but not stored, so the call doesn't wait
+	// for the backend. The other keys fail with the cancellation error.
+	cancelErr := ctx.Err()
+	var keyErrs KeyErrors
+	failed = err != nil && !errors.As(err, &keyErrs)
+	if failed && cancelErr == nil {
+		failKeys(errs, toFetch, err)
+		return
+	}
+
+	for i, key := range toFetch {
+		result := Result[T]{Type: Miss}
+		data, found := fetched[key]
+		keyErr := keyErrs[key]
+		if keyErr == nil && (!found || data == nil) {
+			if cancelErr != nil {
+				errs[key] = cancelErr
+				continue
+			}
+			results[key] = result
+			sc.observeGet(key, &result, start)
+			continue
+		}
+
+		item, fetchedType, err := sc.toCacheEntry(ctx, key, data, keyErr, epoch)
+		if err == nil && cancelErr == nil {
+			if err = sc.setEntry(ctx, key, sc.backendTTL(key, item), item); err != nil {
+				err = fmt.Errorf("failed to update cache for key '%s': %w", key, err)
+			} else {
+				sc.storedFetch(key, toFetchReqs[i], item)
+			}
+		}
+		if err == nil {
+			err = item.Err
+		}
+
+		if fetchedType != Miss {
+			result.Type = fetchedType
+		}
+		sc.observeGet(key, &result, start)
+		if err != nil {
+			errs[key] = err
+			continue
+		}
+		result.Data = item.Data
+		result.CreatedAt = item.Created
+		results[key] = result
+	}
+}
+
+// failKeys sets the error for all the keys.
+func failKeys(errs map[string]error, keys []string, err error) {
+	for _, key := range keys {
+		errs[key] = err
+	}
+}
+
+// getEntries reads entries for the keys from the backend, with a single call if the backend supports it.
+func (sc *Cache[T]) getEntries(ctx context.Context, keys []string) (map[string]*CacheEntry[T], error) {
+	if mb, ok := sc.backend.(MultiGetBackend[T]); ok {
+		if sc.bloom != nil {
+			keys = sc.maybeStoredKeys(keys)
+			if len(keys) == 0 {
+				return map[string]*CacheEntry[T]{}, nil
+			}
+		}
+		entries, err := mb.GetMulti(ctx, keys)
+		if err != nil {
+			return nil, fmt.Errorf("cache backend failed: %w", err)
+		}
+		for key, entry := range entries {
+			if sc.bloom != nil {
+				sc.bloom.add(key)
+			}
+			entries[key] = sc.clampCreated(entry)
+		}
+
+		return entries, nil
+	}
+
+	entries := make(map[string]*CacheEntry[T], len(keys))
+	for _, key := range keys {
+		entry, _, err := sc.getEntry(ctx, key)
+		if err != nil {
+			return nil, fmt.Errorf("cache backend failed for key '%s': %w", key, err)
+		}
+		if entry != nil {
+			entries[key] = entry
+		}
+	}
+
+	return entries, nil
+}
+
+// maybeStoredKeys returns the keys that may be stored in the backend, according to the bloom filter.
+func (sc *Cache[T]) maybeStoredKeys(keys []string) []string {
+	maybe := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if sc.bloom.mayContain(key) {
+			maybe = append(maybe, key)
+		}
+	}
+
+	return maybe
+}
+
+// callBatchFetch calls the batch fetch function, converting a panic into an error.
+func callBatchFetch[T any](ctx context.Context, keys []string, fetchFunc BatchFetchFunc[T]) (fetched map[string]*FetchResult[T], err error) {
+	defer recoverFetch(&err)
+
+	return fetchFunc(ctx, keys)
+}
+
+// singleKeyFetch adapts the batch fetch function to fetch a single key.
+func singleKeyFetch[T any](fetchFunc BatchFetchFunc[T]) FetchFunc[T] {
+	return func(ctx context.Context, key string) (*FetchResult[T], error) {
+		fetched, err := fetchFunc(ctx, []string{key})
+		var keyErrs KeyErrors
+		if errors.As(err, &keyErrs) {
+			err = keyErrs[key]
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		data, found := fetched[key]
+		if !found || data == nil {
+			return nil, fmt.Errorf("no data fetched for key '%s'", key)
+		}
+
+		return data, nil
+	}
+}
+
+// uniqueKeys returns keys without duplicates, preserving the order.
+func uniqueKeys(keys []string) []string {
+	seen := make(map[string]struct{}, len(keys))
+	unique := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		unique = append(unique, key)
+	}
+
+	return unique
+}
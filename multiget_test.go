@@ -0,0 +1,296 @@
+package smartcache_test
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/m-zajac/smartcache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache_GetMulti(t *testing.T) {
+	t.Parallel()
+
+	backend := newCountingBackend(t)
+	const primTTL = 50 * time.Millisecond
+	cache, err := smartcache.New[string](backend, smartcache.WithTTL(primTTL, time.Hour))
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	var mu sync.Mutex
+	var fetched [][]string
+	fetchFunc := func(ctx context.Context, keys []string) (map[string]*smartcache.FetchResult[string], error) {
+		mu.Lock()
+		fetched = append(fetched, append([]string(nil), keys...))
+		mu.Unlock()
+
+		results := make(map[string]*smartcache.FetchResult[string], len(keys))
+		for _, key := range keys {
+			if key == "unknown" {
+				continue
+			}
+			v := "value " + key
+			results[key] = &smartcache.FetchResult[string]{Data: &v}
+		}
+		return results, nil
+	}
+	fetchedKeys := func() [][]string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([][]string(nil), fetched...)
+	}
+
+	ctx := context.Background()
+
+	// Prepare a warm key.
+	_, errs := cache.GetMulti(ctx, []string{"warm"}, fetchFunc)
+	require.Empty(t, errs)
+	time.Sleep(primTTL + 10*time.Millisecond)
+
+	// Prepare a hot key.
+	_, errs = cache.GetMulti(ctx, []string{"hot"}, fetchFunc)
+	require.Empty(t, errs)
+	require.Len(t, fetchedKeys(), 2)
+	backend.multiGets.Store(0)
+
+	results, errs := cache.GetMulti(ctx, []string{"b", "hot", "warm", "a", "unknown", "a"}, fetchFunc)
+	require.Empty(t, errs)
+	require.Len(t, results, 5)
+
+	assert.Equal(t, smartcache.HotHit, results["hot"].Type)
+	assert.Equal(t, "value hot", *results["hot"].Data)
+	assert.Equal(t, smartcache.WarmHit, results["warm"].Type)
+	assert.Equal(t, "value warm", *results["warm"].Data)
+	for _, key := range []string{"a", "b"} {
+		assert.Equal(t, smartcache.Miss, results[key].Type)
+		assert.Equal(t, "value "+key, *results[key].Data)
+	}
+	assert.Equal(t, smartcache.Miss, results["unknown"].Type)
+	assert.Nil(t, results["unknown"].Data)
+
+	// Missing keys are fetched with a single call, the warm key is refreshed in the background.
+	assert.Eventually(t, func() bool { return len(fetchedKeys()) == 4 }, time.Second, 5*time.Millisecond)
+	calls := fetchedKeys()[2:]
+	sort.Slice(calls, func(i, j int) bool { return len(calls[i]) < len(calls[j]) })
+	assert.Equal(t, []string{"warm"}, calls[0])
+	assert.Equal(t, []string{"a", "b", "unknown"}, calls[1])
+
+	// Backend reads: one before and one after taking the locks of missing keys.
+	assert.EqualValues(t, 2, backend.multiGets.Load())
+	assert.Zero(t, backend.gets.Load())
+
+	// Fetched keys are cached.
+	results, errs = cache.GetMulti(ctx, []string{"a", "b"}, fetchFunc)
+	require.Empty(t, errs)
+	assert.Equal(t, smartcache.HotHit, results["a"].Type)
+	assert.Equal(t, smartcache.HotHit, results["b"].Type)
+	assert.Len(t, fetchedKeys(), 4)
+}
+
+func TestCache_GetMultiCoalescing(t *testing.T) {
+	t.Parallel()
+
+	backend := newCountingBackend(t)
+	cache, err := smartcache.New[string](backend)
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	var fetches atomic.Int32
+	fetchFunc := func(ctx context.Context, keys []string) (map[string]*smartcache.FetchResult[string], error) {
+		fetches.Add(int32(len(keys)))
+		time.Sleep(20 * time.Millisecond)
+
+		results := make(map[string]*smartcache.FetchResult[string], len(keys))
+		for _, key := range keys {
+			v := "value " + key
+			results[key] = &smartcache.FetchResult[string]{Data: &v}
+		}
+		return results, nil
+	}
+
+	// Overlapping concurrent calls fetch every key once.
+	var wg sync.WaitGroup
+	for _, keys := range [][]string{{"a", "b", "c"}, {"c", "b"}, {"b", "d"}} {
+		wg.Add(1)
+		go func(keys []string) {
+			defer wg.Done()
+			results, errs := cache.GetMulti(context.Background(), keys, fetchFunc)
+			assert.Empty(t, errs)
+			for _, key := range keys {
+				assert.Equal(t, "value "+key, *results[key].Data)
+			}
+		}(keys)
+	}
+	wg.Wait()
+
+	assert.EqualValues(t, 4, fetches.Load())
+}
+
+func TestCache_GetMultiPartialFailure(t *testing.T) {
+	t.Parallel()
+
+	backend := newCountingBackend(t)
+	cache, err := smartcache.New[string](backend)
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	errFailed := errors.New("fetch failed")
+	fetchFunc := func(ctx context.Context, keys []string) (map[string]*smartcache.FetchResult[string], error) {
+		results := make(map[string]*smartcache.FetchResult[string], len(keys))
+		keyErrs := smartcache.KeyErrors{}
+		for _, key := range keys {
+			if strings.HasPrefix(key, "bad") {
+				keyErrs[key] = errFailed
+				continue
+			}
+			v := "value " + key
+			results[key] = &smartcache.FetchResult[string]{Data: &v}
+		}
+		if len(keyErrs) > 0 {
+			return results, keyErrs
+		}
+		return results, nil
+	}
+
+	ctx := context.Background()
+	_, errs := cache.GetMulti(ctx, []string{"hot"}, fetchFunc)
+	require.Empty(t, errs)
+
+	results, errs := cache.GetMulti(ctx, []string{"hot", "good", "bad1", "bad2"}, fetchFunc)
+	assert.Len(t, results, 2)
+	assert.Equal(t, smartcache.HotHit, results["hot"].Type)
+	assert.Equal(t, "value hot", *results["hot"].Data)
+	assert.Equal(t, smartcache.Miss, results["good"].Type)
+	assert.Equal(t, "value good", *results["good"].Data)
+
+	assert.Len(t, errs, 2)
+	assert.ErrorIs(t, errs["bad1"], errFailed)
+	assert.ErrorIs(t, errs["bad2"], errFailed)
+
+	// Failed keys are not cached.
+	results, errs = cache.GetMulti(ctx, []string{"good", "bad1"}, fetchFunc)
+	assert.Equal(t, smartcache.HotHit, results["good"].Type)
+	assert.NotContains(t, results, "bad1")
+	assert.ErrorIs(t, errs["bad1"], errFailed)
+
+	// Other errors fail all the fetched keys, but not the cached ones.
+	errBatch := errors.New("batch failed")
+	results, errs = cache.GetMulti(ctx, []string{"hot", "new1", "new2"}, func(ctx context.Context, keys []string) (map[string]*smartcache.FetchResult[string], error) {
+		return nil, errBatch
+	})
+	assert.Len(t, results, 1)
+	assert.Contains(t, results, "hot")
+	assert.Equal(t, map[string]error{"new1": errBatch, "new2": errBatch}, errs)
+}
+
+func TestCache_GetMultiCancel(t *testing.T) {
+	t.Parallel()
+
+	backend := newCountingBackend(t)
+	cache, err := smartcache.New[string](backend)
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	fetchFunc := func(ctx context.Context, keys []string) (map[string]*smartcache.FetchResult[string], error) {
+		results := make(map[string]*smartcache.FetchResult[string], len(keys))
+		for _, key := range keys {
+			if key == "slow" {
+				// Resolved only after the call is cancelled.
+				<-ctx.Done()
+				return results, ctx.Err()
+			}
+			v := "value " + key
+			results[key] = &smartcache.FetchResult[string]{Data: &v}
+		}
+		return results, nil
+	}
+
+	_, errs := cache.GetMulti(context.Background(), []string{"hot"}, fetchFunc)
+	require.Empty(t, errs)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	start := time.Now()
+	results, errs := cache.GetMulti(ctx, []string{"hot", "a", "slow"}, fetchFunc)
+	assert.Less(t, time.Since(start), time.Second)
+
+	assert.Len(t, results, 2)
+	assert.Equal(t, smartcache.HotHit, results["hot"].Type)
+	assert.Equal(t, smartcache.Miss, results["a"].Type)
+	assert.Equal(t, "value a", *results["a"].Data)
+	assert.Equal(t, map[string]error{"slow": context.Canceled}, errs)
+
+	// Data fetched for the cancelled call is not stored.
+	entry, err := backend.Get(context.Background(), "a")
+	require.NoError(t, err)
+	assert.Nil(t, entry)
+
+	// Calls with a cancelled context fail all keys, even the hot ones.
+	results, errs = cache.GetMulti(ctx, []string{"hot", "a"}, fetchFunc)
+	assert.Empty(t, results)
+	assert.Equal(t, map[string]error{"hot": context.Canceled, "a": context.Canceled}, errs)
+}
+
+func TestCache_GetMultiMaxInFlightKeys(t *testing.T) {
+	t.Parallel()
+
+	backend := newCountingBackend(t)
+	cache, err := smartcache.New[string](backend, smartcache.WithMaxInFlightKeys(2))
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	ctx := context.Background()
+
+	// Occupy one fetch slot.
+	started := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, err := cache.Get(ctx, "blocked", func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+			close(started)
+			<-release
+			v := "value " + key
+			return &smartcache.FetchResult[string]{Data: &v}, nil
+		})
+		assert.NoError(t, err)
+	}()
+	<-started
+
+	var fetched []string
+	fetchFunc := func(ctx context.Context, keys []string) (map[string]*smartcache.FetchResult[string], error) {
+		fetched = append(fetched, keys...)
+		results := make(map[string]*smartcache.FetchResult[string], len(keys))
+		for _, key := range keys {
+			v := "value " + key
+			results[key] = &smartcache.FetchResult[string]{Data: &v}
+		}
+		return results, nil
+	}
+
+	// The batch takes the remaining slot, and the other keys are shed.
+	results, errs := cache.GetMulti(ctx, []string{"c", "b", "a"}, fetchFunc)
+	assert.Equal(t, []string{"a"}, fetched)
+	assert.Len(t, results, 1)
+	assert.Equal(t, "value a", *results["a"].Data)
+	assert.Len(t, errs, 2)
+	assert.ErrorIs(t, errs["b"], smartcache.ErrTooManyInFlight)
+	assert.ErrorIs(t, errs["c"], smartcache.ErrTooManyInFlight)
+
+	// The slots are released after the fetches.
+	close(release)
+	<-done
+	fetched = nil
+	results, errs = cache.GetMulti(ctx, []string{"b", "c"}, fetchFunc)
+	assert.Empty(t, errs)
+	assert.Len(t, results, 2)
+	assert.Equal(t, []string{"b", "c"}, fetched)
+}
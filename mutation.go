@@ -0,0 +1,50 @@
+package smartcache
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sync"
+)
+
+// mutationDetector detects modifications of cached values made by the callers.
+// It remembers a hash of the last value returned for each key, and panics
+// if the same pointer is served again with a different hash.
+type mutationDetector[T any] struct {
+	mu   sync.Mutex
+	seen map[string]servedValue[T]
+}
+
+type servedValue[T any] struct {
+	data *T
+	hash uint64
+}
+
+func newMutationDetector[T any]() *mutationDetector[T] {
+	return &mutationDetector[T]{
+		seen: make(map[string]servedValue[T]),
+	}
+}
+
+func (d *mutationDetector[T]) check(key string, data *T) {
+	if data == nil {
+		return
+	}
+
+	hash := hashValue(data)
+
+	d.mu.Lock()
+	prev, ok := d.seen[key]
+	d.seen[key] = servedValue[T]{data: data, hash: hash}
+	d.mu.Unlock()
+
+	if ok && prev.data == data && prev.hash != hash {
+		panic(fmt.Sprintf("smartcache: cached value for key '%s' was mutated after being returned", key))
+	}
+}
+
+func hashValue[T any](data *T) uint64 {
+	h := fnv.New64a()
+	_, _ = fmt.Fprintf(h, "%#v", *data)
+
+	return h.Sum64()
+}
@@ -0,0 +1,27 @@
+package smartcache
+
+import "context"
+
+// Pair holds two related values cached together under one key, see `GetPair`.
+type Pair[A, B any] struct {
+	First  *A `json:"first" msgpack:"first"`
+	Second *B `json:"second" msgpack:"second"`
+}
+
+// PairFetchFunc fetches two related values for the key, like functions returning `(*A, *B, error)`.
+type PairFetchFunc[A, B any] func(ctx context.Context, key string) (*A, *B, error)
+
+// GetPair works like `Cache.Get` for a cache of pairs, with a fetch function returning both values,
+// so callers don't have to declare a wrapper struct for every pair of cached values.
+func GetPair[A, B any](ctx context.Context, cache *Cache[Pair[A, B]], key string, fetchFunc PairFetchFunc[A, B]) (Result[Pair[A, B]], error) {
+	return cache.get(ctx, key, func(ctx context.Context, key string) (*FetchResult[Pair[A, B]], error) {
+		first, second, err := fetchFunc(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+
+		return &FetchResult[Pair[A, B]]{
+			Data: &Pair[A, B]{First: first, Second: second},
+		}, nil
+	}, getOptions[Pair[A, B]]{checkedFetchFunc: fetchFunc})
+}
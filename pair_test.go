@@ -0,0 +1,78 @@
+package smartcache_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/m-zajac/smartcache"
+	"github.com/m-zajac/smartcache/backend/lru"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type pairData struct {
+	Name string
+}
+
+type pairMeta struct {
+	Version int
+}
+
+func TestGetPair(t *testing.T) {
+	t.Parallel()
+
+	backend, err := lru.NewBackend[smartcache.Pair[pairData, pairMeta]](100)
+	require.NoError(t, err)
+	cache, err := smartcache.New[smartcache.Pair[pairData, pairMeta]](backend)
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	ctx := context.Background()
+	var fetches int
+	fetchFunc := func(ctx context.Context, key string) (*pairData, *pairMeta, error) {
+		fetches++
+		return &pairData{Name: key}, &pairMeta{Version: 2}, nil
+	}
+
+	wantTypes := []smartcache.ResultType{smartcache.Miss, smartcache.HotHit}
+	for _, want := range wantTypes {
+		result, err := smartcache.GetPair(ctx, cache, "key", fetchFunc)
+		require.NoError(t, err)
+		assert.Equal(t, want, result.Type)
+		assert.Equal(t, &pairData{Name: "key"}, result.Data.First)
+		assert.Equal(t, &pairMeta{Version: 2}, result.Data.Second)
+	}
+	assert.Equal(t, 1, fetches)
+
+	fetchErr := errors.New("fetch failed")
+	_, err = smartcache.GetPair(ctx, cache, "failing", func(ctx context.Context, key string) (*pairData, *pairMeta, error) {
+		return nil, nil, fetchErr
+	})
+	assert.ErrorIs(t, err, fetchErr)
+}
+
+func TestGetPair_FetchFuncConsistencyCheck(t *testing.T) {
+	t.Parallel()
+
+	backend, err := lru.NewBackend[smartcache.Pair[pairData, pairMeta]](100)
+	require.NoError(t, err)
+	cache, err := smartcache.New[smartcache.Pair[pairData, pairMeta]](backend, smartcache.WithFetchFuncConsistencyCheck())
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	fetchFunc := func(ctx context.Context, key string) (*pairData, *pairMeta, error) {
+		return &pairData{Name: key}, &pairMeta{Version: 1}, nil
+	}
+	otherFetchFunc := func(ctx context.Context, key string) (*pairData, *pairMeta, error) {
+		return nil, nil, errors.New("unexpected fetch")
+	}
+
+	ctx := context.Background()
+	_, err = smartcache.GetPair(ctx, cache, "key", fetchFunc)
+	require.NoError(t, err)
+
+	assert.Panics(t, func() {
+		_, _ = smartcache.GetPair(ctx, cache, "key", otherFetchFunc)
+	})
+}
@@ -0,0 +1,6 @@
+//go:build race
+
+package smartcache_test
+
+// raceEnabled reports if the race detector is enabled.
+const raceEnabled = true
@@ -0,0 +1,321 @@
+package smartcache
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// refreshTask is a pending background refresh of a key.
+type refreshTask[T any] struct {
+	key       string
+	fetchFunc FetchFunc[T]
+	// current is the entry being refreshed.
+	current *CacheEntry[T]
+	// expiresAt is the time when the refreshed entry expires. Tasks for entries closer to expiration are more urgent.
+	expiresAt time.Time
+	// ctx and gen are the refresh context and generation from the time the refresh was requested.
+	ctx context.Context
+	gen uint64
+	// req is the key's request, kept alive by the task. deletes and writes are its counts when the refresh was requested.
+	req     *request
+	deletes uint64
+	writes  uint64
+	// linked is the context of the request that initiated the refresh, kept only for tracing.
+	linked context.Context
+}
+
+// startRefresh initiates a background refresh of the entry, requested in ctx.
+// It has to be called while owning the shard of the request, which may be held for a coordination key.
+func (sc *Cache[T]) startRefresh(ctx context.Context, req *request, key string, entry *CacheEntry[T], fetchFunc FetchFunc[T]) {
+	req.updatePending = true
+	req.requests++
+
+	if sc.isTraced(key) {
+		sc.config.logger.Printf("smartcache: trace key '%s': background refresh started", key)
+	}
+
+	task := refreshTask[T]{
+		key:       key,
+		fetchFunc: fetchFunc,
+		current:   entry,
+		expiresAt: sc.expiresAt(key, entry),
+		ctx:       sc.refreshCtx,
+		gen:       sc.refreshGen,
+		req:       req,
+		deletes:   req.deletes.Load(),
+		writes:    req.writes.Load(),
+	}
+	if sc.config.tracer != nil {
+		task.linked = ctx
+	}
+
+	if sc.refreshQueue != nil {
+		if dropped, ok := sc.refreshQueue.push(task); ok {
+			sc.stats.refreshesDropped.Add(1)
+			// Finishing the dropped task needs its key's shard, which may be the one owned here.
+			sc.wg.Add(1)
+			go func() {
+				defer sc.wg.Done()
+				sc.finishRefresh(dropped)
+			}()
+		}
+		return
+	}
+
+	sc.wg.Add(1)
+	go func() {
+		defer sc.wg.Done()
+		if sc.refreshSlots != nil {
+			defer func() { <-sc.refreshSlots }()
+		}
+		sc.runRefresh(task)
+	}()
+}
+
+// runRefresh fetches and stores fresh data for the task's key.
+func (sc *Cache[T]) runRefresh(task refreshTask[T]) {
+	key := task.key
+
+	if task.req.writes.Load() != task.writes {
+		// The key was updated after the refresh was requested, there's nothing to refresh.
+		sc.finishRefresh(task)
+		return
+	}
+
+	bkgCtx, cancel := sc.newBackgroundContext(task.ctx)
+	defer cancel()
+
+	var span Span
+	if sc.config.tracer != nil {
+		bkgCtx, span = sc.config.tracer.StartLinked(bkgCtx, SpanRefresh, task.linked)
+		span.SetAttribute(AttributeKey, key)
+	}
+
+	var err error
+	var stored bool
+	item := sc.probeUnchanged(bkgCtx, key, task.current)
+	if item == nil {
+		item, _, err = sc.fetchToCacheEntry(bkgCtx, key, task.fetchFunc, true)
+	}
+	if err != nil {
+		sc.config.backgroundErrorHandler(err)
+	} else if stored, err = sc.storeRefreshed(bkgCtx, task, item); err != nil {
+		sc.config.backgroundErrorHandler(err)
+	} else if stored {
+		sc.setMirrored(key, item)
+		sc.resetStaleServes(key)
+	}
+	sc.setRefreshErr(key, err)
+	sc.stats.refreshes.Add(1)
+	if err != nil {
+		sc.stats.refreshErrors.Add(1)
+	}
+	if sc.config.observer != nil {
+		sc.config.observer.ObserveBackgroundRefresh(err)
+	}
+	if span != nil {
+		if err != nil {
+			span.RecordError(err)
+		}
+		span.End()
+	}
+
+	sc.finishRefresh(task)
+}
+
+// storeRefreshed stores the refreshed entry, unless the key was updated or deleted during the refresh.
+// The key lock is held while storing, so the check doesn't race with `Update` or `Delete`.
+func (sc *Cache[T]) storeRefreshed(ctx context.Context, task refreshTask[T], item *CacheEntry[T]) (stored bool, err error) {
+	<-task.req.lock
+	defer func() { task.req.lock <- struct{}{} }()
+
+	if task.req.writes.Load() != task.writes {
+		// The updated data is newer than the refreshed one.
+		return false, nil
+	}
+	if task.req.deletes.Load() != task.deletes {
+		// The key was deleted during the refresh, the refreshed data can't resurrect it.
+		return false, nil
+	}
+	if err := sc.setEntry(ctx, task.key, sc.backendTTL(task.key, item), item); err != nil {
+		return false, fmt.Errorf("failed to update cache for key '%s': %w", task.key, err)
+	}
+
+	return true, nil
+}
+
+// finishRefresh releases the task's request after the refresh is done or dropped.
+func (sc *Cache[T]) finishRefresh(task refreshTask[T]) {
+	// The request is kept for the key it was acquired for, which may be a coordination key.
+	key := task.req.key
+
+	shard := sc.shard(key)
+	rs := <-shard
+	if sc.refreshGen == task.gen {
+		// Refreshes were not cancelled in the meantime, so the flag still belongs to this refresh.
+		task.req.updatePending = false
+	}
+	removed := sc.done(rs, key)
+	shard <- rs
+
+	// The removed request has no callers, so it can be reused right away.
+	if removed != nil {
+		sc.requestPool.Put(removed)
+	}
+}
+
+// probeUnchanged runs the refresh probe, if configured. It returns the current entry renewed if the data
+// didn't change, or nil if the full fetch is needed.
+func (sc *Cache[T]) probeUnchanged(ctx context.Context, key string, current *CacheEntry[T]) *CacheEntry[T] {
+	if sc.probe == nil || current.Data == nil {
+		return nil
+	}
+
+	changed, err := sc.probe(ctx, key, current.Data)
+	if err != nil {
+		sc.config.backgroundErrorHandler(fmt.Errorf("refresh probe failed for key '%s': %w", key, err))
+		return nil
+	}
+	if changed {
+		return nil
+	}
+
+	renewed := newOKCacheEntry(current.Data, sc.now())
+	renewed.Epoch = current.Epoch
+	renewed.SchemaVersion = current.SchemaVersion
+
+	return renewed
+}
+
+// runRefreshWorker runs queued refreshes until the cache is closed.
+func (sc *Cache[T]) runRefreshWorker() {
+	defer sc.wg.Done()
+
+	for {
+		task, ok := sc.refreshQueue.pop(sc.ctx)
+		if !ok {
+			return
+		}
+		sc.runRefresh(task)
+	}
+}
+
+// expiresAt returns the time when the entry stops being servable.
+func (sc *Cache[T]) expiresAt(key string, entry *CacheEntry[T]) time.Time {
+	if entry.FixedExpiration != nil {
+		return *entry.FixedExpiration
+	}
+
+	_, secondaryTTL := sc.ttls(key, entry)
+
+	return entry.Created.Add(secondaryTTL)
+}
+
+// refreshQueue is a priority queue of pending refreshes, ordered by the expiration time.
+type refreshQueue[T any] struct {
+	mu     sync.Mutex
+	tasks  refreshHeap[T]
+	notify chan struct{}
+	// size is the maximum number of queued tasks, 0 means no limit.
+	size int
+}
+
+func newRefreshQueue[T any](size int) *refreshQueue[T] {
+	return &refreshQueue[T]{
+		notify: make(chan struct{}, 1),
+		size:   size,
+	}
+}
+
+// push adds the task to the queue. If the queue is full, the least urgent task is dropped and returned.
+// It may be the pushed task itself.
+func (q *refreshQueue[T]) push(task refreshTask[T]) (dropped refreshTask[T], isDropped bool) {
+	q.mu.Lock()
+	if q.size > 0 && q.tasks.Len() >= q.size {
+		i := q.tasks.leastUrgent()
+		if !task.expiresAt.Before(q.tasks[i].expiresAt) {
+			q.mu.Unlock()
+			return task, true
+		}
+		dropped, isDropped = q.tasks[i], true
+		q.tasks[i] = task
+		heap.Fix(&q.tasks, i)
+	} else {
+		heap.Push(&q.tasks, task)
+	}
+	q.mu.Unlock()
+
+	q.signal()
+
+	return dropped, isDropped
+}
+
+// pop waits for the most urgent task. It returns false if the context is done.
+func (q *refreshQueue[T]) pop(ctx context.Context) (refreshTask[T], bool) {
+	for {
+		q.mu.Lock()
+		if q.tasks.Len() > 0 {
+			task := heap.Pop(&q.tasks).(refreshTask[T])
+			more := q.tasks.Len() > 0
+			q.mu.Unlock()
+
+			if more {
+				// Wake up another worker.
+				q.signal()
+			}
+
+			return task, true
+		}
+		q.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return refreshTask[T]{}, false
+		case <-q.notify:
+		}
+	}
+}
+
+func (q *refreshQueue[T]) signal() {
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+// refreshHeap implements heap.Interface.
+type refreshHeap[T any] []refreshTask[T]
+
+func (h refreshHeap[T]) Len() int           { return len(h) }
+func (h refreshHeap[T]) Less(i, j int) bool { return h[i].expiresAt.Before(h[j].expiresAt) }
+func (h refreshHeap[T]) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+// leastUrgent returns the index of the task expiring last. The heap can't be empty.
+func (h refreshHeap[T]) leastUrgent() int {
+	// The least urgent task is one of the leaves.
+	last := len(h) / 2
+	for i := last + 1; i < len(h); i++ {
+		if h[i].expiresAt.After(h[last].expiresAt) {
+			last = i
+		}
+	}
+
+	return last
+}
+
+func (h *refreshHeap[T]) Push(x any) {
+	*h = append(*h, x.(refreshTask[T]))
+}
+
+func (h *refreshHeap[T]) Pop() any {
+	old := *h
+	n := len(old)
+	task := old[n-1]
+	old[n-1] = refreshTask[T]{}
+	*h = old[:n-1]
+
+	return task
+}
@@ -0,0 +1,443 @@
+package smartcache_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/m-zajac/smartcache"
+	"github.com/m-zajac/smartcache/backend/lru"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache_RefreshWorkersPriority(t *testing.T) {
+	t.Parallel()
+
+	backend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+
+	const primTTL = time.Minute
+	const secTTL = time.Hour
+	cache, err := smartcache.New[string](
+		backend,
+		smartcache.WithTTL(primTTL, secTTL),
+		smartcache.WithRefreshWorkers(1),
+	)
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	ages := map[string]time.Duration{
+		"blocker":    primTTL + time.Second,
+		"justWarm":   primTTL + time.Second,
+		"nearExpiry": secTTL - time.Minute,
+	}
+
+	var mu sync.Mutex
+	var refreshed []string
+	refreshing := false
+	blockerStarted := make(chan struct{})
+	releaseBlocker := make(chan struct{})
+
+	fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		mu.Lock()
+		isRefresh := refreshing
+		if isRefresh {
+			refreshed = append(refreshed, key)
+		}
+		mu.Unlock()
+
+		if isRefresh && key == "blocker" {
+			close(blockerStarted)
+			<-releaseBlocker
+		}
+
+		return &smartcache.FetchResult[string]{
+			Data:      &key,
+			CreatedAt: time.Now().Add(-ages[key]),
+		}, nil
+	}
+
+	ctx := context.Background()
+	for _, key := range []string{"blocker", "justWarm", "nearExpiry"} {
+		_, err := cache.Get(ctx, key, fetchFunc)
+		require.NoError(t, err)
+	}
+
+	mu.Lock()
+	refreshing = true
+	mu.Unlock()
+
+	// Saturate the pool.
+	result, err := cache.Get(ctx, "blocker", fetchFunc)
+	require.NoError(t, err)
+	require.Equal(t, smartcache.WarmHit, result.Type)
+	<-blockerStarted
+
+	// Queue refreshes, the less urgent one first.
+	for _, key := range []string{"justWarm", "nearExpiry"} {
+		result, err := cache.Get(ctx, key, fetchFunc)
+		require.NoError(t, err)
+		require.Equal(t, smartcache.WarmHit, result.Type)
+	}
+
+	close(releaseBlocker)
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(refreshed) == 3
+	}, time.Second, 10*time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"blocker", "nearExpiry", "justWarm"}, refreshed)
+}
+
+func TestCache_RefreshProbe(t *testing.T) {
+	t.Parallel()
+
+	for _, changed := range []bool{false, true} {
+		changed := changed
+		t.Run(fmt.Sprintf("changed=%v", changed), func(t *testing.T) {
+			t.Parallel()
+
+			backend, err := lru.NewBackend[string](100)
+			require.NoError(t, err)
+
+			var probes, fetches atomic.Int32
+			probed := make(chan string, 1)
+			probe := func(ctx context.Context, key string, current *string) (bool, error) {
+				probes.Add(1)
+				probed <- *current
+				return changed, nil
+			}
+
+			const primTTL = 50 * time.Millisecond
+			cache, err := smartcache.New[string](
+				backend,
+				smartcache.WithTTL(primTTL, time.Hour),
+				smartcache.WithRefreshProbe[string](probe),
+			)
+			require.NoError(t, err)
+			t.Cleanup(cache.Close)
+
+			fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+				data := fmt.Sprintf("data-%d", fetches.Add(1))
+				return &smartcache.FetchResult[string]{Data: &data}, nil
+			}
+
+			ctx := context.Background()
+			_, err = cache.Get(ctx, "key", fetchFunc)
+			require.NoError(t, err)
+
+			time.Sleep(primTTL + 10*time.Millisecond)
+			result, err := cache.Get(ctx, "key", fetchFunc)
+			require.NoError(t, err)
+			assert.Equal(t, smartcache.WarmHit, result.Type)
+			assert.Equal(t, "data-1", <-probed)
+
+			// Wait for the refresh to be stored.
+			require.Eventually(t, func() bool {
+				result, err = cache.Get(ctx, "key", fetchFunc)
+				return err == nil && result.Type == smartcache.HotHit
+			}, time.Second, 5*time.Millisecond)
+
+			assert.Equal(t, int32(1), probes.Load())
+			if changed {
+				assert.Equal(t, int32(2), fetches.Load())
+				assert.Equal(t, "data-2", *result.Data)
+			} else {
+				// Freshness was extended without the full fetch.
+				assert.Equal(t, int32(1), fetches.Load())
+				assert.Equal(t, "data-1", *result.Data)
+			}
+		})
+	}
+}
+
+func TestCache_RefreshProbeTypeMismatch(t *testing.T) {
+	t.Parallel()
+
+	backend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+
+	_, err = smartcache.New[string](backend, smartcache.WithRefreshProbe[int](
+		func(ctx context.Context, key string, current *int) (bool, error) { return true, nil },
+	))
+	assert.Error(t, err)
+}
+
+func TestCache_StartupRefreshDelay(t *testing.T) {
+	t.Parallel()
+
+	backend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+
+	// The backend was warmed by another instance.
+	stale := "stale"
+	require.NoError(t, backend.Set(context.Background(), "key", time.Hour, &smartcache.CacheEntry[string]{
+		Data:    &stale,
+		Created: time.Now().Add(-2 * time.Minute),
+	}))
+
+	const delay = 200 * time.Millisecond
+	cache, err := smartcache.New[string](
+		backend,
+		smartcache.WithTTL(time.Minute, time.Hour),
+		smartcache.WithStartupRefreshDelay(delay),
+	)
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	var fetches atomic.Int32
+	fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		fetches.Add(1)
+		data := "fresh"
+		return &smartcache.FetchResult[string]{Data: &data}, nil
+	}
+
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		result, err := cache.Get(ctx, "key", fetchFunc)
+		require.NoError(t, err)
+		assert.Equal(t, smartcache.WarmHit, result.Type)
+		assert.Equal(t, stale, *result.Data)
+	}
+	time.Sleep(20 * time.Millisecond)
+	assert.Zero(t, fetches.Load(), "no background refreshes within the startup window")
+
+	// Misses are fetched as usual.
+	_, err = cache.Get(ctx, "other", fetchFunc)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, fetches.Load())
+
+	time.Sleep(delay)
+	result, err := cache.Get(ctx, "key", fetchFunc)
+	require.NoError(t, err)
+	assert.Equal(t, smartcache.WarmHit, result.Type)
+	assert.Eventually(t, func() bool { return fetches.Load() == 2 }, time.Second, 5*time.Millisecond)
+}
+
+func TestCache_RefreshQueue(t *testing.T) {
+	t.Parallel()
+
+	backend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+
+	const primTTL = time.Minute
+	const secTTL = time.Hour
+	cache, err := smartcache.New[string](
+		backend,
+		smartcache.WithTTL(primTTL, secTTL),
+		smartcache.WithRefreshWorkers(1),
+		smartcache.WithRefreshQueue(2),
+	)
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	ages := map[string]time.Duration{
+		"blocker":    primTTL + time.Second,
+		"justWarm":   primTTL + time.Second,
+		"halfway":    secTTL / 2,
+		"nearExpiry": secTTL - time.Minute,
+		"lateWarm":   primTTL + 2*time.Second,
+	}
+
+	var mu sync.Mutex
+	var refreshed []string
+	refreshing := false
+	blockerStarted := make(chan struct{})
+	releaseBlocker := make(chan struct{})
+
+	fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		mu.Lock()
+		isRefresh := refreshing
+		if isRefresh {
+			refreshed = append(refreshed, key)
+		}
+		mu.Unlock()
+
+		if isRefresh && key == "blocker" {
+			close(blockerStarted)
+			<-releaseBlocker
+		}
+
+		return &smartcache.FetchResult[string]{
+			Data:      &key,
+			CreatedAt: time.Now().Add(-ages[key]),
+		}, nil
+	}
+
+	ctx := context.Background()
+	keys := []string{"justWarm", "halfway", "nearExpiry", "lateWarm"}
+	for _, key := range append([]string{"blocker"}, keys...) {
+		_, err := cache.Get(ctx, key, fetchFunc)
+		require.NoError(t, err)
+	}
+
+	mu.Lock()
+	refreshing = true
+	mu.Unlock()
+
+	// Saturate the pool.
+	result, err := cache.Get(ctx, "blocker", fetchFunc)
+	require.NoError(t, err)
+	require.Equal(t, smartcache.WarmHit, result.Type)
+	<-blockerStarted
+
+	// The queue fills up with "justWarm" and "halfway". "nearExpiry" replaces "justWarm",
+	// and "lateWarm" is less urgent than all queued refreshes, so it's dropped.
+	for _, key := range keys {
+		result, err := cache.Get(ctx, key, fetchFunc)
+		require.NoError(t, err)
+		require.Equal(t, smartcache.WarmHit, result.Type)
+	}
+	assert.EqualValues(t, 2, cache.Stats().RefreshesDropped)
+
+	close(releaseBlocker)
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(refreshed) == 3
+	}, time.Second, 10*time.Millisecond)
+
+	mu.Lock()
+	assert.Equal(t, []string{"blocker", "nearExpiry", "halfway"}, refreshed)
+	mu.Unlock()
+
+	// Dropped refreshes are retried on the next warm hit.
+	result, err = cache.Get(ctx, "justWarm", fetchFunc)
+	require.NoError(t, err)
+	require.Equal(t, smartcache.WarmHit, result.Type)
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(refreshed) == 4 && refreshed[3] == "justWarm"
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestCache_RefreshQueueRequiresWorkers(t *testing.T) {
+	t.Parallel()
+
+	backend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+
+	_, err = smartcache.New[string](backend, smartcache.WithRefreshQueue(10))
+	assert.Error(t, err)
+}
+
+func TestCache_StaleServeCount(t *testing.T) {
+	t.Parallel()
+
+	backend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+
+	const primTTL = 50 * time.Millisecond
+	cache, err := smartcache.New[string](backend, smartcache.WithTTL(primTTL, time.Hour))
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	var refreshing atomic.Bool
+	release := make(chan struct{})
+	refreshed := make(chan struct{})
+	fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		if refreshing.Load() {
+			<-release
+			defer close(refreshed)
+		}
+		data := "data"
+		return &smartcache.FetchResult[string]{Data: &data}, nil
+	}
+
+	ctx := context.Background()
+	_, err = cache.Get(ctx, "key", fetchFunc)
+	require.NoError(t, err)
+	assert.Zero(t, cache.StaleServeCount("key"))
+
+	time.Sleep(primTTL + 10*time.Millisecond)
+	refreshing.Store(true)
+
+	// The refresh is blocked, so the key keeps being served warm.
+	for i := 0; i < 3; i++ {
+		result, err := cache.Get(ctx, "key", fetchFunc)
+		require.NoError(t, err)
+		require.Equal(t, smartcache.WarmHit, result.Type)
+	}
+	assert.Equal(t, 3, cache.StaleServeCount("key"))
+	assert.Zero(t, cache.StaleServeCount("other"))
+
+	// Successful refresh resets the count.
+	close(release)
+	<-refreshed
+	assert.Eventually(t, func() bool { return cache.StaleServeCount("key") == 0 }, time.Second, 5*time.Millisecond)
+}
+
+func TestCache_MaxBackgroundRefreshes(t *testing.T) {
+	t.Parallel()
+
+	backend, err := lru.NewBackend[string](1000)
+	require.NoError(t, err)
+
+	_, err = smartcache.New[string](backend, smartcache.WithMaxBackgroundRefreshes(0))
+	require.Error(t, err)
+	_, err = smartcache.New[string](backend, smartcache.WithMaxBackgroundRefreshes(1), smartcache.WithRefreshWorkers(1))
+	require.Error(t, err)
+
+	const primTTL = time.Minute
+	const limit = 3
+	cache, err := smartcache.New[string](
+		backend,
+		smartcache.WithTTL(primTTL, time.Hour),
+		smartcache.WithMaxBackgroundRefreshes(limit),
+	)
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	var running, maxRunning atomic.Int32
+	release := make(chan struct{})
+	fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			m := maxRunning.Load()
+			if n <= m || maxRunning.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		<-release
+
+		return &smartcache.FetchResult[string]{Data: &key}, nil
+	}
+
+	const keys = 50
+	ctx := context.Background()
+	for i := 0; i < keys; i++ {
+		key := fmt.Sprintf("key%d", i)
+		require.NoError(t, backend.Set(ctx, key, time.Hour, &smartcache.CacheEntry[string]{
+			Data:    &key,
+			Created: time.Now().Add(-primTTL - time.Second),
+		}))
+	}
+
+	// Flood the cache with warm hits of distinct keys.
+	for i := 0; i < keys; i++ {
+		result, err := cache.Get(ctx, fmt.Sprintf("key%d", i), fetchFunc)
+		require.NoError(t, err)
+		require.Equal(t, smartcache.WarmHit, result.Type)
+	}
+	assert.Eventually(t, func() bool { return running.Load() == limit }, time.Second, 5*time.Millisecond)
+	assert.EqualValues(t, keys-limit, cache.Stats().RefreshesSkipped)
+
+	close(release)
+	assert.Eventually(t, func() bool { return cache.Stats().Refreshes == limit }, time.Second, 5*time.Millisecond)
+	assert.LessOrEqual(t, maxRunning.Load(), int32(limit))
+
+	// Slots are released, skipped keys are refreshed on later warm hits.
+	result, err := cache.Get(ctx, fmt.Sprintf("key%d", keys-1), fetchFunc)
+	require.NoError(t, err)
+	require.Equal(t, smartcache.WarmHit, result.Type)
+	assert.Eventually(t, func() bool { return cache.Stats().Refreshes == limit+1 }, time.Second, 5*time.Millisecond)
+}
@@ -0,0 +1,48 @@
+package smartcache
+
+import (
+	"fmt"
+	"hash/maphash"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache_ShardHash(t *testing.T) {
+	// The lowest bit of FNV-1a depends only on the lowest bits of the key bytes.
+	// Keys built from doubled characters all end up in the same of two shards by default.
+	keys := make([]string, 0, 100)
+	for i := 0; i < 100; i++ {
+		c := fmt.Sprintf("%02d", i)
+		keys = append(keys, "user:"+c+c)
+	}
+
+	distribution := func(options ...Option) []int {
+		sc, err := New[int](&memoizeBackend[int]{}, append(options, WithShardCount(2))...)
+		require.NoError(t, err)
+		defer sc.Close()
+
+		counts := make([]int, len(sc.shards))
+		for _, key := range keys {
+			shard := sc.shard(key)
+			for i := range sc.shards {
+				if sc.shards[i] == shard {
+					counts[i]++
+				}
+			}
+		}
+
+		return counts
+	}
+
+	def := distribution()
+	assert.ElementsMatch(t, []int{0, 100}, def)
+
+	seed := maphash.MakeSeed()
+	custom := distribution(WithShardHash(func(key string) uint64 {
+		return maphash.String(seed, key)
+	}))
+	assert.Greater(t, custom[0], 25)
+	assert.Greater(t, custom[1], 25)
+}
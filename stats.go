@@ -0,0 +1,140 @@
+package smartcache
+
+import (
+	"expvar"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Stats contains cache usage counters.
+type Stats struct {
+	// HotHits, WarmHits, Misses, StaleHits and FallbackHits are numbers of Get calls by their result type.
+	HotHits      uint64
+	WarmHits     uint64
+	Misses       uint64
+	StaleHits    uint64
+	FallbackHits uint64
+	// Refreshes is a number of finished background refreshes, RefreshErrors is a number of the failed ones.
+	Refreshes     uint64
+	RefreshErrors uint64
+	// FetchErrors is a number of fetch function calls that returned an error, in the foreground or in background refreshes.
+	FetchErrors uint64
+	// ForegroundFetches and BackgroundFetches are numbers of fetch function calls on misses and in background refreshes.
+	// ForegroundFetchTime and BackgroundFetchTime are their total durations.
+	ForegroundFetches   uint64
+	ForegroundFetchTime time.Duration
+	BackgroundFetches   uint64
+	BackgroundFetchTime time.Duration
+	// StaleServedBeyondTarget is a number of hits, including stale hits, that served data older than the freshness target.
+	// See `WithFreshnessTarget`.
+	StaleServedBeyondTarget uint64
+	// FetchLeader is a number of misses, for which the call fetched and stored the data.
+	FetchLeader uint64
+	// CoalescedFollower is a number of calls that waited for a concurrent fetch for the same key and reused its result.
+	CoalescedFollower uint64
+	// EventsDropped is a number of events not delivered to slow subscribers. See `Subscribe`.
+	EventsDropped uint64
+	// RefreshesDropped is a number of background refreshes dropped because the refresh queue was full.
+	// See `WithRefreshQueue`.
+	RefreshesDropped uint64
+	// RefreshesSkipped is a number of background refreshes not started because of the limit of running refreshes.
+	// See `WithMaxBackgroundRefreshes`.
+	RefreshesSkipped uint64
+	// BreakerState is the state of the circuit breaker, BreakerOpens is a number of times it opened.
+	// See `WithCircuitBreaker`.
+	BreakerState BreakerState
+	BreakerOpens uint64
+}
+
+// stats holds the counters updated by the cache.
+type stats struct {
+	hotHits                 atomic.Uint64
+	warmHits                atomic.Uint64
+	misses                  atomic.Uint64
+	staleHits               atomic.Uint64
+	fallbackHits            atomic.Uint64
+	refreshes               atomic.Uint64
+	refreshErrors           atomic.Uint64
+	fetchErrors             atomic.Uint64
+	foregroundFetches       atomic.Uint64
+	foregroundFetchTime     atomic.Int64
+	backgroundFetches       atomic.Uint64
+	backgroundFetchTime     atomic.Int64
+	staleServedBeyondTarget atomic.Uint64
+	fetchLeader             atomic.Uint64
+	coalescedFollower       atomic.Uint64
+	eventsDropped           atomic.Uint64
+	refreshesDropped        atomic.Uint64
+	refreshesSkipped        atomic.Uint64
+}
+
+func (s *stats) snapshot() Stats {
+	return Stats{
+		HotHits:                 s.hotHits.Load(),
+		WarmHits:                s.warmHits.Load(),
+		Misses:                  s.misses.Load(),
+		StaleHits:               s.staleHits.Load(),
+		FallbackHits:            s.fallbackHits.Load(),
+		Refreshes:               s.refreshes.Load(),
+		RefreshErrors:           s.refreshErrors.Load(),
+		FetchErrors:             s.fetchErrors.Load(),
+		ForegroundFetches:       s.foregroundFetches.Load(),
+		ForegroundFetchTime:     time.Duration(s.foregroundFetchTime.Load()),
+		BackgroundFetches:       s.backgroundFetches.Load(),
+		BackgroundFetchTime:     time.Duration(s.backgroundFetchTime.Load()),
+		StaleServedBeyondTarget: s.staleServedBeyondTarget.Load(),
+		FetchLeader:             s.fetchLeader.Load(),
+		CoalescedFollower:       s.coalescedFollower.Load(),
+		EventsDropped:           s.eventsDropped.Load(),
+		RefreshesDropped:        s.refreshesDropped.Load(),
+		RefreshesSkipped:        s.refreshesSkipped.Load(),
+	}
+}
+
+// countResult increments the counter of the result type.
+func (s *stats) countResult(resultType ResultType) {
+	switch resultType {
+	case HotHit:
+		s.hotHits.Add(1)
+	case WarmHit:
+		s.warmHits.Add(1)
+	case Miss:
+		s.misses.Add(1)
+	case StaleHit:
+		s.staleHits.Add(1)
+	case FallbackHit:
+		s.fallbackHits.Add(1)
+	}
+}
+
+// countFetch records a fetch function call of the given duration.
+func (s *stats) countFetch(d time.Duration, background bool) {
+	if background {
+		s.backgroundFetches.Add(1)
+		s.backgroundFetchTime.Add(int64(d))
+		return
+	}
+	s.foregroundFetches.Add(1)
+	s.foregroundFetchTime.Add(int64(d))
+}
+
+// expvarMu serializes checking and publishing expvar names, as expvar.Publish panics on duplicates.
+var expvarMu sync.Mutex
+
+// PublishExpvar publishes the cache stats in expvar under the given name, so they're served with other variables
+// at /debug/vars. An error is returned if the name is already used.
+func (sc *Cache[T]) PublishExpvar(name string) error {
+	expvarMu.Lock()
+	defer expvarMu.Unlock()
+
+	if expvar.Get(name) != nil {
+		return fmt.Errorf("expvar '%s' is already published", name)
+	}
+	expvar.Publish(name, expvar.Func(func() any {
+		return sc.Stats()
+	}))
+
+	return nil
+}
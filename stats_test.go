@@ -0,0 +1,197 @@
+package smartcache_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/m-zajac/smartcache"
+	"github.com/m-zajac/smartcache/backend/lru"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache_PublishExpvar(t *testing.T) {
+	t.Parallel()
+
+	backend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+	cache, err := smartcache.New[string](backend)
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	const name = "smartcache_test_stats"
+	require.NoError(t, cache.PublishExpvar(name))
+	assert.Error(t, cache.PublishExpvar(name), "duplicate name")
+
+	data := "some data"
+	_, err = cache.Get(context.Background(), "key", func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		return &smartcache.FetchResult[string]{Data: &data}, nil
+	})
+	require.NoError(t, err)
+
+	v := expvar.Get(name)
+	require.NotNil(t, v)
+
+	var stats smartcache.Stats
+	require.NoError(t, json.Unmarshal([]byte(v.String()), &stats))
+	assert.Equal(t, cache.Stats(), stats)
+	assert.EqualValues(t, 1, stats.FetchLeader)
+}
+
+func TestCache_StatsCounters(t *testing.T) {
+	t.Parallel()
+
+	backend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+
+	const primTTL = 50 * time.Millisecond
+	cache, err := smartcache.New[string](backend, smartcache.WithTTL(primTTL, time.Hour))
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	var fail atomic.Bool
+	fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		if fail.Load() {
+			return nil, errors.New("fetch failed")
+		}
+		data := "data " + key
+		return &smartcache.FetchResult[string]{Data: &data}, nil
+	}
+
+	ctx := context.Background()
+	get := func(key string, want smartcache.ResultType) {
+		t.Helper()
+		result, err := cache.Get(ctx, key, fetchFunc)
+		require.NoError(t, err)
+		require.Equal(t, want, result.Type)
+	}
+
+	get("a", smartcache.Miss)
+	get("b", smartcache.Miss)
+	get("a", smartcache.HotHit)
+	get("a", smartcache.HotHit)
+	get("b", smartcache.HotHit)
+
+	time.Sleep(primTTL + 10*time.Millisecond)
+
+	// Successful refresh.
+	get("a", smartcache.WarmHit)
+	assert.Eventually(t, func() bool { return cache.Stats().Refreshes == 1 }, time.Second, 5*time.Millisecond)
+	get("a", smartcache.HotHit)
+
+	// Failed refresh.
+	fail.Store(true)
+	get("b", smartcache.WarmHit)
+	assert.Eventually(t, func() bool { return cache.Stats().Refreshes == 2 }, time.Second, 5*time.Millisecond)
+
+	// Failed foreground fetch.
+	_, err = cache.Get(ctx, "c", fetchFunc)
+	require.Error(t, err)
+
+	stats := cache.Stats()
+	assert.EqualValues(t, 4, stats.HotHits)
+	assert.EqualValues(t, 2, stats.WarmHits)
+	assert.EqualValues(t, 3, stats.Misses)
+	assert.EqualValues(t, 0, stats.StaleHits)
+	assert.EqualValues(t, 2, stats.Refreshes)
+	assert.EqualValues(t, 1, stats.RefreshErrors)
+	assert.EqualValues(t, 2, stats.FetchErrors)
+	assert.EqualValues(t, 3, stats.ForegroundFetches)
+	assert.EqualValues(t, 2, stats.BackgroundFetches)
+	assert.Positive(t, stats.ForegroundFetchTime)
+	assert.Positive(t, stats.BackgroundFetchTime)
+}
+
+type recordingObserver struct {
+	mu            sync.Mutex
+	gets          []smartcache.ResultType
+	fetchErrs     []error
+	fetchOrigins  []bool
+	refreshErrs   []error
+	fetchDuration time.Duration
+}
+
+func (o *recordingObserver) ObserveGet(resultType smartcache.ResultType, d time.Duration) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.gets = append(o.gets, resultType)
+}
+
+func (o *recordingObserver) ObserveFetch(d time.Duration, err error, background bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.fetchErrs = append(o.fetchErrs, err)
+	o.fetchOrigins = append(o.fetchOrigins, background)
+	o.fetchDuration += d
+}
+
+func (o *recordingObserver) ObserveBackgroundRefresh(err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.refreshErrs = append(o.refreshErrs, err)
+}
+
+func (o *recordingObserver) refreshes() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.refreshErrs)
+}
+
+func TestCache_MetricsObserver(t *testing.T) {
+	t.Parallel()
+
+	backend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+
+	_, err = smartcache.New[string](backend, smartcache.WithMetricsObserver(nil))
+	require.Error(t, err)
+
+	obs := &recordingObserver{}
+	const primTTL = 50 * time.Millisecond
+	cache, err := smartcache.New[string](
+		backend,
+		smartcache.WithTTL(primTTL, time.Hour),
+		smartcache.WithMetricsObserver(obs),
+	)
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	fetchErr := errors.New("fetch failed")
+	var fail atomic.Bool
+	fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		time.Sleep(time.Millisecond)
+		if fail.Load() {
+			return nil, fetchErr
+		}
+		data := "data"
+		return &smartcache.FetchResult[string]{Data: &data}, nil
+	}
+
+	ctx := context.Background()
+	_, err = cache.Get(ctx, "key", fetchFunc)
+	require.NoError(t, err)
+	_, err = cache.Get(ctx, "key", fetchFunc)
+	require.NoError(t, err)
+
+	time.Sleep(primTTL + 10*time.Millisecond)
+
+	fail.Store(true)
+	_, err = cache.Get(ctx, "key", fetchFunc)
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool { return obs.refreshes() == 1 }, time.Second, 5*time.Millisecond)
+
+	obs.mu.Lock()
+	defer obs.mu.Unlock()
+	assert.Equal(t, []smartcache.ResultType{smartcache.Miss, smartcache.HotHit, smartcache.WarmHit}, obs.gets)
+	assert.Equal(t, []error{nil, fetchErr}, obs.fetchErrs)
+	assert.Equal(t, []bool{false, true}, obs.fetchOrigins, "foreground miss, background refresh")
+	assert.GreaterOrEqual(t, obs.fetchDuration, 2*time.Millisecond)
+	require.Len(t, obs.refreshErrs, 1)
+	assert.ErrorIs(t, obs.refreshErrs[0], fetchErr)
+}
@@ -0,0 +1,77 @@
+package smartcache
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// accessTrace writes access records in the format: "<unix nano timestamp>\t<quoted key>\t<result type>\n".
+type accessTrace struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (t *accessTrace) record(ts time.Time, key string, resultType ResultType) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// Tracing must not affect the cache, so write errors are ignored.
+	_, _ = fmt.Fprintf(t.w, "%d\t%s\t%s\n", ts.UnixNano(), strconv.Quote(key), resultType)
+}
+
+// Replay drives the cache with accesses recorded by `WithAccessTrace`, preserving time gaps between them.
+// Errors returned by Get calls are ignored, only invalid trace records and context errors stop the replay.
+func Replay[T any](ctx context.Context, cache *Cache[T], r io.Reader, fetchFunc FetchFunc[T]) error {
+	var last time.Time
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		ts, key, err := parseTraceRecord(scanner.Text())
+		if err != nil {
+			return fmt.Errorf("invalid trace record in line %d: %w", line, err)
+		}
+
+		if !last.IsZero() && ts.After(last) {
+			timer := time.NewTimer(ts.Sub(last))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-timer.C:
+			}
+		}
+		last = ts
+
+		_, _ = cache.Get(ctx, key, fetchFunc)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("reading trace: %w", err)
+	}
+
+	return ctx.Err()
+}
+
+func parseTraceRecord(record string) (ts time.Time, key string, err error) {
+	fields := strings.Split(record, "\t")
+	if len(fields) != 3 {
+		return ts, "", fmt.Errorf("expected 3 fields, got %d", len(fields))
+	}
+
+	nanos, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return ts, "", fmt.Errorf("parsing timestamp: %w", err)
+	}
+
+	key, err = strconv.Unquote(fields[1])
+	if err != nil {
+		return ts, "", fmt.Errorf("parsing key: %w", err)
+	}
+
+	return time.Unix(0, nanos), key, nil
+}
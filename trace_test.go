@@ -0,0 +1,94 @@
+package smartcache_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/m-zajac/smartcache"
+	"github.com/m-zajac/smartcache/backend/lru"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessTraceReplay(t *testing.T) {
+	t.Parallel()
+
+	const primTTL = 50 * time.Millisecond
+	const secTTL = 150 * time.Millisecond
+
+	newCache := func(options ...smartcache.Option) *smartcache.Cache[string] {
+		backend, err := lru.NewBackend[string](100)
+		require.NoError(t, err)
+
+		cache, err := smartcache.New[string](
+			backend,
+			append(options, smartcache.WithTTL(primTTL, secTTL))...,
+		)
+		require.NoError(t, err)
+		t.Cleanup(cache.Close)
+
+		return cache
+	}
+
+	var calls atomic.Int32
+	data := "some data"
+	fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		calls.Add(1)
+		return &smartcache.FetchResult[string]{
+			Data: &data,
+		}, nil
+	}
+
+	// Record the trace.
+	var trace bytes.Buffer
+	cache := newCache(smartcache.WithAccessTrace(&trace))
+	ctx := context.Background()
+
+	get := func(key string) {
+		_, err := cache.Get(ctx, key, fetchFunc)
+		require.NoError(t, err)
+	}
+	get("key 1")            // Miss.
+	get("key\t2")           // Miss.
+	get("key 1")            // Hot hit.
+	time.Sleep(2 * secTTL)  // Both keys expire.
+	get("key 1")            // Miss.
+	time.Sleep(primTTL * 2) // Key is warm.
+	get("key 1")            // Warm hit, background refresh.
+	time.Sleep(primTTL / 2) // Let the refresh finish.
+
+	recordedCalls := calls.Load()
+	assert.EqualValues(t, 4, recordedCalls)
+
+	lines := strings.Split(strings.TrimSpace(trace.String()), "\n")
+	require.Len(t, lines, 5)
+	assert.True(t, strings.HasSuffix(lines[0], "\t\"key 1\"\tMiss"))
+	assert.True(t, strings.HasSuffix(lines[1], "\t\"key\\t2\"\tMiss"))
+	assert.True(t, strings.HasSuffix(lines[2], "\t\"key 1\"\tHotHit"))
+	assert.True(t, strings.HasSuffix(lines[4], "\t\"key 1\"\tWarmHit"))
+
+	// Replay the trace against a fresh cache with the same config.
+	calls.Store(0)
+	replayCache := newCache()
+	err := smartcache.Replay(ctx, replayCache, &trace, fetchFunc)
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool { return calls.Load() == recordedCalls }, time.Second, 10*time.Millisecond)
+}
+
+func TestReplay_InvalidTrace(t *testing.T) {
+	t.Parallel()
+
+	backend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+
+	cache, err := smartcache.New[string](backend)
+	require.NoError(t, err)
+
+	err = smartcache.Replay(context.Background(), cache, strings.NewReader("invalid\n"), nil)
+	assert.Error(t, err)
+}
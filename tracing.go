@@ -0,0 +1,65 @@
+package smartcache
+
+import "context"
+
+// Tracer starts spans of cache operations. It allows integrating any tracing library without adding it
+// as a dependency of the cache.
+type Tracer interface {
+	// Start starts a span as a child of the span in ctx, if any. The returned context carries the new span.
+	Start(ctx context.Context, name string) (context.Context, Span)
+	// StartLinked works like Start, but also links the span to the span carried by linked, if any.
+	// It's used for background refreshes, which run outside of the request that initiated them.
+	StartLinked(ctx context.Context, name string, linked context.Context) (context.Context, Span)
+}
+
+// Span is a single traced operation.
+type Span interface {
+	SetAttribute(key, value string)
+	RecordError(err error)
+	End()
+}
+
+// Names of the spans and their attributes.
+const (
+	SpanGet     = "smartcache.Get"
+	SpanFetch   = "smartcache.fetch"
+	SpanRefresh = "smartcache.refresh"
+
+	AttributeKey    = "smartcache.key"
+	AttributeResult = "smartcache.result"
+)
+
+// tracedGet wraps the get call in a span tagged with the key and the result type.
+func (sc *Cache[T]) tracedGet(ctx context.Context, key string, fetchFunc FetchFunc[T], opts getOptions[T]) (Result[T], error) {
+	ctx, span := sc.config.tracer.Start(ctx, SpanGet)
+	defer span.End()
+	span.SetAttribute(AttributeKey, key)
+
+	result, err := sc.getOrFetch(ctx, key, fetchFunc, opts)
+	span.SetAttribute(AttributeResult, result.Type.String())
+	if err != nil {
+		span.RecordError(err)
+	}
+
+	return result, err
+}
+
+// startFetchSpan starts a span of a fetch function call, if there's a tracer.
+// The returned function ends the span, recording the fetch error.
+func (sc *Cache[T]) startFetchSpan(ctx context.Context, name, key string) (context.Context, func(error)) {
+	if sc.config.tracer == nil {
+		return ctx, func(error) {}
+	}
+
+	ctx, span := sc.config.tracer.Start(ctx, name)
+	if key != "" {
+		span.SetAttribute(AttributeKey, key)
+	}
+
+	return ctx, func(err error) {
+		if err != nil {
+			span.RecordError(err)
+		}
+		span.End()
+	}
+}
@@ -0,0 +1,63 @@
+// Package otel provides a smartcache.Tracer creating OpenTelemetry spans.
+package otel
+
+import (
+	"context"
+
+	"github.com/m-zajac/smartcache"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracer adapts an OpenTelemetry tracer to `smartcache.Tracer`.
+type Tracer struct {
+	tracer trace.Tracer
+}
+
+var _ smartcache.Tracer = &Tracer{}
+
+// NewTracer creates a tracer starting spans with the given OpenTelemetry tracer,
+// e.g. `otel.Tracer("github.com/m-zajac/smartcache")`.
+func NewTracer(tracer trace.Tracer) *Tracer {
+	return &Tracer{
+		tracer: tracer,
+	}
+}
+
+func (t *Tracer) Start(ctx context.Context, name string) (context.Context, smartcache.Span) {
+	ctx, span := t.tracer.Start(ctx, name)
+
+	return ctx, &otelSpan{span: span}
+}
+
+// StartLinked starts a new root span, linked to the span carried by linked, if any.
+func (t *Tracer) StartLinked(ctx context.Context, name string, linked context.Context) (context.Context, smartcache.Span) {
+	opts := []trace.SpanStartOption{trace.WithNewRoot()}
+	if linked != nil {
+		if sc := trace.SpanContextFromContext(linked); sc.IsValid() {
+			opts = append(opts, trace.WithLinks(trace.Link{SpanContext: sc}))
+		}
+	}
+	ctx, span := t.tracer.Start(ctx, name, opts...)
+
+	return ctx, &otelSpan{span: span}
+}
+
+// otelSpan adapts an OpenTelemetry span to `smartcache.Span`.
+type otelSpan struct {
+	span trace.Span
+}
+
+func (s *otelSpan) SetAttribute(key, value string) {
+	s.span.SetAttributes(attribute.String(key, value))
+}
+
+func (s *otelSpan) RecordError(err error) {
+	s.span.RecordError(err)
+	s.span.SetStatus(codes.Error, err.Error())
+}
+
+func (s *otelSpan) End() {
+	s.span.End()
+}
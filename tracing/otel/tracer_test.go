@@ -0,0 +1,86 @@
+package otel_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/m-zajac/smartcache"
+	"github.com/m-zajac/smartcache/backend/lru"
+	smartotel "github.com/m-zajac/smartcache/tracing/otel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracer(t *testing.T) {
+	t.Parallel()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	tracer := provider.Tracer("test")
+
+	backend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+
+	const primTTL = 50 * time.Millisecond
+	cache, err := smartcache.New[string](
+		backend,
+		smartcache.WithTTL(primTTL, time.Hour),
+		smartcache.WithTracer(smartotel.NewTracer(tracer)),
+	)
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	fetches := 0
+	fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		fetches++
+		if fetches > 1 {
+			return nil, errors.New("fetch failed")
+		}
+		data := "data"
+		return &smartcache.FetchResult[string]{Data: &data}, nil
+	}
+
+	ctx, request := tracer.Start(context.Background(), "request")
+	_, err = cache.Get(ctx, "key", fetchFunc)
+	require.NoError(t, err)
+	time.Sleep(primTTL + 10*time.Millisecond)
+	_, err = cache.Get(ctx, "key", fetchFunc)
+	require.NoError(t, err)
+	request.End()
+
+	assert.Eventually(t, func() bool { return len(recorder.Ended()) == 6 }, time.Second, 5*time.Millisecond)
+
+	byName := make(map[string][]sdktrace.ReadOnlySpan)
+	for _, s := range recorder.Ended() {
+		byName[s.Name()] = append(byName[s.Name()], s)
+	}
+	require.Len(t, byName[smartcache.SpanGet], 2)
+	require.Len(t, byName[smartcache.SpanFetch], 2)
+	require.Len(t, byName[smartcache.SpanRefresh], 1)
+
+	missGet, warmGet := byName[smartcache.SpanGet][0], byName[smartcache.SpanGet][1]
+	assert.Equal(t, request.SpanContext().SpanID(), missGet.Parent().SpanID())
+	assert.Contains(t, missGet.Attributes(), attribute.String(smartcache.AttributeKey, "key"))
+	assert.Contains(t, missGet.Attributes(), attribute.String(smartcache.AttributeResult, "Miss"))
+	assert.Contains(t, warmGet.Attributes(), attribute.String(smartcache.AttributeResult, "WarmHit"))
+
+	fetch := byName[smartcache.SpanFetch][0]
+	assert.Equal(t, missGet.SpanContext().SpanID(), fetch.Parent().SpanID())
+
+	// The refresh is a new root, linked to the warm get.
+	refresh := byName[smartcache.SpanRefresh][0]
+	assert.False(t, refresh.Parent().IsValid())
+	require.Len(t, refresh.Links(), 1)
+	assert.Equal(t, warmGet.SpanContext().SpanID(), refresh.Links()[0].SpanContext.SpanID())
+	assert.Equal(t, codes.Error, refresh.Status().Code)
+
+	refreshFetch := byName[smartcache.SpanFetch][1]
+	assert.Equal(t, refresh.SpanContext().SpanID(), refreshFetch.Parent().SpanID())
+	assert.Equal(t, codes.Error, refreshFetch.Status().Code)
+}
@@ -0,0 +1,169 @@
+package smartcache_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/m-zajac/smartcache"
+	"github.com/m-zajac/smartcache/backend/lru"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type spanCtxKey struct{}
+
+type testSpan struct {
+	tracer *testTracer
+	name   string
+	parent *testSpan
+	link   *testSpan
+	attrs  map[string]string
+	err    error
+	ended  bool
+}
+
+func (s *testSpan) SetAttribute(key, value string) {
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	s.attrs[key] = value
+}
+
+func (s *testSpan) RecordError(err error) {
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	s.err = err
+}
+
+func (s *testSpan) End() {
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	s.ended = true
+}
+
+type testTracer struct {
+	mu    sync.Mutex
+	spans []*testSpan
+}
+
+func (t *testTracer) Start(ctx context.Context, name string) (context.Context, smartcache.Span) {
+	return t.StartLinked(ctx, name, nil)
+}
+
+func (t *testTracer) StartLinked(ctx context.Context, name string, linked context.Context) (context.Context, smartcache.Span) {
+	span := &testSpan{
+		tracer: t,
+		name:   name,
+		attrs:  make(map[string]string),
+	}
+	span.parent, _ = ctx.Value(spanCtxKey{}).(*testSpan)
+	if linked != nil {
+		span.link, _ = linked.Value(spanCtxKey{}).(*testSpan)
+	}
+
+	t.mu.Lock()
+	t.spans = append(t.spans, span)
+	t.mu.Unlock()
+
+	return context.WithValue(ctx, spanCtxKey{}, span), span
+}
+
+// ended returns copies of the ended spans, in the order they were started.
+func (t *testTracer) ended() []testSpan {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var spans []testSpan
+	for _, s := range t.spans {
+		if s.ended {
+			spans = append(spans, *s)
+		}
+	}
+	return spans
+}
+
+func TestCache_Tracer(t *testing.T) {
+	t.Parallel()
+
+	backend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+
+	_, err = smartcache.New[string](backend, smartcache.WithTracer(nil))
+	require.Error(t, err)
+
+	tracer := &testTracer{}
+	const primTTL = 50 * time.Millisecond
+	cache, err := smartcache.New[string](
+		backend,
+		smartcache.WithTTL(primTTL, time.Hour),
+		smartcache.WithTracer(tracer),
+	)
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	fetchErr := errors.New("fetch failed")
+	var fetchCtxs []context.Context
+	var mu sync.Mutex
+	fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		mu.Lock()
+		defer mu.Unlock()
+		fetchCtxs = append(fetchCtxs, ctx)
+		if len(fetchCtxs) > 1 {
+			return nil, fetchErr
+		}
+		data := "data"
+		return &smartcache.FetchResult[string]{Data: &data}, nil
+	}
+
+	ctx, requestSpan := tracer.Start(context.Background(), "request")
+
+	// Miss, with a fetch span.
+	_, err = cache.Get(ctx, "key", fetchFunc)
+	require.NoError(t, err)
+
+	spans := tracer.ended()
+	require.Len(t, spans, 2)
+	get, fetch := spans[0], spans[1]
+	assert.Equal(t, smartcache.SpanGet, get.name)
+	assert.Same(t, requestSpan, get.parent)
+	assert.Equal(t, map[string]string{
+		smartcache.AttributeKey:    "key",
+		smartcache.AttributeResult: "Miss",
+	}, get.attrs)
+	assert.Equal(t, smartcache.SpanFetch, fetch.name)
+	assert.Equal(t, smartcache.SpanGet, fetch.parent.name)
+	assert.Equal(t, "key", fetch.attrs[smartcache.AttributeKey])
+	assert.NoError(t, fetch.err)
+
+	// Warm hit, with a failing refresh in a new root span linked to the get span.
+	time.Sleep(primTTL + 10*time.Millisecond)
+	_, err = cache.Get(ctx, "key", fetchFunc)
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool { return len(tracer.ended()) == 5 }, time.Second, 5*time.Millisecond)
+	spans = tracer.ended()
+
+	var warmGet, refresh, refreshFetch testSpan
+	for _, s := range spans[2:] {
+		switch s.name {
+		case smartcache.SpanGet:
+			warmGet = s
+		case smartcache.SpanRefresh:
+			refresh = s
+		case smartcache.SpanFetch:
+			refreshFetch = s
+		}
+	}
+	assert.Equal(t, "WarmHit", warmGet.attrs[smartcache.AttributeResult])
+	assert.Nil(t, refresh.parent)
+	require.NotNil(t, refresh.link)
+	assert.Equal(t, smartcache.SpanGet, refresh.link.name)
+	assert.Same(t, requestSpan, refresh.link.parent)
+	assert.ErrorIs(t, refresh.err, fetchErr)
+	assert.Equal(t, "key", refresh.attrs[smartcache.AttributeKey])
+	require.NotNil(t, refreshFetch.parent)
+	assert.Equal(t, smartcache.SpanRefresh, refreshFetch.parent.name)
+	assert.ErrorIs(t, refreshFetch.err, fetchErr)
+}
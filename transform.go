@@ -0,0 +1,22 @@
+package smartcache
+
+import "context"
+
+// GetTransformed works like `Cache.Get`, but returns the data converted with the transform function.
+// The transform is applied on every read and its result is not cached, so the cache can keep a compact
+// representation, while callers get the shape they need. It's not called if there's no data.
+func GetTransformed[T, U any](ctx context.Context, cache *Cache[T], key string, fetchFunc FetchFunc[T], transform func(*T) *U) (Result[U], error) {
+	result, err := cache.Get(ctx, key, fetchFunc)
+
+	transformed := Result[U]{
+		Type:       result.Type,
+		Age:        result.Age,
+		CreatedAt:  result.CreatedAt,
+		RefreshErr: result.RefreshErr,
+	}
+	if result.Data != nil {
+		transformed.Data = transform(result.Data)
+	}
+
+	return transformed, err
+}
@@ -0,0 +1,60 @@
+package smartcache_test
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/m-zajac/smartcache"
+	"github.com/m-zajac/smartcache/backend/lru"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetTransformed(t *testing.T) {
+	t.Parallel()
+
+	backend, err := lru.NewBackend[string](100)
+	require.NoError(t, err)
+
+	const primTTL = 50 * time.Millisecond
+	cache, err := smartcache.New[string](backend, smartcache.WithTTL(primTTL, time.Hour))
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		data := "a,b,c"
+		return &smartcache.FetchResult[string]{Data: &data}, nil
+	}
+
+	var transforms atomic.Int32
+	transform := func(v *string) *[]string {
+		transforms.Add(1)
+		parts := strings.Split(*v, ",")
+		return &parts
+	}
+
+	ctx := context.Background()
+	wantTypes := []smartcache.ResultType{smartcache.Miss, smartcache.HotHit}
+	for i, want := range wantTypes {
+		result, err := smartcache.GetTransformed(ctx, cache, "key", fetchFunc, transform)
+		require.NoError(t, err)
+		assert.Equal(t, want, result.Type)
+		assert.Equal(t, []string{"a", "b", "c"}, *result.Data)
+		assert.EqualValues(t, i+1, transforms.Load())
+	}
+
+	time.Sleep(primTTL + 10*time.Millisecond)
+	result, err := smartcache.GetTransformed(ctx, cache, "key", fetchFunc, transform)
+	require.NoError(t, err)
+	assert.Equal(t, smartcache.WarmHit, result.Type)
+	assert.Equal(t, []string{"a", "b", "c"}, *result.Data)
+	assert.EqualValues(t, 3, transforms.Load())
+
+	// The stored value is the compact form.
+	entry, err := backend.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, "a,b,c", *entry.Data)
+}
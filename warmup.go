@@ -0,0 +1,104 @@
+package smartcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// maxWarmupErrors is the number of errors collected by `WarmupStream`, further errors are only counted.
+const maxWarmupErrors = 10
+
+// WarmupError aggregates errors of a warmup.
+type WarmupError struct {
+	// Errors contains the first collected errors.
+	Errors []error
+	// Failed is the total number of keys that failed, including ones not present in Errors.
+	Failed int
+}
+
+func (e *WarmupError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+
+	return fmt.Sprintf("warmup failed for %d keys: %s", e.Failed, strings.Join(msgs, "; "))
+}
+
+// Is reports whether any of the collected errors matches the target.
+func (e *WarmupError) Is(target error) bool {
+	for _, err := range e.Errors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// WarmupStream refreshes all keys received from the channel, using at most concurrency parallel fetches.
+// Keys are consumed until the channel is closed or ctx is done, so they can be streamed without buffering them.
+// All keys are fetched even if some of them fail. The returned error is a `*WarmupError`, or ctx error if warmup
+// was interrupted.
+func (sc *Cache[T]) WarmupStream(ctx context.Context, keys <-chan string, fetchFunc FetchFunc[T], concurrency int) error {
+	return warmupStream(ctx, keys, concurrency, func(key string) string { return key }, func(ctx context.Context, key string) error {
+		_, err := sc.RefreshAndWait(ctx, key, fetchFunc)
+		return err
+	})
+}
+
+// warmupStream runs refresh for all keys received from the channel, see `Cache.WarmupStream`.
+// The storage key returned by keyFunc is used in the collected errors.
+func warmupStream[K any](ctx context.Context, keys <-chan K, concurrency int, keyFunc func(K) string, refresh func(ctx context.Context, key K) error) error {
+	if concurrency <= 0 {
+		return errors.New("concurrency has to be > 0")
+	}
+
+	var (
+		mu      sync.Mutex
+		warmErr WarmupError
+		wg      sync.WaitGroup
+	)
+
+	wg.Add(concurrency)
+	for i := 0; i < concurrency; i++ {
+		go func() {
+			defer wg.Done()
+
+			for {
+				var key K
+				var ok bool
+				select {
+				case <-ctx.Done():
+					return
+				case key, ok = <-keys:
+					if !ok {
+						return
+					}
+				}
+
+				if err := refresh(ctx, key); err != nil {
+					mu.Lock()
+					warmErr.Failed++
+					if len(warmErr.Errors) < maxWarmupErrors {
+						warmErr.Errors = append(warmErr.Errors, fmt.Errorf("key '%s': %w", keyFunc(key), err))
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if warmErr.Failed > 0 {
+		return &warmErr
+	}
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package smartcache_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/m-zajac/smartcache"
+	"github.com/m-zajac/smartcache/backend/lru"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache_WarmupStream(t *testing.T) {
+	t.Parallel()
+
+	backend, err := lru.NewBackend[string](1000)
+	require.NoError(t, err)
+
+	cache, err := smartcache.New[string](backend, smartcache.WithTTL(time.Minute, time.Hour))
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	const concurrency = 4
+	var running, maxRunning atomic.Int32
+	fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			m := maxRunning.Load()
+			if n <= m || maxRunning.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+
+		data := "data-" + key
+		return &smartcache.FetchResult[string]{Data: &data}, nil
+	}
+
+	const numKeys = 100
+	keys := make(chan string)
+	go func() {
+		defer close(keys)
+		for i := 0; i < numKeys; i++ {
+			keys <- fmt.Sprintf("key-%d", i)
+		}
+	}()
+
+	require.NoError(t, cache.WarmupStream(context.Background(), keys, fetchFunc, concurrency))
+	assert.LessOrEqual(t, maxRunning.Load(), int32(concurrency))
+
+	for i := 0; i < numKeys; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		result, err := cache.Get(context.Background(), key, fetchFunc)
+		require.NoError(t, err)
+		assert.Equal(t, smartcache.HotHit, result.Type)
+		assert.Equal(t, "data-"+key, *result.Data)
+	}
+}
+
+func TestCache_WarmupStreamErrors(t *testing.T) {
+	t.Parallel()
+
+	backend, err := lru.NewBackend[string](1000)
+	require.NoError(t, err)
+
+	cache, err := smartcache.New[string](backend)
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+
+	fetchErr := errors.New("fetch failed")
+	fetchFunc := func(ctx context.Context, key string) (*smartcache.FetchResult[string], error) {
+		return nil, fetchErr
+	}
+
+	const numKeys = 50
+	keys := make(chan string, numKeys)
+	for i := 0; i < numKeys; i++ {
+		keys <- fmt.Sprintf("key-%d", i)
+	}
+	close(keys)
+
+	err = cache.WarmupStream(context.Background(), keys, fetchFunc, 3)
+	require.ErrorIs(t, err, fetchErr)
+
+	var warmErr *smartcache.WarmupError
+	require.ErrorAs(t, err, &warmErr)
+	assert.Equal(t, numKeys, warmErr.Failed)
+	assert.Less(t, len(warmErr.Errors), numKeys)
+}